@@ -0,0 +1,244 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package console commands.go Defines the commands every console starts with.
+package console
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bluemun/munfall"
+	"github.com/op/go-logging"
+)
+
+func (c *Console) addBuiltinCommands() {
+	c.commands["help"] = &command{usage: "help", direct: true, run: func(munfall.World, []string) (string, error) {
+		return c.help(), nil
+	}}
+	// The game loop reads the log levels while it logs, so they are set from it too.
+	c.AddCommand("loglevel", "loglevel <critical|error|warning|notice|info|debug>", setLogLevel)
+	c.AddCommand("list", "list [tag]", listActors)
+	c.AddCommand("inspect", "inspect <actor id>", inspectActor)
+	c.AddCommand("spawn", "spawn <definition> [x y [z]] [parameter=value...]", c.spawnActor)
+	c.AddCommand("kill", "kill <actor id>", killActor)
+	c.AddCommand("order", "order <actor id|global> <order> [value]", issueOrder)
+	c.AddCommand("pause", "pause", func(w munfall.World, args []string) (string, error) {
+		w.Pause()
+		return "paused", nil
+	})
+	c.AddCommand("resume", "resume", func(w munfall.World, args []string) (string, error) {
+		w.Resume()
+		return "resumed", nil
+	})
+	c.AddCommand("step", "step [ticks]", stepWorld)
+}
+
+func setLogLevel(w munfall.World, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("usage: loglevel <level>")
+	}
+
+	level, err := logging.LogLevel(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	logging.SetLevel(level, "")
+	return "log level set to " + level.String(), nil
+}
+
+func listActors(w munfall.World, args []string) (string, error) {
+	actors := w.Actors()
+	if len(args) == 1 {
+		actors = w.ActorsWithTag(args[0])
+	} else if len(args) > 1 {
+		return "", errors.New("usage: list [tag]")
+	}
+
+	var b strings.Builder
+	for _, a := range actors {
+		pos := a.Pos()
+		fmt.Fprintf(&b, "%d\t(%g, %g, %g)", a.ActorID(), pos.X, pos.Y, pos.Z)
+		if tags := a.Tags(); len(tags) > 0 {
+			fmt.Fprintf(&b, "\t[%s]", strings.Join(tags, " "))
+		}
+		if parent := a.Parent(); parent != nil {
+			fmt.Fprintf(&b, "\tattached to %d", parent.ActorID())
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+func inspectActor(w munfall.World, args []string) (string, error) {
+	a, err := actorArg(w, args)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	pos := a.Pos()
+	fmt.Fprintf(&b, "actor %d at (%g, %g, %g) tags [%s]\n", a.ActorID(), pos.X, pos.Y, pos.Z, strings.Join(a.Tags(), " "))
+	for _, trait := range w.GetTraitsImplementing(a, (*munfall.Trait)(nil)) {
+		fmt.Fprintf(&b, "%T\n", trait)
+		v := reflect.Indirect(reflect.ValueOf(trait))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			fmt.Fprintf(&b, "  %s: %s\n", v.Type().Field(i).Name, formatField(v.Field(i)))
+		}
+	}
+
+	return b.String(), nil
+}
+
+// formatField formats a trait field, references to the engine in exported
+// fields are shortened so they don't flood the output.
+func formatField(v reflect.Value) string {
+	if !v.CanInterface() {
+		return fmt.Sprintf("%v", v)
+	}
+
+	switch value := v.Interface().(type) {
+	case munfall.Actor:
+		if munfall.IsNil(value) {
+			return "<nil>"
+		}
+		return fmt.Sprint("actor ", value.ActorID())
+	case munfall.World, munfall.WorldMap:
+		return fmt.Sprintf("%T", value)
+	default:
+		return fmt.Sprintf("%+v", value)
+	}
+}
+
+func (c *Console) spawnActor(w munfall.World, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("usage: spawn <definition> [x y [z]] [parameter=value...]")
+	}
+
+	pos := &munfall.WPos{}
+	coords := []*float32{&pos.X, &pos.Y, &pos.Z}
+	params := make(map[string]interface{})
+	i := 0
+	for _, arg := range args[1:] {
+		if key := strings.SplitN(arg, "=", 2); len(key) == 2 {
+			params[key[0]] = parseValue(key[1])
+			continue
+		}
+
+		if i >= len(coords) {
+			return "", fmt.Errorf("unexpected argument %q", arg)
+		}
+
+		f, err := strconv.ParseFloat(arg, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid coordinate %q", arg)
+		}
+		*coords[i] = float32(f)
+		i++
+	}
+
+	if len(params) == 0 {
+		params = nil
+	}
+
+	a, err := c.registry.CreateActorE(args[0], params, w, false)
+	if err != nil {
+		return "", err
+	}
+
+	a.SetPos(pos)
+	w.AddToWorld(a)
+	return fmt.Sprint("spawned actor ", a.ActorID()), nil
+}
+
+func killActor(w munfall.World, args []string) (string, error) {
+	a, err := actorArg(w, args)
+	if err != nil {
+		return "", err
+	}
+
+	a.Kill()
+	return fmt.Sprint("killed actor ", a.ActorID()), nil
+}
+
+func issueOrder(w munfall.World, args []string) (string, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return "", errors.New("usage: order <actor id|global> <order> [value]")
+	}
+
+	order := &munfall.Order{Order: args[1]}
+	if len(args) == 3 {
+		order.Value = parseValue(args[2])
+	}
+
+	if args[0] == "global" {
+		w.IssueGlobalOrder(order)
+		return "issued global order " + order.Order, nil
+	}
+
+	a, err := actorArg(w, args[:1])
+	if err != nil {
+		return "", err
+	}
+
+	w.IssueOrder(a, order)
+	return fmt.Sprint("issued order ", order.Order, " to actor ", a.ActorID()), nil
+}
+
+func stepWorld(w munfall.World, args []string) (string, error) {
+	ticks := 1
+	if len(args) == 1 {
+		var err error
+		if ticks, err = strconv.Atoi(args[0]); err != nil || ticks < 1 {
+			return "", fmt.Errorf("invalid tick count %q", args[0])
+		}
+	}
+
+	if !w.IsPaused() {
+		return "", errors.New("the world has to be paused to step")
+	}
+
+	w.Step(ticks)
+	return fmt.Sprint("stepping ", ticks, " ticks"), nil
+}
+
+func actorArg(w munfall.World, args []string) (munfall.Actor, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected a single actor id")
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid actor id %q", args[0])
+	}
+
+	a := w.GetActor(uint(id))
+	if a == nil {
+		return nil, fmt.Errorf("actor %d is not in the world", id)
+	}
+
+	return a, nil
+}
+
+// parseValue turns a console argument into a float64, bool or string.
+func parseValue(s string) interface{} {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+
+	return s
+}
@@ -0,0 +1,236 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package console console.go Defines an opt-in developer console that accepts
+// text commands over a local socket and runs them on the game loop.
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+)
+
+// CommandFunc runs a console command on the game loop and returns the text
+// that is sent back to the client.
+type CommandFunc func(w munfall.World, args []string) (string, error)
+
+type command struct {
+	usage string
+	run   CommandFunc
+	// direct commands don't touch the world and run on the connection goroutine.
+	direct bool
+}
+
+// Console is a developer console server, commands are read one per line and
+// executed on the game loop through the world command queue.
+type Console struct {
+	world    munfall.World
+	registry *logic.ActorRegistry
+	timeout  time.Duration
+
+	mutex    sync.Mutex
+	commands map[string]*command
+	listener net.Listener
+	conns    map[net.Conn]bool
+}
+
+// CreateConsole creates a console for the given world, the registry is used to
+// spawn actors by their definition name.
+func CreateConsole(w munfall.World, ar *logic.ActorRegistry) *Console {
+	c := &Console{
+		world:    w,
+		registry: ar,
+		timeout:  5 * time.Second,
+		commands: make(map[string]*command),
+		conns:    make(map[net.Conn]bool),
+	}
+
+	c.addBuiltinCommands()
+	return c
+}
+
+// AddCommand adds a command to the console, replacing any command with the same name.
+func (c *Console) AddCommand(name, usage string, run CommandFunc) {
+	c.mutex.Lock()
+	c.commands[name] = &command{usage: usage, run: run}
+	c.mutex.Unlock()
+}
+
+// SetTimeout sets how long a command waits for the game loop to run it.
+func (c *Console) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// Listen starts accepting connections, network must be "unix" or "tcp" and tcp
+// addresses must be on the loopback interface.
+func (c *Console) Listen(network, address string) error {
+	switch network {
+	case "unix":
+	case "tcp", "tcp4", "tcp6":
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("console: refusing to listen on non-local address %s", address)
+		}
+	default:
+		return fmt.Errorf("console: unsupported network %s", network)
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.listener = listener
+	c.mutex.Unlock()
+
+	munfall.Logger.Info("Developer console listening on", listener.Addr())
+	go c.accept(listener)
+	return nil
+}
+
+// Addr returns the address the console listens on, nil if it isn't listening.
+func (c *Console) Addr() net.Addr {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.listener == nil {
+		return nil
+	}
+
+	return c.listener.Addr()
+}
+
+// Close stops the console and closes all open connections.
+func (c *Console) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+
+	if c.listener == nil {
+		return nil
+	}
+
+	err := c.listener.Close()
+	c.listener = nil
+	return err
+}
+
+func (c *Console) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c.mutex.Lock()
+		c.conns[conn] = true
+		c.mutex.Unlock()
+		go c.serve(conn)
+	}
+}
+
+func (c *Console) serve(conn net.Conn) {
+	defer func() {
+		c.mutex.Lock()
+		delete(c.conns, conn)
+		c.mutex.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	fmt.Fprint(conn, "> ")
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "quit" || line == "exit" {
+			return
+		}
+
+		if line != "" {
+			out, err := c.Execute(line)
+			if err != nil {
+				fmt.Fprintln(conn, "error:", err)
+			} else if out != "" {
+				fmt.Fprintln(conn, strings.TrimRight(out, "\n"))
+			}
+		}
+
+		fmt.Fprint(conn, "> ")
+	}
+}
+
+// Execute parses and runs a single command line, waiting for the game loop
+// to run it, safe to call from any goroutine.
+func (c *Console) Execute(line string) (string, error) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	c.mutex.Lock()
+	cmd, exists := c.commands[args[0]]
+	c.mutex.Unlock()
+	if !exists {
+		return "", fmt.Errorf("unknown command %q, try help", args[0])
+	}
+
+	if cmd.direct {
+		return cmd.run(c.world, args[1:])
+	}
+
+	type result struct {
+		out string
+		err error
+	}
+
+	done := make(chan result, 1)
+	c.world.QueueCommand(func(w munfall.World) {
+		defer func() {
+			if reason := recover(); reason != nil {
+				done <- result{err: fmt.Errorf("command panicked: %v", reason)}
+			}
+		}()
+
+		out, err := cmd.run(w, args[1:])
+		done <- result{out: out, err: err}
+	})
+
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-time.After(c.timeout):
+		return "", errors.New("timed out waiting for the game loop, the command will still run when it ticks")
+	}
+}
+
+func (c *Console) help() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	names := make([]string, 0, len(c.commands))
+	for name := range c.commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = c.commands[name].usage
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package console
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/gridworldmap"
+	"github.com/bluemun/munfall/logic"
+	"github.com/op/go-logging"
+)
+
+type testTrait struct {
+	owner munfall.Actor
+	count int
+}
+
+func (t *testTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	t.owner = a
+	t.count = 7
+}
+
+func (t *testTrait) Owner() munfall.Actor {
+	return t.owner
+}
+
+func createTestConsole() (*Console, munfall.World, func()) {
+	ar := logic.CreateActorRegistry()
+	ar.RegisterTrait("Test", (*testTrait)(nil))
+	definition := logic.CreateActorDefinition("unit")
+	definition.AddTrait(logic.CreateTraitDefinition("Test"))
+	ar.RegisterActor(definition)
+
+	w := logic.CreateWorld(gridworldmap.CreateGridWorldMap(8, 8, 1, 1))
+	c := CreateConsole(w, ar)
+	c.SetTimeout(time.Second)
+
+	stop := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				w.Tick(1)
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	return c, w, func() { close(stop) }
+}
+
+func TestCommands(t *testing.T) {
+	c, w, stop := createTestConsole()
+	defer stop()
+
+	if err := c.Listen("tcp", "0.0.0.0:0"); err == nil {
+		c.Close()
+		t.Fatal("console listened on a public address")
+	}
+
+	out, err := c.Execute("spawn unit 3 4")
+	if err != nil || !strings.Contains(out, "spawned actor 0") {
+		t.Fatal(out, err)
+	}
+
+	if out, _ = c.Execute("list"); strings.Count(out, "\n") != 1 {
+		t.Errorf("expected a single actor, got %q", out)
+	}
+
+	out, err = c.Execute("inspect 0")
+	if err != nil || !strings.Contains(out, "count: 7") {
+		t.Errorf("unexported trait fields are missing: %q %v", out, err)
+	}
+
+	if out, err = c.Execute("kill 0"); err != nil || w.GetActor(0) != nil {
+		t.Error(out, err)
+	}
+
+	if _, err := c.Execute("nope"); err == nil {
+		t.Error("unknown command succeeded")
+	}
+}
+
+func TestLogLevelRunsOnGameLoop(t *testing.T) {
+	defer logging.SetLevel(logging.GetLevel(""), "")
+	w := logic.CreateWorld(gridworldmap.CreateGridWorldMap(8, 8, 1, 1))
+	c := CreateConsole(w, logic.CreateActorRegistry())
+	c.SetTimeout(10 * time.Millisecond)
+
+	if _, err := c.Execute("loglevel debug"); err == nil {
+		t.Fatal("loglevel ran without the game loop ticking")
+	}
+
+	if logging.GetLevel("") == logging.DEBUG {
+		t.Fatal("log level changed before the game loop ticked")
+	}
+
+	w.Tick(1)
+	if logging.GetLevel("") != logging.DEBUG {
+		t.Fatal("log level wasn't changed by the game loop")
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package continuousworldmap quadtree.go Defines the quadtree that indexes
+// the spaces on the map by their bounds.
+package continuousworldmap
+
+import (
+	"github.com/bluemun/munfall"
+)
+
+// quadNodeCapacity is the amount of spaces a node holds before it splits.
+const quadNodeCapacity = 8
+
+// quadMaxDepth limits how often nodes split, spaces stacked on top of each
+// other would split forever otherwise.
+const quadMaxDepth = 10
+
+type quadItem struct {
+	space     munfall.Space
+	low, high munfall.WPos
+}
+
+// quadNode holds the spaces that fit inside its box but not inside one of its
+// children, leaves have no children.
+type quadNode struct {
+	low, high munfall.WPos
+	depth     int
+	items     []quadItem
+	children  []*quadNode
+}
+
+// quadtree indexes spaces by their bounds, spaces that stick out of the tree
+// are kept in the root.
+type quadtree struct {
+	root  *quadNode
+	nodes map[munfall.Space]*quadNode
+}
+
+func createQuadtree(low, high munfall.WPos) *quadtree {
+	return &quadtree{
+		root:  &quadNode{low: low, high: high},
+		nodes: make(map[munfall.Space]*quadNode),
+	}
+}
+
+func (n *quadNode) contains(low, high *munfall.WPos) bool {
+	return low.X >= n.low.X && low.Y >= n.low.Y && high.X <= n.high.X && high.Y <= n.high.Y
+}
+
+func (n *quadNode) overlaps(low, high *munfall.WPos) bool {
+	return low.X <= n.high.X && high.X >= n.low.X && low.Y <= n.high.Y && high.Y >= n.low.Y
+}
+
+// insert adds the space by its current bounds.
+func (t *quadtree) insert(space munfall.Space) {
+	low, high := spaceBounds(space)
+	t.insertAt(t.root, quadItem{space: space, low: *low, high: *high})
+}
+
+// remove removes the space, it does nothing if the space isn't in the tree.
+func (t *quadtree) remove(space munfall.Space) {
+	node, exists := t.nodes[space]
+	if !exists {
+		return
+	}
+
+	delete(t.nodes, space)
+	for i, item := range node.items {
+		if item.space == space {
+			node.items = append(node.items[:i], node.items[i+1:]...)
+			return
+		}
+	}
+}
+
+// query calls f for every space whose bounds overlap the box between low and high.
+func (t *quadtree) query(low, high *munfall.WPos, f func(munfall.Space)) {
+	t.root.query(low, high, f)
+}
+
+func (n *quadNode) query(low, high *munfall.WPos, f func(munfall.Space)) {
+	for i := range n.items {
+		item := &n.items[i]
+		if item.low.X <= high.X && item.high.X >= low.X && item.low.Y <= high.Y && item.high.Y >= low.Y {
+			f(item.space)
+		}
+	}
+
+	for _, child := range n.children {
+		if child.overlaps(low, high) {
+			child.query(low, high, f)
+		}
+	}
+}
+
+func (n *quadNode) childContaining(low, high *munfall.WPos) *quadNode {
+	for _, child := range n.children {
+		if child.contains(low, high) {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// split gives the node four children and moves the spaces that fit inside
+// one of them down.
+func (t *quadtree) split(n *quadNode) {
+	mid := munfall.WPos{X: (n.low.X + n.high.X) / 2, Y: (n.low.Y + n.high.Y) / 2}
+	n.children = []*quadNode{
+		{low: n.low, high: mid, depth: n.depth + 1},
+		{low: munfall.WPos{X: mid.X, Y: n.low.Y}, high: munfall.WPos{X: n.high.X, Y: mid.Y}, depth: n.depth + 1},
+		{low: munfall.WPos{X: n.low.X, Y: mid.Y}, high: munfall.WPos{X: mid.X, Y: n.high.Y}, depth: n.depth + 1},
+		{low: mid, high: n.high, depth: n.depth + 1},
+	}
+
+	items := n.items
+	n.items = nil
+	for _, item := range items {
+		t.insertAt(n, item)
+	}
+}
+
+// insertAt adds the item to the node or the descendant that contains it.
+func (t *quadtree) insertAt(node *quadNode, item quadItem) {
+	for node.children != nil {
+		child := node.childContaining(&item.low, &item.high)
+		if child == nil {
+			break
+		}
+
+		node = child
+	}
+
+	node.items = append(node.items, item)
+	t.nodes[item.space] = node
+	if node.children == nil && len(node.items) > quadNodeCapacity && node.depth < quadMaxDepth {
+		t.split(node)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package continuousworldmap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// spawnRandom adds count circles and boxes of random sizes at random
+// positions on the map.
+func (m *testMap) spawnRandom(r *rand.Rand, count int) []munfall.Actor {
+	actors := make([]munfall.Actor, count)
+	for i := range actors {
+		parameters := map[string]interface{}{"Width": 1 + r.Float64()*6, "Height": 1 + r.Float64()*6}
+		if i%2 == 0 {
+			parameters = map[string]interface{}{"Radius": 0.5 + r.Float64()*4}
+		}
+
+		pos := munfall.WPos{X: r.Float32() * m.wm.Width(), Y: r.Float32() * m.wm.Height()}
+		actors[i] = m.spawn(pos, parameters)
+	}
+
+	return actors
+}
+
+// ids returns the sorted IDs of the actors.
+func ids(actors []munfall.Actor) []uint {
+	out := make([]uint, len(actors))
+	for i, a := range actors {
+		out[i] = a.ActorID()
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// checkQueries compares the queries of the map with checking every actor.
+func checkQueries(t *testing.T, m *testMap, r *rand.Rand, actors []munfall.Actor) {
+	t.Helper()
+	shapeOf := func(a munfall.Actor) traits.ShapedSpace {
+		return m.world.GetTrait(a, (*shapeTrait)(nil)).(*shapeTrait).spaces[0].(traits.ShapedSpace)
+	}
+
+	for i := 0; i < 50; i++ {
+		center := &munfall.WPos{X: r.Float32() * m.wm.Width(), Y: r.Float32() * m.wm.Height()}
+		radius := r.Float32() * 20
+		low := &munfall.WPos{X: center.X - radius, Y: center.Y - radius/2}
+		high := &munfall.WPos{X: center.X + radius, Y: center.Y + radius/2}
+
+		var inRadius, inRect []munfall.Actor
+		var nearest munfall.Actor
+		nearestDistance := float32(1e9)
+		for _, a := range actors {
+			if shapeOf(a).OverlapsCircle(center, radius) {
+				inRadius = append(inRadius, a)
+			}
+
+			if shapeOf(a).OverlapsBox(low, high) {
+				inRect = append(inRect, a)
+			}
+
+			if d := distanceSquared(a.Pos(), center); d < nearestDistance {
+				nearest, nearestDistance = a, d
+			}
+		}
+
+		if got, want := ids(m.wm.ActorsInRadius(center, radius, nil)), ids(inRadius); !equalIDs(got, want) {
+			t.Fatalf("radius %v around %v found %v, expected %v", radius, center, got, want)
+		}
+
+		if got, want := ids(m.wm.ActorsInRect(low, high, nil)), ids(inRect); !equalIDs(got, want) {
+			t.Fatalf("rect from %v to %v found %v, expected %v", low, high, got, want)
+		}
+
+		if got := m.wm.NearestActor(center, 1000, nil); got != nearest {
+			t.Fatalf("nearest actor to %v is %v, expected %v", center, got, nearest)
+		}
+	}
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestQueries(t *testing.T) {
+	m := createTestMap(CreateContinuousWorldMap(200, 100, 10))
+	r := rand.New(rand.NewSource(5))
+	actors := m.spawnRandom(r, 400)
+	checkQueries(t, m, r, actors)
+
+	for _, a := range actors {
+		to := &munfall.WPos{X: r.Float32() * 200, Y: r.Float32() * 100}
+		m.wm.Move(a, m.wm.GetPath(a, a.Pos(), to), 1)
+	}
+
+	checkQueries(t, m, r, actors)
+
+	for _, a := range actors[:200] {
+		a.Kill()
+	}
+
+	checkQueries(t, m, r, actors[200:])
+	if got := m.wm.ActorsInRect(&munfall.WPos{}, &munfall.WPos{X: 200, Y: 100}, nil); len(got) != 200 {
+		t.Errorf("the whole map holds %v actors, expected 200", len(got))
+	}
+}
+
+func TestNearestActor(t *testing.T) {
+	m := createTestMap(CreateContinuousWorldMap(200, 100, 10))
+	near := m.spawn(munfall.WPos{X: 50, Y: 50}, map[string]interface{}{"Radius": 1.0})
+	far := m.spawn(munfall.WPos{X: 190, Y: 90}, map[string]interface{}{"Radius": 1.0})
+
+	if got := m.wm.NearestActor(&munfall.WPos{X: 60, Y: 50}, 1000, nil); got != near {
+		t.Error("nearest actor is", got)
+	}
+
+	if got := m.wm.NearestActor(&munfall.WPos{X: 60, Y: 50}, 5, nil); got != nil {
+		t.Error("found an actor further away than the limit:", got)
+	}
+
+	notNear := func(a munfall.Actor) bool { return a != near }
+	if got := m.wm.NearestActor(&munfall.WPos{X: 60, Y: 50}, 1000, notNear); got != far {
+		t.Error("nearest actor passing the filter is", got)
+	}
+
+	if got := m.wm.NearestActor(&munfall.WPos{X: 50, Y: 50}, 0, nil); got != near {
+		t.Error("an actor right at the position wasn't found without a distance:", got)
+	}
+}
+
+func BenchmarkQueries(b *testing.B) {
+	m := createTestMap(CreateContinuousWorldMap(2000, 2000, 10))
+	r := rand.New(rand.NewSource(5))
+	actors := m.spawnRandom(r, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a := actors[i%len(actors)]
+		m.wm.ActorsInRadius(a.Pos(), 30, nil)
+		m.wm.Move(a, m.wm.GetPath(a, a.Pos(), &munfall.WPos{X: r.Float32() * 2000, Y: r.Float32() * 2000}), 0.01)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package continuousworldmap queries.go Defines spatial queries that find
+// actors by the shapes of their spaces.
+package continuousworldmap
+
+import (
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// ActorsInRadius returns every actor with a space that overlaps the circle,
+// only actors occupying space on the map can be found.
+func (wm *worldMapContinuous) ActorsInRadius(center *munfall.WPos, radius float32, filter munfall.ActorFilter) []munfall.Actor {
+	low := &munfall.WPos{X: center.X - radius, Y: center.Y - radius}
+	high := &munfall.WPos{X: center.X + radius, Y: center.Y + radius}
+	return wm.collect(low, high, func(space munfall.Space) bool {
+		return overlapsCircle(space, center, radius)
+	}, filter)
+}
+
+// ActorsInRect returns every actor with a space that overlaps the rectangle,
+// only actors occupying space on the map can be found.
+func (wm *worldMapContinuous) ActorsInRect(topLeft, bottomRight *munfall.WPos, filter munfall.ActorFilter) []munfall.Actor {
+	return wm.collect(topLeft, bottomRight, func(space munfall.Space) bool {
+		return overlapsBox(space, topLeft, bottomRight)
+	}, filter)
+}
+
+// ActorsInCells returns every actor with a space that overlaps one of the
+// given cells, cells outside of the map are ignored.
+func (wm *worldMapContinuous) ActorsInCells(cells []*munfall.MPos, filter munfall.ActorFilter) []munfall.Actor {
+	var out []munfall.Actor
+	seen := make(map[uint]bool)
+	for _, pos := range cells {
+		if !wm.InsideMapMPos(pos) {
+			continue
+		}
+
+		low, high := wm.cells[pos.X+pos.Y*wm.cols].bounds()
+		for _, a := range wm.collect(low, high, func(space munfall.Space) bool {
+			return overlapsBox(space, low, high)
+		}, filter) {
+			if !seen[a.ActorID()] {
+				seen[a.ActorID()] = true
+				out = append(out, a)
+			}
+		}
+	}
+
+	return out
+}
+
+// NearestActor returns the actor with the space offset closest to pos that is
+// accepted by the filter, the search area doubles until an actor is found or
+// it covers maxDistance, returns nil if none was found.
+func (wm *worldMapContinuous) NearestActor(pos *munfall.WPos, maxDistance float32, filter munfall.ActorFilter) munfall.Actor {
+	rejected := make(map[uint]bool)
+	for radius := wm.cellSize; ; radius *= 2 {
+		if radius > maxDistance {
+			radius = maxDistance
+		}
+
+		var best munfall.Actor
+		bestDistance := radius * radius
+		low := &munfall.WPos{X: pos.X - radius, Y: pos.Y - radius}
+		high := &munfall.WPos{X: pos.X + radius, Y: pos.Y + radius}
+		wm.tree.query(low, high, func(space munfall.Space) {
+			owner := space.Trait().Owner()
+			if rejected[owner.ActorID()] {
+				return
+			}
+
+			d := distanceSquared(space.Offset(), pos)
+			if d > bestDistance || (best != nil && d == bestDistance) {
+				return
+			}
+
+			if filter != nil && !filter(owner) {
+				rejected[owner.ActorID()] = true
+				return
+			}
+
+			best, bestDistance = owner, d
+		})
+
+		// Every space offset within radius overlaps the queried box.
+		if best != nil || radius >= maxDistance {
+			return best
+		}
+	}
+}
+
+// collect returns the owners of the spaces overlapping the box between low and
+// high that are accepted by both accept and filter, every actor is returned
+// at most once.
+func (wm *worldMapContinuous) collect(low, high *munfall.WPos, accept func(munfall.Space) bool, filter munfall.ActorFilter) []munfall.Actor {
+	var out []munfall.Actor
+	seen := make(map[uint]bool)
+	wm.tree.query(low, high, func(space munfall.Space) {
+		owner := space.Trait().Owner()
+		if seen[owner.ActorID()] || !accept(space) {
+			return
+		}
+
+		seen[owner.ActorID()] = true
+		if filter == nil || filter(owner) {
+			out = append(out, owner)
+		}
+	})
+
+	return out
+}
+
+// overlapsCircle returns if the space overlaps the circle, spaces without a
+// shape are treated as a point.
+func overlapsCircle(space munfall.Space, center *munfall.WPos, radius float32) bool {
+	if shaped, ok := space.(traits.ShapedSpace); ok {
+		return shaped.OverlapsCircle(center, radius)
+	}
+
+	return distanceSquared(space.Offset(), center) <= radius*radius
+}
+
+// overlapsBox returns if the space overlaps the box, spaces without a shape
+// are treated as a point.
+func overlapsBox(space munfall.Space, low, high *munfall.WPos) bool {
+	if shaped, ok := space.(traits.ShapedSpace); ok {
+		return shaped.OverlapsBox(low, high)
+	}
+
+	pos := space.Offset()
+	return pos.X >= low.X && pos.X <= high.X &&
+		pos.Y >= low.Y && pos.Y <= high.Y
+}
+
+func distanceSquared(a, b *munfall.WPos) float32 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
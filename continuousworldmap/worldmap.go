@@ -0,0 +1,329 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package continuousworldmap worldmap.go Defines a world map for actors that
+// move freely instead of from cell to cell.
+package continuousworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// ContinuousWorldMap is a world map where actors stand anywhere and occupy
+// the shapes of their spaces, SpaceCircle and SpaceBox spaces collide by their
+// shape and other spaces as a point. The map is divided into cells of
+// terrain, cells only hold terrain and don't restrict where actors stand.
+type ContinuousWorldMap interface {
+	munfall.WorldMap
+
+	// SetTerrain sets the terrain of a cell, cells start out as clear
+	// terrain that every movement class can pass at a cost of 1.
+	SetTerrain(pos *munfall.MPos, terrain *munfall.Terrain)
+
+	// CreatePath creates a path through the given positions.
+	CreatePath(positions []*munfall.WPos) munfall.Path
+}
+
+// clearTerrain is the terrain cells start out with.
+var clearTerrain = &munfall.Terrain{Name: "clear", Passable: true}
+
+type worldMapContinuous struct {
+	world         munfall.World
+	width, height float32
+	cellSize      float32
+	cols, rows    uint
+	cells         []*cellContinuous
+	tree          *quadtree
+}
+
+// CreateContinuousWorldMap creates a map of width by height, cellSize is the
+// size of the square cells that hold the terrain. All three have to be positive.
+func CreateContinuousWorldMap(width, height, cellSize float32) ContinuousWorldMap {
+	if !(width > 0 && height > 0 && cellSize > 0) {
+		munfall.Logger.Panic("A ContinuousWorldMap needs a positive width, height and cell size, got", width, height, cellSize)
+	}
+
+	wm := &worldMapContinuous{
+		width:    width,
+		height:   height,
+		cellSize: cellSize,
+		cols:     uint(math.Ceil(float64(width / cellSize))),
+		rows:     uint(math.Ceil(float64(height / cellSize))),
+		tree:     createQuadtree(munfall.WPos{}, munfall.WPos{X: width, Y: height}),
+	}
+
+	wm.cells = make([]*cellContinuous, wm.cols*wm.rows)
+	var init uint
+	for y := init; y < wm.rows; y++ {
+		for x := init; x < wm.cols; x++ {
+			wm.cells[x+y*wm.cols] = &cellContinuous{
+				wm:      wm,
+				pos:     &munfall.MPos{X: x, Y: y},
+				terrain: clearTerrain,
+			}
+		}
+	}
+
+	return wm
+}
+
+func (wm *worldMapContinuous) Width() float32 {
+	return wm.width
+}
+
+func (wm *worldMapContinuous) Height() float32 {
+	return wm.height
+}
+
+func (wm *worldMapContinuous) Initialize(world munfall.World) {
+	wm.world = world
+}
+
+func (wm *worldMapContinuous) CellAt(pos *munfall.MPos) munfall.Cell {
+	return wm.cells[pos.X+pos.Y*wm.cols]
+}
+
+func (wm *worldMapContinuous) SetTerrain(pos *munfall.MPos, terrain *munfall.Terrain) {
+	wm.cells[pos.X+pos.Y*wm.cols].terrain = terrain
+}
+
+func (wm *worldMapContinuous) InsideMapWPos(pos *munfall.WPos) bool {
+	return pos.X < wm.width && pos.X >= 0 &&
+		pos.Y < wm.height && pos.Y >= 0
+}
+
+func (wm *worldMapContinuous) InsideMapMPos(pos *munfall.MPos) bool {
+	return pos.X < wm.cols && pos.Y < wm.rows
+}
+
+func (wm *worldMapContinuous) ConvertToWPos(m *munfall.MPos) *munfall.WPos {
+	return &munfall.WPos{X: wm.cellSize * float32(m.X), Y: wm.cellSize * float32(m.Y)}
+}
+
+func (wm *worldMapContinuous) ConvertToMPos(w *munfall.WPos) *munfall.MPos {
+	clamp := func(v float32, size uint) uint {
+		if v < 0 {
+			return 0
+		}
+
+		if cell := uint(v / wm.cellSize); cell < size {
+			return cell
+		}
+
+		return size - 1
+	}
+
+	return &munfall.MPos{X: clamp(w.X, wm.cols), Y: clamp(w.Y, wm.rows)}
+}
+
+func (wm *worldMapContinuous) CreatePath(positions []*munfall.WPos) munfall.Path {
+	nodes := make([]pathContinuous, len(positions))
+	first, last := &nodes[0], &nodes[len(nodes)-1]
+	for i, pos := range positions {
+		copied := *pos
+		nodes[i] = pathContinuous{m: wm, first: first, last: last, pos: copied}
+		if i > 0 {
+			nodes[i-1].next = &nodes[i]
+		}
+	}
+
+	return first
+}
+
+// GetPath returns a straight line from p1 to p2, it stops short of the first
+// cell on the way whose terrain the actor can't pass. Actors are expected to
+// steer around each other while they follow it.
+func (wm *worldMapContinuous) GetPath(a munfall.Actor, p1, p2 *munfall.WPos) munfall.Path {
+	low := munfall.WPos{}
+	high := munfall.WPos{X: wm.width, Y: wm.height}
+	end := p2.Clamp(&low, &high)
+
+	class := traits.MovementClass(a)
+	dx, dy := end.X-p1.X, end.Y-p1.Y
+	length := float32(math.Sqrt(float64(dx*dx + dy*dy)))
+	// Half a cell steps never skip over a cell.
+	step := wm.cellSize / 2
+	for travelled := step; travelled < length+step; travelled += step {
+		if travelled > length {
+			travelled = length
+		}
+
+		pos := &munfall.WPos{X: p1.X + dx*travelled/length, Y: p1.Y + dy*travelled/length}
+		if !wm.CellAt(wm.ConvertToMPos(pos)).Terrain().CanPass(class) {
+			back := travelled - step
+			end = &munfall.WPos{X: p1.X + dx*back/length, Y: p1.Y + dy*back/length}
+			break
+		}
+	}
+
+	return wm.CreatePath([]*munfall.WPos{p1, end})
+}
+
+// Register adds the spaces of the actor's OccupySpace traits to the index.
+func (wm *worldMapContinuous) Register(a munfall.Actor) {
+	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil)) {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.tree.insert(space)
+		}
+	}
+}
+
+func (wm *worldMapContinuous) Move(a munfall.Actor, p munfall.Path, percent float32) {
+	path, exists := p.(*pathContinuous)
+	if !exists {
+		munfall.Logger.Panic("Tried using", p, "on a ContinuousWorldMap, it requires a *pathContinuous type.")
+	}
+
+	wm.setPos(a, path.WPos(percent))
+}
+
+// setPos moves the actor to the given position, updating the index, and moves
+// the actors attached to it along.
+func (wm *worldMapContinuous) setPos(a munfall.Actor, pos *munfall.WPos) {
+	old := a.Pos()
+	spacetraits := wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil))
+	for _, trait := range spacetraits {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.tree.remove(space)
+		}
+	}
+
+	a.SetPos(pos)
+
+	for _, trait := range spacetraits {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.tree.insert(space)
+		}
+	}
+
+	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.MoveNotifier)(nil)) {
+		trait.(traits.MoveNotifier).NotifyMove(old, a.Pos())
+	}
+
+	for _, child := range a.Children() {
+		if child.IsInWorld() {
+			wm.setPos(child, pos.Add(child.AttachOffset()))
+		} else {
+			child.SetPos(pos.Add(child.AttachOffset()))
+		}
+	}
+}
+
+func (wm *worldMapContinuous) Deregister(a munfall.Actor) {
+	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil)) {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.tree.remove(space)
+		}
+	}
+}
+
+// spaceBounds returns the box around the space, a point for spaces without a shape.
+func spaceBounds(space munfall.Space) (low, high *munfall.WPos) {
+	if shaped, ok := space.(traits.ShapedSpace); ok {
+		return shaped.Bounds()
+	}
+
+	return space.Offset(), space.Offset()
+}
+
+type pathContinuous struct {
+	m     *worldMapContinuous
+	first *pathContinuous
+	last  *pathContinuous
+
+	pos  munfall.WPos
+	next *pathContinuous
+}
+
+func (p *pathContinuous) Cell() munfall.Cell {
+	return p.m.CellAt(p.MPos())
+}
+
+func (p *pathContinuous) MPos() *munfall.MPos {
+	return p.m.ConvertToMPos(&p.pos)
+}
+
+// WPos returns the position the given part of the way to the next node, the
+// next node itself at 1.
+func (p *pathContinuous) WPos(percent float32) *munfall.WPos {
+	pos := p.pos
+	if p.next == nil {
+		return &pos
+	}
+
+	if percent >= 1 {
+		pos = p.next.pos
+		return &pos
+	}
+
+	offset := p.next.pos.Subtract(&p.pos)
+	offset.X *= percent
+	offset.Y *= percent
+	offset.Z *= percent
+	return pos.Add(offset)
+}
+
+func (p *pathContinuous) IsEnd() bool {
+	return p.next == nil
+}
+
+func (p *pathContinuous) Next() munfall.Path {
+	if p.next == nil {
+		return nil
+	}
+
+	return p.next
+}
+
+func (p *pathContinuous) First() munfall.Path {
+	return p.first
+}
+
+func (p *pathContinuous) Last() munfall.Path {
+	return p.last
+}
+
+type cellContinuous struct {
+	wm      *worldMapContinuous
+	pos     *munfall.MPos
+	terrain *munfall.Terrain
+}
+
+func (c *cellContinuous) Terrain() *munfall.Terrain {
+	return c.terrain
+}
+
+func (c *cellContinuous) AdjacentCells() []munfall.Cell {
+	cells := make([]munfall.Cell, 0, 4)
+	x, y := int(c.pos.X), int(c.pos.Y)
+	for _, d := range [...][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		nx, ny := x+d[0], y+d[1]
+		if nx >= 0 && ny >= 0 && nx < int(c.wm.cols) && ny < int(c.wm.rows) {
+			cells = append(cells, c.wm.cells[nx+ny*int(c.wm.cols)])
+		}
+	}
+
+	return cells
+}
+
+// Space returns the spaces that overlap the cell.
+func (c *cellContinuous) Space() []munfall.Space {
+	low, high := c.bounds()
+	var out []munfall.Space
+	c.wm.tree.query(low, high, func(space munfall.Space) {
+		if overlapsBox(space, low, high) {
+			out = append(out, space)
+		}
+	})
+
+	return out
+}
+
+func (c *cellContinuous) bounds() (low, high *munfall.WPos) {
+	low = c.wm.ConvertToWPos(c.pos)
+	return low, &munfall.WPos{X: low.X + c.wm.cellSize, Y: low.Y + c.wm.cellSize}
+}
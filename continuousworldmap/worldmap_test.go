@@ -0,0 +1,163 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package continuousworldmap
+
+import (
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+	"github.com/bluemun/munfall/traits"
+)
+
+// shapeTrait occupies a circle when the "Radius" parameter is set and a box
+// of "Width" by "Height" otherwise.
+type shapeTrait struct {
+	owner  munfall.Actor
+	spaces []munfall.Space
+}
+
+func (s *shapeTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	s.owner = a
+	var space munfall.Space
+	if radius, ok := parameters["Radius"].(float64); ok {
+		space = &traits.SpaceCircle{LocalOffset: &munfall.WPos{}, Radius: float32(radius)}
+	} else {
+		width, _ := parameters["Width"].(float64)
+		height, _ := parameters["Height"].(float64)
+		space = &traits.SpaceBox{LocalOffset: &munfall.WPos{}, Width: float32(width), Height: float32(height)}
+	}
+
+	space.Initialize(s)
+	s.spaces = []munfall.Space{space}
+}
+
+func (s *shapeTrait) Owner() munfall.Actor {
+	return s.owner
+}
+
+func (s *shapeTrait) Intersects(other traits.OccupySpace, offset *munfall.WPos) bool {
+	for _, a := range s.spaces {
+		for _, b := range other.Space() {
+			if a.Intersects(b, offset) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *shapeTrait) Space() []munfall.Space {
+	return s.spaces
+}
+
+func (s *shapeTrait) OutOfBounds(offset *munfall.WPos) bool {
+	return false
+}
+
+// testMap is a map in a world with a registry that creates "shape" actors.
+type testMap struct {
+	wm    ContinuousWorldMap
+	world munfall.World
+	ar    *logic.ActorRegistry
+}
+
+func createTestMap(wm ContinuousWorldMap) *testMap {
+	ar := logic.CreateActorRegistry()
+	ar.RegisterTrait("Shape", (*shapeTrait)(nil))
+	definition := logic.CreateActorDefinition("shape")
+	definition.AddTrait(logic.CreateTraitDefinition("Shape"))
+	ar.RegisterActor(definition)
+	return &testMap{wm: wm, world: logic.CreateWorld(wm), ar: ar}
+}
+
+// spawn adds a shape at the position to the world.
+func (m *testMap) spawn(pos munfall.WPos, parameters map[string]interface{}) munfall.Actor {
+	a := m.ar.CreateActor("shape", parameters, m.world, false)
+	a.SetPos(&pos)
+	m.world.AddToWorld(a)
+	return a
+}
+
+func TestInvalidSize(t *testing.T) {
+	for _, size := range [][3]float32{{0, 10, 1}, {10, -1, 1}, {10, 10, 0}, {10, 10, -2}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("a map of %v by %v with cells of %v was created", size[0], size[1], size[2])
+				}
+			}()
+
+			CreateContinuousWorldMap(size[0], size[1], size[2])
+		}()
+	}
+}
+
+func TestCells(t *testing.T) {
+	wm := CreateContinuousWorldMap(95, 40, 10)
+	if !wm.InsideMapMPos(&munfall.MPos{X: 9, Y: 3}) || wm.InsideMapMPos(&munfall.MPos{X: 10, Y: 3}) {
+		t.Error("the partial column at the right edge isn't a cell of the map")
+	}
+
+	if got := wm.ConvertToMPos(&munfall.WPos{X: 94, Y: 39}); *got != (munfall.MPos{X: 9, Y: 3}) {
+		t.Error("the bottom right corner is in cell", got)
+	}
+
+	if got := wm.ConvertToMPos(&munfall.WPos{X: -5, Y: 500}); *got != (munfall.MPos{X: 0, Y: 3}) {
+		t.Error("positions outside of the map aren't clamped:", got)
+	}
+
+	if len(wm.CellAt(&munfall.MPos{X: 3, Y: 2}).AdjacentCells()) != 4 || len(wm.CellAt(&munfall.MPos{}).AdjacentCells()) != 2 {
+		t.Error("cells don't have their neighbours")
+	}
+
+	m := createTestMap(wm)
+	a := m.spawn(munfall.WPos{X: 35, Y: 25}, map[string]interface{}{"Radius": 1.0})
+	if space := wm.CellAt(&munfall.MPos{X: 3, Y: 2}).Space(); len(space) != 1 || space[0].Trait().Owner() != a {
+		t.Error("the cell holds", space)
+	}
+}
+
+func TestPathStopsAtTerrain(t *testing.T) {
+	wm := CreateContinuousWorldMap(200, 100, 10)
+	water := &munfall.Terrain{Name: "water", MovementCosts: map[string]float32{"boat": 1}}
+	for y := uint(0); y < 10; y++ {
+		wm.SetTerrain(&munfall.MPos{X: 10, Y: y}, water)
+	}
+
+	end := wm.GetPath(nil, &munfall.WPos{X: 5, Y: 50}, &munfall.WPos{X: 195, Y: 50}).Last().WPos(0)
+	if end.X >= 100 || end.X < 90 {
+		t.Error("path across the water ends at", end)
+	}
+
+	end = wm.GetPath(nil, &munfall.WPos{X: 5, Y: 50}, &munfall.WPos{X: 50, Y: 50}).Last().WPos(0)
+	if *end != (munfall.WPos{X: 50, Y: 50}) {
+		t.Error("path on land ends at", end)
+	}
+
+	end = wm.GetPath(nil, &munfall.WPos{X: 5, Y: 50}, &munfall.WPos{X: 50, Y: 500}).Last().WPos(0)
+	if end.Y != 100 {
+		t.Error("path leaving the map ends at", end)
+	}
+}
+
+func TestMove(t *testing.T) {
+	m := createTestMap(CreateContinuousWorldMap(200, 100, 10))
+	a := m.spawn(munfall.WPos{X: 10, Y: 10}, map[string]interface{}{"Radius": 2.0})
+	to := &munfall.WPos{X: 150, Y: 80}
+	m.wm.Move(a, m.wm.GetPath(a, a.Pos(), to), 1)
+	if *a.Pos() != *to {
+		t.Fatal("moved to", a.Pos())
+	}
+
+	if got := m.wm.ActorsInRadius(&munfall.WPos{X: 10, Y: 10}, 5, nil); len(got) != 0 {
+		t.Error("the actor is still found at its old position")
+	}
+
+	if got := m.wm.ActorsInRadius(to, 1, nil); len(got) != 1 || got[0] != a {
+		t.Error("the actor isn't found at its new position")
+	}
+}
@@ -7,9 +7,7 @@ package munfall
 
 import (
 	"os"
-	"runtime/debug"
 
-	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/op/go-logging"
 )
 
@@ -30,13 +28,3 @@ func init() {
 	// Set the backends to be used.
 	logging.SetBackend(backend1Leveled)
 }
-
-// CheckGLError used to check and log any errors that happen in opengl calls.
-func CheckGLError() {
-	if Logger.IsEnabledFor(logging.DEBUG) {
-		if e := gl.GetError(); e != gl.NO_ERROR {
-			debug.PrintStack()
-			Logger.Critical("OpenGL error: ", e)
-		}
-	}
-}
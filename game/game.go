@@ -2,11 +2,13 @@
 // Use of this source code is governed by a MIT License
 // license that can be found in the LICENSE file.
 
-// Package game game.go Defines the struct used to connect all the engine components together.
+// Package game game.go Defines the struct used to connect all the engine components together,
+// games that run without a window use the headless package instead.
 package game
 
 import (
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/bluemun/munfall"
@@ -27,6 +29,8 @@ type Game struct {
 	renderer       render.RendersTraits
 	window         *graphics.Window
 	world          munfall.World
+	stop           chan struct{}
+	stopOnce       sync.Once
 }
 
 // Initialize initializes the game.
@@ -45,31 +49,30 @@ func (g *Game) Initialize(wm munfall.WorldMap) {
 	g.Camera.Activate()
 
 	g.world = logic.CreateWorld(wm)
+	g.stop = make(chan struct{})
 
 	// TODO: Change this once we got more renderers.
 	g.renderer = render.CreateRendersTraits2D(g.world)
 }
 
-// Start starts the game loop.
+// Start starts the game loop, ticking the world framerate times per second
+// until the window is closed or Stop is called.
 func (g *Game) Start(framerate int64) {
 	ticker := time.NewTicker(time.Second / (time.Duration)(framerate))
+	defer ticker.Stop()
 
 	for {
 		select {
+		case <-g.stop:
+			g.shutdown()
+			return
 		case <-ticker.C:
 			if g.window.Closed() {
-				ticker.Stop()
-				close(munfall.Mainfunc)
+				g.shutdown()
 				return
 			}
 
-			g.world.Tick(1.0 / (float32)(framerate))
-			g.window.PollEvents()
-			if g.orderGenerator != nil {
-				for _, order := range g.orderGenerator.GetOrders() {
-					g.world.IssueGlobalOrder(order)
-				}
-			}
+			g.Step(1.0 / (float32)(framerate))
 
 			g.window.Clear()
 			g.renderer.Render()
@@ -78,6 +81,28 @@ func (g *Game) Start(framerate int64) {
 	}
 }
 
+// Step advances the game by a single tick, it polls the window events and
+// issues the orders of the order generator after ticking the world.
+func (g *Game) Step(deltaUnit float32) {
+	g.world.Tick(deltaUnit)
+	g.window.PollEvents()
+
+	if g.orderGenerator != nil {
+		for _, order := range g.orderGenerator.GetOrders() {
+			g.world.IssueGlobalOrder(order)
+		}
+	}
+}
+
+// Stop makes the running game loop return, safe to call from any goroutine.
+func (g *Game) Stop() {
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+func (g *Game) shutdown() {
+	close(munfall.Mainfunc)
+}
+
 // SetOrderGenerator sets the current active order generator for the game.
 func (g *Game) SetOrderGenerator(og input.OrderGenerator) {
 	g.orderGenerator = og
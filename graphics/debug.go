@@ -0,0 +1,25 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package graphics debug.go Defines the OpenGL error checks, they live here so
+// the packages that don't render don't depend on OpenGL.
+package graphics
+
+import (
+	"runtime/debug"
+
+	"github.com/bluemun/munfall"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/op/go-logging"
+)
+
+// CheckGLError used to check and log any errors that happen in opengl calls.
+func CheckGLError() {
+	if munfall.Logger.IsEnabledFor(logging.DEBUG) {
+		if e := gl.GetError(); e != gl.NO_ERROR {
+			debug.PrintStack()
+			munfall.Logger.Critical("OpenGL error: ", e)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package render
 
 import (
 	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/graphics"
 	"github.com/bluemun/munfall/graphics/shader"
 	"github.com/go-gl/gl/v3.3-core/gl"
 )
@@ -72,41 +73,41 @@ func CreateRenderer2D(vertexBufferSize, indexBufferSize int) Renderer {
 		r.s = shader.CreateShader(vertexShader, fragmentShader)
 		r.s.Use()
 		gl.GenVertexArrays(1, &r.vertexArray)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BindVertexArray(r.vertexArray)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 
 		gl.GenBuffers(1, &r.vertexBuffer)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BindBuffer(gl.ARRAY_BUFFER, r.vertexBuffer)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BufferData(gl.ARRAY_BUFFER, (int)(10000*vertexSize*float32Size), nil, gl.DYNAMIC_DRAW)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 
 		point := r.s.GetAttributeLocation("vertex")
 		gl.EnableVertexAttribArray(point)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		munfall.Logger.Info("Vertex attribute vertex location: ", point)
 		gl.VertexAttribPointer(point, 3, gl.FLOAT, false, vertexSize*float32Size, gl.PtrOffset(0))
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 
 		color := r.s.GetAttributeLocation("color")
 		gl.EnableVertexAttribArray(color)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		munfall.Logger.Info("Vertex attribute color location: ", color)
 		gl.VertexAttribPointer(color, 1, gl.FLOAT, false, vertexSize*float32Size, gl.PtrOffset(3*float32Size))
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 
 		gl.GenBuffers(1, &r.indexBuffer)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, r.indexBuffer)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 
 		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, (int)(10000*int32Size), nil, gl.DYNAMIC_DRAW)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 
 		r.s.BindFragDataLocation("outputColor")
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 	})
 
 	return r
@@ -122,9 +123,9 @@ func (r *renderer2d) Begin() {
 		}
 
 		gl.BindBuffer(gl.ARRAY_BUFFER, r.vertexBuffer)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, r.indexBuffer)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 	})
 }
 
@@ -174,9 +175,9 @@ func (r *renderer2d) draw(vertices []float32, indices []uint32) {
 
 	munfall.Do(func() {
 		gl.BufferSubData(gl.ARRAY_BUFFER, (r.vertexOffset*vertexSize)*float32Size, len(vertices)*float32Size, gl.Ptr(vertices))
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BufferSubData(gl.ELEMENT_ARRAY_BUFFER, (r.indexOffset)*int32Size, len(indices)*int32Size, gl.Ptr(indices))
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 	})
 	r.vertexOffset += len(vertices) / vertexSize
 	r.indexOffset += len(indices)
@@ -186,11 +187,11 @@ func (r *renderer2d) draw(vertices []float32, indices []uint32) {
 func (r *renderer2d) Flush() {
 	munfall.Do(func() {
 		gl.BindVertexArray(r.vertexArray)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.DrawElements(gl.TRIANGLES, int32(r.indexOffset), gl.UNSIGNED_INT, nil)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BindVertexArray(0)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 	})
 }
 
@@ -198,8 +199,8 @@ func (r *renderer2d) Flush() {
 func (r *renderer2d) End() {
 	munfall.Do(func() {
 		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 		gl.BindBuffer(gl.ARRAY_BUFFER, 0)
-		munfall.CheckGLError()
+		graphics.CheckGLError()
 	})
 }
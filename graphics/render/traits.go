@@ -6,6 +6,8 @@
 package render
 
 import (
+	"sort"
+
 	"github.com/bluemun/munfall"
 	"github.com/bluemun/munfall/traits"
 )
@@ -29,10 +31,28 @@ func CreateRendersTraits2D(w munfall.World) RendersTraits {
 func (r *renderTraits2d) Render() {
 	ptraits := r.world.GetAllTraitsImplementing((*traits.TraitRender2D)(nil))
 	r.renderer.Begin()
+	profiler := r.world.Profiler()
+	sorter, sorted := r.world.WorldMap().(munfall.DepthSorter)
+	var renderables []munfall.Renderable
 	for _, trait := range ptraits {
+		sample := profiler.Begin()
 		for _, renderable := range trait.(traits.TraitRender2D).Render2D() {
-			r.renderer.Submit(renderable)
+			if sorted {
+				renderables = append(renderables, renderable)
+			} else {
+				r.renderer.Submit(renderable)
+			}
 		}
+		profiler.End("Render2D", trait, sample)
+	}
+
+	// Renderables with the same depth keep the order their traits gave them.
+	sort.SliceStable(renderables, func(i, j int) bool {
+		return sorter.DrawDepth(renderables[i].Pos()) < sorter.DrawDepth(renderables[j].Pos())
+	})
+
+	for _, renderable := range renderables {
+		r.renderer.Submit(renderable)
 	}
 	r.renderer.Flush()
 	r.renderer.End()
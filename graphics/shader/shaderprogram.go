@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/graphics"
 	"github.com/go-gl/gl/v3.3-core/gl"
 )
 
@@ -85,12 +86,12 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 // Use Sets this shader as the active one.
 func (s *Shader) Use() {
 	gl.UseProgram((uint32)(*s))
-	munfall.CheckGLError()
+	graphics.CheckGLError()
 }
 
 // GetAttributeLocation Gets the location of the variable name in the shader.
 func (s *Shader) GetAttributeLocation(name string) uint32 {
-	defer munfall.CheckGLError()
+	defer graphics.CheckGLError()
 	return uint32(gl.GetAttribLocation((uint32)(*s), gl.Str(name+"\x00")))
 }
 
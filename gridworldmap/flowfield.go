@@ -0,0 +1,185 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package gridworldmap flowfield.go Defines flow fields that lead every cell
+// of the map to a single goal, shared by all actors moving there.
+package gridworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+)
+
+// flowFieldCacheSize is the amount of flow fields a map keeps around.
+const flowFieldCacheSize = 16
+
+// occupiedCostFactor multiplies the cost of entering a cell that is occupied
+// by an actor, crowds flow around each other instead of being walled off.
+const occupiedCostFactor = 4
+
+// FlowField holds the cost of reaching the goal from every cell of the map
+// together with the cell to move to next. Fields are shared by every actor of
+// a movement class moving to the same goal and are recomputed when terrain,
+// blocked or occupied cells change. Fields don't know about layers, a cell
+// counts as occupied by spaces on any layer.
+type FlowField struct {
+	wm    *worldMap2DGrid
+	goal  int
+	class string
+	// passability, occupancy and tick are the versions of the map the field was computed at.
+	passability uint64
+	occupancy   uint64
+	tick        uint64
+	cost        []float32
+	next        []int32
+	open        cellmap.OpenSet
+}
+
+// FlowField returns the flow field leading to the cell of the goal, fields
+// are cached by goal cell and movement class and brought up to date when
+// they are requested. Changes to terrain and blocked cells are picked up right
+// away, actors moving between cells at most once per world tick so crowds
+// don't make the field recompute for every request.
+func (wm *worldMap2DGrid) FlowField(goal *munfall.WPos, movementClass string) *FlowField {
+	mpos := wm.ConvertToMPos(goal)
+	index := int(mpos.X + mpos.Y*wm.width)
+	for i, field := range wm.flowFields {
+		if field.goal == index && field.class == movementClass {
+			copy(wm.flowFields[1:i+1], wm.flowFields[:i])
+			wm.flowFields[0] = field
+			if field.passability != wm.passability || (field.occupancy != wm.occupancy && field.tick != wm.ticks) {
+				field.Update()
+			}
+
+			return field
+		}
+	}
+
+	field := &FlowField{
+		wm:    wm,
+		goal:  index,
+		class: movementClass,
+		cost:  make([]float32, len(wm.grid)),
+		next:  make([]int32, len(wm.grid)),
+	}
+	field.Update()
+
+	if len(wm.flowFields) < flowFieldCacheSize {
+		wm.flowFields = append(wm.flowFields, nil)
+	}
+
+	copy(wm.flowFields[1:], wm.flowFields)
+	wm.flowFields[0] = field
+	return field
+}
+
+// occupancyChanged is called whenever a space is added to or removed from a cell.
+func (wm *worldMap2DGrid) occupancyChanged() {
+	wm.occupancy++
+}
+
+// Goal returns the goal cell of the field.
+func (f *FlowField) Goal() *munfall.MPos {
+	return f.wm.grid[f.goal].pos
+}
+
+// IsValid returns false when blocked or occupied cells changed since the
+// field was computed.
+func (f *FlowField) IsValid() bool {
+	return f.passability == f.wm.passability && f.occupancy == f.wm.occupancy
+}
+
+// Cost returns the cost of moving from the cell to the goal, +Inf if the goal
+// can't be reached from it.
+func (f *FlowField) Cost(pos *munfall.MPos) float32 {
+	return f.cost[pos.X+pos.Y*f.wm.width]
+}
+
+// Next returns the cell to move to from the given cell, nil at the goal or
+// when the goal can't be reached.
+func (f *FlowField) Next(pos *munfall.MPos) *munfall.MPos {
+	next := f.next[pos.X+pos.Y*f.wm.width]
+	if next < 0 {
+		return nil
+	}
+
+	return f.wm.grid[next].pos
+}
+
+// Direction returns the normalized direction to move in from the given
+// position, a zero vector at the goal or when the goal can't be reached.
+func (f *FlowField) Direction(pos *munfall.WPos) *munfall.WPos {
+	next := f.Next(f.wm.ConvertToMPos(pos))
+	if next == nil {
+		return &munfall.WPos{}
+	}
+
+	dir := f.wm.ConvertToWPos(next).Subtract(f.wm.ConvertToWPos(f.wm.ConvertToMPos(pos)))
+	length := float32(math.Sqrt(float64(dir.X*dir.X + dir.Y*dir.Y)))
+	dir.X /= length
+	dir.Y /= length
+	return dir
+}
+
+// Path returns a path that follows the field from the given position to the
+// goal at the height of from, it only contains the start when the goal can't
+// be reached.
+func (f *FlowField) Path(from *munfall.WPos) munfall.Path {
+	mpos := f.wm.ConvertToMPos(from)
+	i := int32(mpos.X + mpos.Y*f.wm.width)
+	cells := []*cell2DRectGrid{f.wm.grid[i]}
+	for i = f.next[i]; i >= 0; i = f.next[i] {
+		cells = append(cells, f.wm.grid[i])
+	}
+
+	return f.wm.createPath(cells, from.Z)
+}
+
+// Update recomputes the field from the current terrain, blocked and occupied cells.
+func (f *FlowField) Update() {
+	wm := f.wm
+	for i := range f.cost {
+		f.cost[i] = float32(math.Inf(1))
+		f.next[i] = -1
+	}
+
+	f.passability, f.occupancy, f.tick = wm.passability, wm.occupancy, wm.ticks
+	if !wm.grid[f.goal].Passable(f.class) {
+		return
+	}
+
+	graph := searchGraph{wm}
+	f.cost[f.goal] = 0
+	open := append(f.open[:0], cellmap.OpenEntry{Index: f.goal})
+	for len(open) > 0 {
+		e := open.Pop()
+		if e.F > f.cost[e.Index] {
+			continue
+		}
+
+		// Walking from a neighbour into this cell costs more when it's occupied.
+		factor := wm.grid[e.Index].terrain.MovementCost(f.class)
+		if e.Index != f.goal && len(wm.grid[e.Index].Space()) > 0 {
+			factor *= occupiedCostFactor
+		}
+
+		// Fields step between the same 4 neighbours as GetPath, so actors
+		// following a field never cut across the corners of blocked cells.
+		graph.Neighbours(e.Index, func(next int, step float32) {
+			if !wm.grid[next].Passable(f.class) {
+				return
+			}
+
+			if c := e.F + step*factor; c < f.cost[next] {
+				f.cost[next] = c
+				f.next[next] = int32(e.Index)
+				open.Push(cellmap.OpenEntry{Index: next, F: c})
+			}
+		})
+	}
+
+	f.open = open[:0]
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bluemun/munfall"
+)
+
+func TestFlowFieldAroundWall(t *testing.T) {
+	m := createWalledMap()
+	goal := &munfall.WPos{X: 8, Y: 1}
+	f := m.wm.FlowField(goal, "")
+	if f != m.wm.FlowField(goal, "") {
+		t.Fatal("flow field wasn't cached")
+	}
+
+	cells := cellsOf(f.Path(&munfall.WPos{X: 1, Y: 1}))
+	for _, c := range cells {
+		if m.wm.IsCellBlocked(&c) {
+			t.Fatalf("path goes through blocked cell %v", c)
+		}
+	}
+
+	if end := cells[len(cells)-1]; end != *f.Goal() {
+		t.Errorf("path ends at %v", end)
+	}
+
+	if !math.IsInf(float64(f.Cost(&munfall.MPos{X: 5, Y: 0})), 1) {
+		t.Error("blocked cell has a finite cost")
+	}
+
+	if dir := f.Direction(&munfall.WPos{X: 8, Y: 3}); dir.X != 0 || dir.Y != -1 {
+		t.Errorf("expected to move straight to the goal, got %v", dir)
+	}
+
+	m.wm.SetCellBlocked(&munfall.MPos{X: 5, Y: 9}, true)
+	m.wm.FlowField(goal, "")
+	if f.Next(&munfall.MPos{X: 1, Y: 1}) != nil {
+		t.Error("closing the gap right away left the goal reachable")
+	}
+}
+
+func TestFlowFieldOccupancy(t *testing.T) {
+	m := createWalledMap()
+	goal := &munfall.WPos{X: 8, Y: 1}
+	f := m.wm.FlowField(goal, "")
+	before := f.Cost(&munfall.MPos{X: 1, Y: 1})
+
+	a := m.spawn(munfall.WPos{X: 5, Y: 9}, nil)
+	if f.IsValid() {
+		t.Fatal("field stayed valid after a cell on the way was occupied")
+	}
+
+	// Occupancy changes are only picked up once per tick.
+	m.wm.FlowField(goal, "")
+	if f.IsValid() {
+		t.Fatal("field was recomputed twice in the same tick")
+	}
+
+	m.world.Tick(1)
+	m.wm.FlowField(goal, "")
+	if !f.IsValid() || f.Cost(&munfall.MPos{X: 1, Y: 1}) <= before {
+		t.Fatalf("occupied gap didn't raise the cost, %v before and %v after", before, f.Cost(&munfall.MPos{X: 1, Y: 1}))
+	}
+
+	// Moving inside the same cell doesn't change the occupancy.
+	m.wm.Move(a, m.wm.CreatePath([]*munfall.WPos{{X: 5, Y: 9}, {X: 5.4, Y: 9.4}}), 1)
+	if !f.IsValid() {
+		t.Error("moving inside a cell invalidated the field")
+	}
+
+	m.wm.Move(a, m.wm.CreatePath([]*munfall.WPos{{X: 5.4, Y: 9.4}, {X: 6, Y: 9}}), 1)
+	if f.IsValid() {
+		t.Error("moving to another cell left the field valid")
+	}
+}
+
+func BenchmarkFlowFieldCrowd(b *testing.B) {
+	m := createTestMap(CreateGridWorldMap(256, 256, 1, 1))
+	var actors []munfall.Actor
+	for i := 0; i < 500; i++ {
+		actors = append(actors, m.spawn(munfall.WPos{X: float32(i % 200), Y: float32(i / 200 * 2)}, nil))
+	}
+
+	goal := &munfall.WPos{X: 250, Y: 250}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Every actor asks for the field and moves, once per tick.
+		for _, a := range actors {
+			f := m.wm.FlowField(goal, "")
+			if next := f.Next(m.wm.ConvertToMPos(a.Pos())); next != nil {
+				m.wm.Move(a, m.wm.CreatePath([]*munfall.WPos{a.Pos(), m.wm.ConvertToWPos(next)}), 0.25)
+			}
+		}
+
+		m.world.Tick(1)
+	}
+}
+
+func TestFlowFieldStepsLikeGetPath(t *testing.T) {
+	m := createWalledMap()
+	goal := &munfall.WPos{X: 8, Y: 1}
+	f := m.wm.FlowField(goal, "")
+	for y := uint(0); y < 10; y++ {
+		for x := uint(0); x < 10; x++ {
+			pos := munfall.MPos{X: x, Y: y}
+			next := f.Next(&pos)
+			if next == nil {
+				continue
+			}
+
+			dx, dy := int(next.X)-int(x), int(next.Y)-int(y)
+			if dx*dx+dy*dy != 1 {
+				t.Fatalf("field steps from %v to %v", pos, *next)
+			}
+		}
+	}
+
+	from := &munfall.WPos{X: 1, Y: 1}
+	if got, want := len(cellsOf(f.Path(from))), len(cellsOf(m.wm.GetPath(nil, from, goal))); got != want {
+		t.Errorf("field path has %v cells, GetPath %v", got, want)
+	}
+}
@@ -0,0 +1,608 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package gridworldmap hierarchy.go Defines a hierarchical path search that
+// plans over clusters of cells before refining the path cell by cell.
+package gridworldmap
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+)
+
+// hierarchy splits the map into square clusters connected by entrances on
+// their borders for a single movement class. Only blocked cells and terrain
+// are considered while planning over the clusters, actors are routed around
+// when the path is refined.
+type hierarchy struct {
+	wm           *worldMap2DGrid
+	class        string
+	size         uint
+	across, down uint
+	clusters     []*cluster
+	dirty        []bool
+	anyDirty     bool
+	// entrances holds the entrance cells of every cluster with their edges
+	// to each other, it is the graph searched by findRoute.
+	entrances []entrance
+	edges     []clusterEdge
+	search    *cellmap.Search
+	// dist, costs and open are reused by clusterDistances, costs holds the
+	// cost of entering every cell of costsOf and -1 for blocked cells.
+	dist    []float32
+	costs   []float32
+	costsOf *cluster
+	open    cellmap.OpenSet
+}
+
+// cluster holds the entrance cells of a part of the map, inter edges lead to
+// entrance cells of the neighbouring clusters by their grid index. Intra edges
+// lead to the other entrance cells of the cluster by their position in nodes
+// and are stored in the same order as nodes.
+type cluster struct {
+	x0, y0, x1, y1 uint
+	inter          map[int][]clusterEdge
+	intra          [][]clusterEdge
+	// nodes holds the entrance cells in grid order, their entrances start
+	// at first in the graph.
+	nodes []int
+	first int
+}
+
+// entrance is an entrance cell in the graph over the clusters, its edges lead
+// to other entrances by their position in the graph.
+type entrance struct {
+	cell    int
+	x, y    float32
+	cluster int
+	local   int
+	edges   []clusterEdge
+}
+
+type clusterEdge struct {
+	to   int
+	cost float32
+}
+
+func createHierarchy(wm *worldMap2DGrid, size uint, class string) *hierarchy {
+	h := &hierarchy{
+		wm:     wm,
+		class:  class,
+		size:   size,
+		across: (wm.width + size - 1) / size,
+		down:   (wm.height + size - 1) / size,
+		dist:   make([]float32, size*size),
+		costs:  make([]float32, size*size),
+	}
+
+	for y := uint(0); y < h.down; y++ {
+		for x := uint(0); x < h.across; x++ {
+			c := &cluster{x0: x * size, y0: y * size, x1: (x + 1) * size, y1: (y + 1) * size}
+			if c.x1 > wm.width {
+				c.x1 = wm.width
+			}
+			if c.y1 > wm.height {
+				c.y1 = wm.height
+			}
+
+			c.inter = make(map[int][]clusterEdge)
+			h.clusters = append(h.clusters, c)
+		}
+	}
+
+	h.dirty = make([]bool, len(h.clusters))
+	for i := range h.dirty {
+		h.dirty[i] = true
+	}
+
+	h.anyDirty = true
+	return h
+}
+
+func (h *hierarchy) clusterOf(pos *munfall.MPos) int {
+	return int(pos.X/h.size + pos.Y/h.size*h.across)
+}
+
+func (h *hierarchy) clusterOfIndex(i int) int {
+	x, y := uint(i)%h.wm.width, uint(i)/h.wm.width
+	return int(x/h.size + y/h.size*h.across)
+}
+
+// markDirty makes the cluster of the cell rebuild before the next search.
+func (h *hierarchy) markDirty(pos *munfall.MPos) {
+	h.dirty[h.clusterOf(pos)] = true
+	h.anyDirty = true
+	h.costsOf = nil
+}
+
+// isFar returns if a search between the cells should go over the clusters,
+// shorter searches are cheaper on the cells directly.
+func (h *hierarchy) isFar(start, goal *cell2DRectGrid) bool {
+	dx := int(start.pos.X) - int(goal.pos.X)
+	dy := int(start.pos.Y) - int(goal.pos.Y)
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+
+	return uint(dx+dy) > 2*h.size
+}
+
+// update rebuilds the borders of the dirty clusters and the intra edges of
+// every cluster whose entrances could have changed.
+func (h *hierarchy) update() {
+	if !h.anyDirty {
+		return
+	}
+
+	affected := make([]bool, len(h.clusters))
+	for i, dirty := range h.dirty {
+		if !dirty {
+			continue
+		}
+
+		affected[i] = true
+		x, y := uint(i)%h.across, uint(i)/h.across
+		if x+1 < h.across {
+			h.buildBorder(i, i+1, true)
+			affected[i+1] = true
+		}
+		if x > 0 && !h.dirty[i-1] {
+			h.buildBorder(i-1, i, true)
+			affected[i-1] = true
+		}
+		if y+1 < h.down {
+			h.buildBorder(i, i+int(h.across), false)
+			affected[i+int(h.across)] = true
+		}
+		if y > 0 && !h.dirty[i-int(h.across)] {
+			h.buildBorder(i-int(h.across), i, false)
+			affected[i-int(h.across)] = true
+		}
+	}
+
+	for i := range h.clusters {
+		if affected[i] {
+			h.buildIntra(i)
+		}
+
+		h.dirty[i] = false
+	}
+
+	h.buildGraph()
+	h.anyDirty = false
+}
+
+// buildGraph collects the entrances of every cluster and their edges into
+// the graph searched by findRoute.
+func (h *hierarchy) buildGraph() {
+	h.entrances = h.entrances[:0]
+	for i, c := range h.clusters {
+		c.first = len(h.entrances)
+		for _, cell := range c.nodes {
+			pos := h.wm.grid[cell].pos
+			h.entrances = append(h.entrances, entrance{
+				cell:    cell,
+				x:       float32(pos.X),
+				y:       float32(pos.Y),
+				cluster: i,
+				local:   h.localIndex(c, cell),
+			})
+		}
+	}
+
+	// The edges of every entrance share one slice.
+	edges := h.edges[:0]
+	for k := range h.entrances {
+		e := &h.entrances[k]
+		c := h.clusters[e.cluster]
+		first := len(edges)
+		for _, edge := range c.intra[k-c.first] {
+			edges = append(edges, clusterEdge{to: c.first + edge.to, cost: edge.cost})
+		}
+		for _, edge := range c.inter[e.cell] {
+			other := h.clusters[h.clusterOfIndex(edge.to)]
+			edges = append(edges, clusterEdge{to: h.entranceID(other, edge.to), cost: edge.cost})
+		}
+
+		e.edges = edges[first:len(edges):len(edges)]
+	}
+
+	h.edges = edges
+	h.search = cellmap.CreateSearch(len(h.entrances) + 2)
+}
+
+// entranceID returns the position of an entrance cell of the cluster in the
+// graph.
+func (h *hierarchy) entranceID(c *cluster, cell int) int {
+	return c.first + sort.SearchInts(c.nodes, cell)
+}
+
+// buildBorder finds the entrances between cluster a and the cluster b to its
+// right, or below it when horizontal is false.
+func (h *hierarchy) buildBorder(a, b int, horizontal bool) {
+	ca, cb := h.clusters[a], h.clusters[b]
+	h.removeInter(ca, b)
+	h.removeInter(cb, a)
+
+	wm := h.wm
+	first, last, cost := ca.x0, ca.x1, wm.cHeight
+	if horizontal {
+		first, last, cost = ca.y0, ca.y1, wm.cWidth
+	}
+
+	pair := func(k uint) (int, int) {
+		if horizontal {
+			return int(ca.x1 - 1 + k*wm.width), int(cb.x0 + k*wm.width)
+		}
+
+		return int(k + (ca.y1-1)*wm.width), int(k + cb.y0*wm.width)
+	}
+
+	connect := func(k uint) {
+		ia, ib := pair(k)
+		ca.inter[ia] = append(ca.inter[ia], clusterEdge{to: ib, cost: cost * wm.grid[ib].terrain.MovementCost(h.class)})
+		cb.inter[ib] = append(cb.inter[ib], clusterEdge{to: ia, cost: cost * wm.grid[ia].terrain.MovementCost(h.class)})
+	}
+
+	runStart := -1
+	for k := first; k <= last; k++ {
+		if k < last {
+			ia, ib := pair(k)
+			if wm.grid[ia].Passable(h.class) && wm.grid[ib].Passable(h.class) {
+				if runStart < 0 {
+					runStart = int(k)
+				}
+				continue
+			}
+		}
+
+		if runStart < 0 {
+			continue
+		}
+
+		// Long entrances get a transition at each end so paths along the
+		// border don't detour through the middle.
+		from, to := uint(runStart), k-1
+		if to-from+1 >= 6 {
+			connect(from)
+			connect(to)
+		} else {
+			connect((from + to) / 2)
+		}
+
+		runStart = -1
+	}
+}
+
+func (h *hierarchy) removeInter(c *cluster, other int) {
+	for cell, edges := range c.inter {
+		kept := edges[:0]
+		for _, edge := range edges {
+			if h.clusterOfIndex(edge.to) != other {
+				kept = append(kept, edge)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(c.inter, cell)
+		} else {
+			c.inter[cell] = kept
+		}
+	}
+}
+
+// buildIntra connects the entrance cells of a cluster that can reach each other.
+func (h *hierarchy) buildIntra(i int) {
+	c := h.clusters[i]
+	c.nodes = c.entrances()
+	c.intra = make([][]clusterEdge, len(c.nodes))
+	local := make([]int, len(c.nodes))
+	for k, cell := range c.nodes {
+		local[k] = h.localIndex(c, cell)
+	}
+
+	for from, cell := range c.nodes {
+		dist := h.clusterDistances(c, cell, false)
+		for to := range c.nodes {
+			if d := dist[local[to]]; to != from && !math.IsInf(float64(d), 1) {
+				c.intra[from] = append(c.intra[from], clusterEdge{to: to, cost: d})
+			}
+		}
+	}
+}
+
+// entrances returns the entrance cells of the cluster in grid order.
+func (c *cluster) entrances() []int {
+	nodes := make([]int, 0, len(c.inter))
+	for cell := range c.inter {
+		nodes = append(nodes, cell)
+	}
+
+	sort.Ints(nodes)
+	return nodes
+}
+
+func (h *hierarchy) localIndex(c *cluster, i int) int {
+	x, y := uint(i)%h.wm.width, uint(i)/h.wm.width
+	return int(x - c.x0 + (y-c.y0)*(c.x1-c.x0))
+}
+
+// clusterDistances returns the distance from the cell to every cell of the
+// cluster without leaving it, indexed by localIndex. With reverse set the
+// distances are from every cell to the given cell instead. The returned slice
+// is reused by the next call.
+func (h *hierarchy) clusterDistances(c *cluster, from int, reverse bool) []float32 {
+	wm := h.wm
+	w, ht := int(c.x1-c.x0), int(c.y1-c.y0)
+	dist := h.dist[:w*ht]
+	for i := range dist {
+		dist[i] = float32(math.Inf(1))
+	}
+
+	costs := h.costs[:w*ht]
+	if h.costsOf != c {
+		for local := range costs {
+			cell := wm.grid[int(c.x0)+local%w+(int(c.y0)+local/w)*int(wm.width)]
+			costs[local] = -1
+			if cell.Passable(h.class) {
+				costs[local] = cell.terrain.MovementCost(h.class)
+			}
+		}
+
+		h.costsOf = c
+	}
+
+	// Square cells on terrain that costs the same everywhere make every step
+	// cost the same, a breadth first search finds the distances without the heap.
+	uniform := wm.cWidth == wm.cHeight && wm.terrains.UniformCosts(h.class)
+	origin := h.localIndex(c, from)
+	dist[origin] = 0
+	open := append(h.open[:0], cellmap.OpenEntry{Index: origin})
+	for head := 0; head < len(open); {
+		var e cellmap.OpenEntry
+		if uniform {
+			e = open[head]
+			head++
+		} else {
+			e = open.Pop()
+			if e.F > dist[e.Index] {
+				continue
+			}
+		}
+
+		x, y := e.Index%w, e.Index/w
+		for dir := 0; dir < 4; dir++ {
+			next, cost := e.Index, wm.cWidth
+			switch dir {
+			case 0:
+				if x == 0 {
+					continue
+				}
+				next--
+			case 1:
+				if x == w-1 {
+					continue
+				}
+				next++
+			case 2:
+				if y == 0 {
+					continue
+				}
+				next, cost = next-w, wm.cHeight
+			case 3:
+				if y == ht-1 {
+					continue
+				}
+				next, cost = next+w, wm.cHeight
+			}
+
+			if costs[next] < 0 {
+				continue
+			}
+
+			// Costs are paid for the cell that is entered.
+			entered := next
+			if reverse {
+				entered = e.Index
+			}
+
+			if d := e.F + cost*costs[entered]; d < dist[next] {
+				dist[next] = d
+				if uniform {
+					open = append(open, cellmap.OpenEntry{Index: next, F: d})
+				} else {
+					open.Push(cellmap.OpenEntry{Index: next, F: d})
+				}
+			}
+		}
+	}
+
+	h.open = open[:0]
+	return dist
+}
+
+// findRoute plans a route over the cluster entrances, it returns the cells the
+// path has to pass through starting with start and ending with goal. If the
+// goal can't be reached the route leads to the entrance closest to it, nil is
+// returned if the start can't leave its cluster.
+func (h *hierarchy) findRoute(start, goal *cell2DRectGrid) []*cell2DRectGrid {
+	h.update()
+	wm := h.wm
+	si := int(start.pos.X + start.pos.Y*wm.width)
+	gi := int(goal.pos.X + goal.pos.Y*wm.width)
+	cs, cgi := h.clusters[h.clusterOf(start.pos)], h.clusterOf(goal.pos)
+	cg := h.clusters[cgi]
+
+	// The start and goal are added to the graph after the entrances.
+	startID, goalID := len(h.entrances), len(h.entrances)+1
+	var startEdges []clusterEdge
+	dist := h.clusterDistances(cs, si, false)
+	for k, node := range cs.nodes {
+		if d := dist[h.localIndex(cs, node)]; !math.IsInf(float64(d), 1) {
+			startEdges = append(startEdges, clusterEdge{to: cs.first + k, cost: d})
+		}
+	}
+
+	if cs == cg {
+		if d := dist[h.localIndex(cs, gi)]; !math.IsInf(float64(d), 1) {
+			startEdges = append(startEdges, clusterEdge{to: goalID, cost: d})
+		}
+	}
+
+	if len(startEdges) == 0 {
+		return nil
+	}
+
+	// goalDist stays valid until clusterDistances is called again.
+	goalDist := h.clusterDistances(cg, gi, true)
+	scale := wm.terrains.MinMovementCost(h.class)
+	gx, gy := float32(goal.pos.X), float32(goal.pos.Y)
+	heuristic := func(x, y float32) float32 {
+		dx, dy := x-gx, y-gy
+		if dx < 0 {
+			dx = -dx
+		}
+		if dy < 0 {
+			dy = -dy
+		}
+
+		return (dx*wm.cWidth + dy*wm.cHeight) * scale
+	}
+
+	s := h.search
+	s.Reset()
+	var current int
+	visit := func(to int, cost float32) {
+		nn := s.Node(to)
+		if nn.Closed {
+			return
+		}
+
+		if g := s.Node(current).G + cost; g < nn.G {
+			nn.G = g
+			nn.Parent = current
+			var hc float32
+			if to != goalID {
+				hc = heuristic(h.entrances[to].x, h.entrances[to].y)
+			}
+
+			s.Open.Push(cellmap.OpenEntry{Index: to, F: g + hc, H: hc})
+		}
+	}
+
+	best, bestH := startID, heuristic(float32(start.pos.X), float32(start.pos.Y))
+	s.Node(startID).G = 0
+	s.Open.Push(cellmap.OpenEntry{Index: startID, F: bestH, H: bestH})
+	for len(s.Open) > 0 {
+		e := s.Open.Pop()
+		n := s.Node(e.Index)
+		if n.Closed {
+			continue
+		}
+
+		n.Closed = true
+		if e.Index == goalID {
+			best = goalID
+			break
+		}
+
+		if e.H < bestH {
+			best, bestH = e.Index, e.H
+		}
+
+		current = e.Index
+		if current == startID {
+			for _, edge := range startEdges {
+				visit(edge.to, edge.cost)
+			}
+			continue
+		}
+
+		en := &h.entrances[current]
+		for _, edge := range en.edges {
+			visit(edge.to, edge.cost)
+		}
+		if en.cluster == cgi {
+			if d := goalDist[en.local]; !math.IsInf(float64(d), 1) {
+				visit(goalID, d)
+			}
+		}
+	}
+
+	var route []*cell2DRectGrid
+	for _, id := range s.Path(best) {
+		switch id {
+		case startID:
+			route = append(route, start)
+		case goalID:
+			route = append(route, goal)
+		default:
+			route = append(route, wm.grid[h.entrances[id].cell])
+		}
+	}
+
+	if best != goalID {
+		// Refining towards the goal from the closest entrance gets the path
+		// as close as the cells allow.
+		route = append(route, goal)
+	}
+
+	return route
+}
+
+// pathRoute holds the cells of a hierarchical path that haven't been refined
+// into a path through every cell yet, refining happens as the path is walked.
+type pathRoute struct {
+	actor     munfall.Actor
+	waypoints []*cell2DRectGrid
+	first     *path2DGrid
+	tail      *path2DGrid
+}
+
+func (wm *worldMap2DGrid) createRoutePath(a munfall.Actor, route []*cell2DRectGrid, z float32) *path2DGrid {
+	r := &pathRoute{actor: a, waypoints: route[1:]}
+	r.first = &path2DGrid{m: wm, cell: route[0], z: z, route: r}
+	r.first.first = r.first
+	r.tail = r.first
+	return r.first
+}
+
+// refine appends the cells up to the next waypoint to the path, returns false
+// when there is nothing left to refine. If the waypoint can't be reached the
+// path ends at the closest cell.
+func (r *pathRoute) refine() bool {
+	if len(r.waypoints) == 0 {
+		return false
+	}
+
+	wm := r.tail.m
+	target := r.waypoints[0]
+	r.waypoints = r.waypoints[1:]
+
+	// Waypoints are at most a cluster apart, the limit only keeps a blocked
+	// waypoint from searching the whole map.
+	limit := int(4 * wm.clusterSize * wm.clusterSize)
+	if wm.searchLimit > 0 && wm.searchLimit < limit {
+		limit = wm.searchLimit
+	}
+
+	cells := wm.findPath(r.actor, r.tail.cell, target, limit)
+	if cells[len(cells)-1] != target {
+		r.waypoints = nil
+	}
+
+	for _, cell := range cells[1:] {
+		node := &path2DGrid{m: wm, first: r.first, cell: cell, z: r.tail.z, route: r}
+		r.tail.next = node
+		r.tail = node
+	}
+
+	return true
+}
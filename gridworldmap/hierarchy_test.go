@@ -0,0 +1,124 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/bluemun/munfall"
+)
+
+// createMaze creates a size by size map scattered with short walls, the cell
+// at 2,2 where the returned actor stands is kept free.
+func createMaze(size uint) (*testMap, munfall.Actor) {
+	m := createTestMap(CreateGridWorldMap(size, size, 1, 1))
+	r := rand.New(rand.NewSource(1))
+	for i := uint(0); i < size*size/12; i++ {
+		x, y := uint(r.Intn(int(size))), uint(r.Intn(int(size)))
+		for k := uint(0); k < 4 && x+k < size; k++ {
+			m.wm.SetCellBlocked(&munfall.MPos{X: x + k, Y: y}, true)
+		}
+	}
+
+	m.wm.SetCellBlocked(&munfall.MPos{X: 2, Y: 2}, false)
+	return m, m.spawn(munfall.WPos{X: 2, Y: 2}, nil)
+}
+
+func TestHierarchicalPath(t *testing.T) {
+	m, a := createMaze(128)
+	goal := &munfall.WPos{X: 120, Y: 110}
+	m.wm.SetCellBlocked(m.wm.ConvertToMPos(goal), false)
+	flat := cellsOf(m.wm.GetPath(a, a.Pos(), goal))
+
+	m.wm.SetClusterSize(16)
+	p := m.wm.GetPath(a, a.Pos(), goal)
+	cells := cellsOf(p)
+	checkSteps(t, m.wm, cells)
+	if end := cells[len(cells)-1]; end != flat[len(flat)-1] || *p.Last().MPos() != end {
+		t.Fatalf("hierarchical path ends at %v, the flat one at %v", end, flat[len(flat)-1])
+	}
+
+	// Hierarchical paths aren't optimal but shouldn't stray far from it.
+	if len(cells) > len(flat)*5/4 {
+		t.Errorf("hierarchical path has %d cells, the flat one %d", len(cells), len(flat))
+	}
+}
+
+func TestHierarchyIncrementalUpdates(t *testing.T) {
+	m, a := createMaze(128)
+	m.wm.SetClusterSize(16)
+	goal := &munfall.WPos{X: 120, Y: 110}
+	g := m.wm.ConvertToMPos(goal)
+	m.wm.SetCellBlocked(g, false)
+	m.wm.GetPath(a, a.Pos(), goal)
+
+	// Walling in the goal after the hierarchy was built must be noticed.
+	for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		m.wm.SetCellBlocked(&munfall.MPos{X: uint(int(g.X) + d[0]), Y: uint(int(g.Y) + d[1])}, true)
+	}
+
+	cells := cellsOf(m.wm.GetPath(a, a.Pos(), goal))
+	checkSteps(t, m.wm, cells)
+	if cells[len(cells)-1] == *g {
+		t.Fatal("path reached a walled in goal")
+	}
+
+	m.wm.SetCellBlocked(&munfall.MPos{X: g.X - 1, Y: g.Y}, false)
+	cells = cellsOf(m.wm.GetPath(a, a.Pos(), goal))
+	checkSteps(t, m.wm, cells)
+	if cells[len(cells)-1] != *g {
+		t.Fatalf("path didn't reach the goal after it was opened, it ends at %v", cells[len(cells)-1])
+	}
+}
+
+func TestHierarchyAvoidsNewWalls(t *testing.T) {
+	m := createTestMap(CreateGridWorldMap(64, 64, 1, 1))
+	a := m.spawn(munfall.WPos{X: 2, Y: 2}, nil)
+	m.wm.SetClusterSize(8)
+	goal := &munfall.WPos{X: 60, Y: 2}
+	m.wm.GetPath(a, a.Pos(), goal)
+
+	// A wall across the whole map except for a gap at the far end.
+	for y := uint(0); y < 63; y++ {
+		m.wm.SetCellBlocked(&munfall.MPos{X: 32, Y: y}, true)
+	}
+
+	cells := cellsOf(m.wm.GetPath(a, a.Pos(), goal))
+	checkSteps(t, m.wm, cells)
+	if cells[len(cells)-1] != *m.wm.ConvertToMPos(goal) {
+		t.Fatalf("path didn't go through the gap, it ends at %v", cells[len(cells)-1])
+	}
+}
+
+func BenchmarkHierarchicalPath(b *testing.B) {
+	m, a := createMaze(512)
+	m.wm.SetClusterSize(32)
+	r := rand.New(rand.NewSource(2))
+	goals := make([]*munfall.WPos, 64)
+	for i := range goals {
+		goals[i] = &munfall.WPos{X: float32(r.Intn(512)), Y: float32(r.Intn(512))}
+	}
+
+	// The clusters are built by the first query.
+	m.wm.GetPath(a, a.Pos(), goals[0])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.wm.GetPath(a, a.Pos(), goals[i%len(goals)])
+	}
+}
+
+func BenchmarkHierarchyRebuild(b *testing.B) {
+	m, a := createMaze(512)
+	m.wm.SetClusterSize(32)
+	goal := &munfall.WPos{X: 500, Y: 500}
+	m.wm.GetPath(a, a.Pos(), goal)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Each change rebuilds a cluster and its neighbours before the query.
+		m.wm.SetCellBlocked(&munfall.MPos{X: 200, Y: 200}, i%2 == 0)
+		m.wm.GetPath(a, a.Pos(), goal)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package gridworldmap isometric.go Defines the isometric projection of grid
+// world maps.
+package gridworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+)
+
+// IsometricWorldMap is a grid world map whose cells are drawn as diamonds.
+// Cells keep their neighbours and paths are searched over the grid as on any
+// grid world map, only world positions are projected.
+//
+// Cell 0,0 is the diamond at the bottom of the map, the one closest to the
+// viewer. Moving along the x axis of the grid goes up and to the right in the
+// world, moving along the y axis up and to the left. Positions in the world
+// are positions on screen, a point under the cursor is hit tested with
+// InsideMapWPos and ConvertToMPos.
+type IsometricWorldMap interface {
+	GridWorldMap
+	munfall.DepthSorter
+
+	// CellCorners returns the bottom, right, top and left corners of the
+	// diamond of the cell.
+	CellCorners(pos *munfall.MPos) [4]*munfall.WPos
+}
+
+type isometricWorldMap struct {
+	*worldMap2DGrid
+}
+
+// CreateIsometricWorldMap creates a grid world map of width by height cells
+// drawn as diamonds that are tileWidth wide and tileHeight high.
+func CreateIsometricWorldMap(width, height uint, tileWidth, tileHeight float32) IsometricWorldMap {
+	// Every step along the grid covers half of the diagonal of a tile.
+	step := float32(math.Sqrt(float64(tileWidth*tileWidth+tileHeight*tileHeight))) / 2
+	wm := CreateGridWorldMap(width, height, step, step).(*worldMap2DGrid)
+	wm.isometric = true
+	wm.tileWidth, wm.tileHeight = tileWidth, tileHeight
+	return &isometricWorldMap{wm}
+}
+
+// DrawDepth returns the order renderables at the position are drawn in, those
+// further up the screen are behind and drawn first. Renderables raised on the
+// Z axis are drawn over the ones on the ground below them.
+func (wm *isometricWorldMap) DrawDepth(pos *munfall.WPos) float32 {
+	return pos.Z - pos.Y
+}
+
+func (wm *isometricWorldMap) CellCorners(pos *munfall.MPos) [4]*munfall.WPos {
+	x, y := float32(pos.X), float32(pos.Y)
+	return [4]*munfall.WPos{
+		wm.fromGrid(x, y),
+		wm.fromGrid(x+1, y),
+		wm.fromGrid(x+1, y+1),
+		wm.fromGrid(x, y+1),
+	}
+}
+
+// isometricToGrid returns the position in cells of a position in the world.
+func (wm *worldMap2DGrid) isometricToGrid(w *munfall.WPos) (x, y float32) {
+	across := (w.X - float32(wm.height)*wm.tileWidth/2) / (wm.tileWidth / 2)
+	up := w.Y / (wm.tileHeight / 2)
+	return (up + across) / 2, (up - across) / 2
+}
+
+// isometricFromGrid returns the position in the world of a position in cells,
+// the map is moved right so the left corner of the map lies at 0.
+func (wm *worldMap2DGrid) isometricFromGrid(x, y float32) *munfall.WPos {
+	return &munfall.WPos{
+		X: (x - y + float32(wm.height)) * wm.tileWidth / 2,
+		Y: (x + y) * wm.tileHeight / 2,
+	}
+}
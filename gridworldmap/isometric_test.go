@@ -0,0 +1,123 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"testing"
+
+	"github.com/bluemun/munfall"
+)
+
+func TestIsometricSize(t *testing.T) {
+	wm := CreateIsometricWorldMap(6, 4, 64, 32)
+	if wm.Width() != 320 || wm.Height() != 160 {
+		t.Errorf("map is %v by %v, expected 320 by 160", wm.Width(), wm.Height())
+	}
+
+	if w, h := wm.CellSize(); w != 64 || h != 32 {
+		t.Errorf("cells are %v by %v, expected the tile size", w, h)
+	}
+
+	// The bottom corner of cell 0,0 lies on the bottom edge, the left
+	// corner of the map on the left edge.
+	corners := wm.CellCorners(&munfall.MPos{})
+	if *corners[0] != (munfall.WPos{X: 128}) {
+		t.Errorf("bottom corner of cell 0,0 is at %v", corners[0])
+	}
+
+	if left := wm.CellCorners(&munfall.MPos{Y: 3})[3]; left.X != 0 || left.Y != 64 {
+		t.Errorf("left corner of the map is at %v", left)
+	}
+}
+
+func TestIsometricCorners(t *testing.T) {
+	wm := CreateIsometricWorldMap(6, 4, 64, 32)
+	for y := uint(0); y < 4; y++ {
+		for x := uint(0); x < 6; x++ {
+			c := wm.CellCorners(&munfall.MPos{X: x, Y: y})
+			if c[2].X != c[0].X || c[2].Y-c[0].Y != 32 || c[1].X-c[3].X != 64 || c[1].Y != c[3].Y {
+				t.Fatalf("cell %v,%v isn't a 64 by 32 diamond: %v %v %v %v", x, y, c[0], c[1], c[2], c[3])
+			}
+
+			if *wm.ConvertToWPos(&munfall.MPos{X: x, Y: y}) != *c[0] {
+				t.Fatalf("cell %v,%v is at %v instead of its bottom corner", x, y, wm.ConvertToWPos(&munfall.MPos{X: x, Y: y}))
+			}
+		}
+	}
+}
+
+func TestIsometricHitTesting(t *testing.T) {
+	wm := CreateIsometricWorldMap(6, 4, 64, 32)
+	for y := uint(0); y < 4; y++ {
+		for x := uint(0); x < 6; x++ {
+			pos := munfall.MPos{X: x, Y: y}
+			c := wm.CellCorners(&pos)
+			center := &munfall.WPos{X: c[0].X, Y: (c[0].Y + c[2].Y) / 2}
+			// Points just inside every corner of the diamond.
+			points := []*munfall.WPos{
+				center,
+				{X: c[0].X, Y: c[0].Y + 1},
+				{X: c[1].X - 2, Y: c[1].Y},
+				{X: c[2].X, Y: c[2].Y - 1},
+				{X: c[3].X + 2, Y: c[3].Y},
+			}
+
+			for _, p := range points {
+				if got := wm.ConvertToMPos(p); *got != pos || !wm.InsideMapWPos(p) {
+					t.Fatalf("point %v of cell %v hit cell %v", p, pos, got)
+				}
+			}
+		}
+	}
+
+	// The corners of the bounding box lie outside of the diamond of the map.
+	for _, p := range []*munfall.WPos{{X: 1, Y: 1}, {X: 319, Y: 1}, {X: 1, Y: 159}, {X: 319, Y: 159}} {
+		if wm.InsideMapWPos(p) {
+			t.Errorf("%v is outside of the map but was hit", p)
+		}
+	}
+}
+
+func TestIsometricDrawDepth(t *testing.T) {
+	wm := CreateIsometricWorldMap(6, 4, 64, 32)
+	front := wm.ConvertToWPos(&munfall.MPos{})
+	back := wm.ConvertToWPos(&munfall.MPos{X: 3, Y: 3})
+	if wm.DrawDepth(back) >= wm.DrawDepth(front) {
+		t.Error("cells further up the screen aren't drawn first")
+	}
+
+	raised := &munfall.WPos{X: front.X, Y: front.Y, Z: 1}
+	if wm.DrawDepth(raised) <= wm.DrawDepth(front) {
+		t.Error("raised positions aren't drawn over the ground")
+	}
+}
+
+func TestIsometricPath(t *testing.T) {
+	wm := CreateIsometricWorldMap(6, 4, 64, 32)
+	for y := uint(0); y < 3; y++ {
+		wm.SetCellBlocked(&munfall.MPos{X: 3, Y: y}, true)
+	}
+
+	m := createTestMap(wm)
+	a := m.spawn(*wm.ConvertToWPos(&munfall.MPos{}), nil)
+	b := m.spawn(*wm.ConvertToWPos(&munfall.MPos{X: 5, Y: 3}), nil)
+	cells := cellsOf(wm.GetPath(a, a.Pos(), wm.ConvertToWPos(&munfall.MPos{X: 5, Y: 0})))
+	checkSteps(t, wm, cells)
+	if len(cells) != 12 || cells[len(cells)-1] != (munfall.MPos{X: 5, Y: 0}) {
+		t.Error("path doesn't go around the wall:", cells)
+	}
+
+	if got := wm.ActorsInRadius(b.Pos(), 10, nil); len(got) != 1 || got[0] != b {
+		t.Error("radius query returned", got)
+	}
+
+	if got := wm.ActorsInRect(&munfall.WPos{}, &munfall.WPos{X: 320, Y: 160}, nil); len(got) != 2 {
+		t.Error("rect query returned", got)
+	}
+
+	if got := wm.NearestActor(&munfall.WPos{X: 128, Y: 5}, 1000, nil); got != a {
+		t.Error("nearest actor is", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package gridworldmap layers.go Defines the height layers that keep the
+// spaces in a cell apart, like aircraft flying over ground units.
+package gridworldmap
+
+import (
+	"sort"
+
+	"github.com/bluemun/munfall"
+)
+
+// Layer is a height layer of the map, it holds the spaces from MinZ up to the
+// MinZ of the next layer. The lowest layer also holds everything below it.
+type Layer struct {
+	Name string
+	MinZ float32
+}
+
+// defaultLayers puts every space on a single ground layer.
+var defaultLayers = []Layer{{Name: "ground"}}
+
+// SetLayers replaces the layers of the map, every layer collides only with
+// itself until SetLayerCollision says otherwise. GetPath keeps actors on
+// their layer, the clusters of hierarchical searches only look at terrain and
+// blocked cells and leave the layers to the refinement of their paths. Flow
+// fields are shared by every layer and count spaces on all of them.
+func (wm *worldMap2DGrid) SetLayers(layers []Layer) {
+	if len(layers) == 0 {
+		munfall.Logger.Panic("A GridWorldMap needs at least one layer.")
+	}
+
+	wm.layers = append([]Layer(nil), layers...)
+	sort.SliceStable(wm.layers, func(i, j int) bool { return wm.layers[i].MinZ < wm.layers[j].MinZ })
+	wm.collide = make([][]bool, len(wm.layers))
+	for i := range wm.collide {
+		wm.collide[i] = make([]bool, len(wm.layers))
+		wm.collide[i][i] = true
+	}
+
+	wm.passability++
+}
+
+// SetLayerCollision sets if spaces on the two layers collide with each other.
+func (wm *worldMap2DGrid) SetLayerCollision(a, b string, collide bool) {
+	i, j := wm.layerIndex(a), wm.layerIndex(b)
+	wm.collide[i][j], wm.collide[j][i] = collide, collide
+	wm.passability++
+}
+
+// LayerOf returns the name of the layer that holds the position.
+func (wm *worldMap2DGrid) LayerOf(pos *munfall.WPos) string {
+	return wm.layers[wm.layerAt(pos.Z)].Name
+}
+
+// LayersCollide returns if spaces at the two positions are on layers that collide.
+func (wm *worldMap2DGrid) LayersCollide(a, b *munfall.WPos) bool {
+	return wm.collide[wm.layerAt(a.Z)][wm.layerAt(b.Z)]
+}
+
+// InLayer returns an ActorFilter that accepts actors standing on one of the
+// given layers, for use with the actor queries.
+func (wm *worldMap2DGrid) InLayer(names ...string) munfall.ActorFilter {
+	accepted := make([]bool, len(wm.layers))
+	for _, name := range names {
+		accepted[wm.layerIndex(name)] = true
+	}
+
+	return func(a munfall.Actor) bool {
+		return accepted[wm.layerAt(a.Pos().Z)]
+	}
+}
+
+// layerAt returns the index of the layer holding the height.
+func (wm *worldMap2DGrid) layerAt(z float32) int {
+	i := sort.Search(len(wm.layers), func(i int) bool { return wm.layers[i].MinZ > z })
+	if i == 0 {
+		return 0
+	}
+
+	return i - 1
+}
+
+func (wm *worldMap2DGrid) layerIndex(name string) int {
+	for i, layer := range wm.layers {
+		if layer.Name == name {
+			return i
+		}
+	}
+
+	munfall.Logger.Panic("Unknown layer", name, "on GridWorldMap.")
+	return -1
+}
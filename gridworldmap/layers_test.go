@@ -0,0 +1,102 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"testing"
+
+	"github.com/bluemun/munfall"
+)
+
+// createCorridor returns a map with a single open row at y 1 and air above
+// the ground.
+func createCorridor() *testMap {
+	wm := CreateGridWorldMap(10, 3, 1, 1)
+	for x := uint(0); x < 10; x++ {
+		wm.SetCellBlocked(&munfall.MPos{X: x, Y: 0}, true)
+		wm.SetCellBlocked(&munfall.MPos{X: x, Y: 2}, true)
+	}
+
+	wm.SetLayers([]Layer{{Name: "air", MinZ: 10}, {Name: "underground", MinZ: -10}, {Name: "ground"}})
+	return createTestMap(wm)
+}
+
+func TestLayerOf(t *testing.T) {
+	m := createCorridor()
+	for z, layer := range map[float32]string{-50: "underground", -10: "underground", 0: "ground", 9: "ground", 10: "air", 50: "air"} {
+		if got := m.wm.LayerOf(&munfall.WPos{Z: z}); got != layer {
+			t.Errorf("Z %v is on layer %v, expected %v", z, got, layer)
+		}
+	}
+}
+
+func TestAircraftPassesOverTank(t *testing.T) {
+	m := createCorridor()
+	m.spawn(munfall.WPos{X: 5, Y: 1}, nil)
+	aircraft := m.spawn(munfall.WPos{X: 0, Y: 1, Z: 12}, nil)
+
+	path := m.wm.GetPath(aircraft, aircraft.Pos(), &munfall.WPos{X: 9, Y: 1})
+	cells := cellsOf(path)
+	if len(cells) != 10 || cells[9] != (munfall.MPos{X: 9, Y: 1}) {
+		t.Fatal("the tank blocked the aircraft:", cells)
+	}
+
+	for p := path; !p.IsEnd(); p = p.Next() {
+		m.wm.Move(aircraft, p, 0.5)
+		if aircraft.Pos().Z != 12 {
+			t.Fatalf("moving over %v dropped the aircraft to %v", p.MPos(), aircraft.Pos())
+		}
+	}
+
+	m.wm.SetLayerCollision("air", "ground", true)
+	cells = cellsOf(m.wm.GetPath(aircraft, &munfall.WPos{X: 0, Y: 1, Z: 12}, &munfall.WPos{X: 9, Y: 1}))
+	if cells[len(cells)-1] != (munfall.MPos{X: 4, Y: 1}) {
+		t.Error("colliding layers didn't block the aircraft:", cells)
+	}
+}
+
+func TestTankBlockedOnGround(t *testing.T) {
+	m := createCorridor()
+	tank := m.spawn(munfall.WPos{X: 0, Y: 1}, nil)
+	m.spawn(munfall.WPos{X: 5, Y: 1, Z: 12}, nil)
+
+	cells := cellsOf(m.wm.GetPath(tank, tank.Pos(), &munfall.WPos{X: 9, Y: 1}))
+	if len(cells) != 10 {
+		t.Fatal("the aircraft blocked the tank:", cells)
+	}
+
+	m.spawn(munfall.WPos{X: 5, Y: 1}, nil)
+	cells = cellsOf(m.wm.GetPath(tank, tank.Pos(), &munfall.WPos{X: 9, Y: 1}))
+	if cells[len(cells)-1] != (munfall.MPos{X: 4, Y: 1}) {
+		t.Error("a ground unit didn't block the tank:", cells)
+	}
+}
+
+func TestCreatePathKeepsHeight(t *testing.T) {
+	m := createCorridor()
+	path := m.wm.CreatePath([]*munfall.WPos{{X: 0, Y: 1, Z: 10}, {X: 1, Y: 1, Z: 20}})
+	if z := path.WPos(0.5).Z; z != 15 {
+		t.Errorf("halfway between heights 10 and 20 is at %v", z)
+	}
+}
+
+func TestInLayer(t *testing.T) {
+	m := createCorridor()
+	aircraft := m.spawn(munfall.WPos{X: 5, Y: 1, Z: 12}, nil)
+	truck := m.spawn(munfall.WPos{X: 5, Y: 1}, nil)
+	m.spawn(munfall.WPos{X: 5, Y: 1, Z: -20}, nil)
+
+	if got := m.wm.ActorsInRadius(&munfall.WPos{X: 5, Y: 1}, 1, m.wm.InLayer("air")); len(got) != 1 || got[0] != aircraft {
+		t.Error("air query returned", got)
+	}
+
+	if got := m.wm.ActorsInRadius(&munfall.WPos{X: 5, Y: 1}, 1, m.wm.InLayer("ground")); len(got) != 1 || got[0] != truck {
+		t.Error("ground query returned", got)
+	}
+
+	if got := m.wm.ActorsInRadius(&munfall.WPos{X: 5, Y: 1}, 1, m.wm.InLayer("ground", "underground")); len(got) != 2 {
+		t.Error("ground and underground query returned", got)
+	}
+}
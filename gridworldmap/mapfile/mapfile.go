@@ -0,0 +1,276 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package mapfile mapfile.go Defines the native map file format of grid world
+// maps, shared by the editor and the game.
+package mapfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/gridworldmap"
+	"github.com/bluemun/munfall/logic"
+)
+
+// Version is the version of the format written by Write, Read accepts files
+// up to this version.
+const Version = 1
+
+// Map is the content of a map file. Maps are stored as JSON of the form
+//
+//	{"Version": 1,
+//	 "Metadata": {"Title": "Lakes", "Author": "bluemun", "PlayerStarts": [{"X": 2, "Y": 2}]},
+//	 "Width": 64, "Height": 64, "CellWidth": 1, "CellHeight": 1,
+//	 "Terrains": ["clear", "water"],
+//	 "Terrain": [{"Terrain": 0, "Count": 100}, {"Terrain": 1, "Count": 12}, ...],
+//	 "Blocked": [130, 131],
+//	 "Actors": [{"Definition": "tree", "Pos": {"X": 4, "Y": 7}, "Parameters": {"Size": 2}}]}
+type Map struct {
+	Version  int
+	Metadata Metadata
+
+	Width, Height         uint
+	CellWidth, CellHeight float32
+	// Isometric maps are drawn as diamonds, the cell size is the size of a tile.
+	Isometric bool `json:",omitempty"`
+
+	// Terrains holds the names of the terrain types used by the map, Terrain
+	// refers to them by index.
+	Terrains []string
+	// Terrain holds the terrain of every cell row by row as runs of cells
+	// with the same terrain.
+	Terrain []TerrainRun
+	// Blocked holds the indices of the blocked cells, x + y * Width.
+	Blocked []uint
+
+	Actors []*Actor
+}
+
+// Metadata describes a map.
+type Metadata struct {
+	Title       string
+	Author      string
+	Description string `json:",omitempty"`
+	// PlayerStarts holds the start position of every player slot.
+	PlayerStarts []*munfall.WPos
+}
+
+// TerrainRun is a run of Count cells that use the terrain at index Terrain of
+// Map.Terrains.
+type TerrainRun struct {
+	Terrain uint
+	Count   uint
+}
+
+// Actor is an actor placed on the map, Parameters are passed as runtime
+// parameters when it is created. Numbers in Parameters are read as float64.
+type Actor struct {
+	Definition string
+	Pos        munfall.WPos
+	Parameters map[string]interface{} `json:",omitempty"`
+}
+
+// Load reads a map file.
+func Load(name string) (*Map, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Save writes the map to a file.
+func (m *Map) Save(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Read reads a map and checks that it is complete, files written by a newer
+// version are rejected.
+func Read(r io.Reader) (*Map, error) {
+	m := &Map{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+
+	if m.Version < 1 || m.Version > Version {
+		return nil, fmt.Errorf("mapfile: unsupported version %d, expected at most %d", m.Version, Version)
+	}
+
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Write writes the map with the current version.
+func (m *Map) Write(w io.Writer) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+
+	m.Version = Version
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(m)
+}
+
+// Capture creates a map from the world map of the world and the actors in it.
+// Actors attached to another actor are left out, they are expected to be
+// created again by their parent. Terrain names are taken from the cells.
+func Capture(w munfall.World, metadata Metadata) *Map {
+	wm := w.WorldMap().(gridworldmap.GridWorldMap)
+	m := &Map{Version: Version, Metadata: metadata}
+	m.Width, m.Height = wm.Cells()
+	m.CellWidth, m.CellHeight = wm.CellSize()
+	_, m.Isometric = wm.(gridworldmap.IsometricWorldMap)
+
+	palette := make(map[string]uint)
+	pos := &munfall.MPos{}
+	for pos.Y = 0; pos.Y < m.Height; pos.Y++ {
+		for pos.X = 0; pos.X < m.Width; pos.X++ {
+			name := wm.CellAt(pos).Terrain().Name
+			index, exists := palette[name]
+			if !exists {
+				index = uint(len(m.Terrains))
+				palette[name] = index
+				m.Terrains = append(m.Terrains, name)
+			}
+
+			if last := len(m.Terrain) - 1; last >= 0 && m.Terrain[last].Terrain == index {
+				m.Terrain[last].Count++
+			} else {
+				m.Terrain = append(m.Terrain, TerrainRun{Terrain: index, Count: 1})
+			}
+
+			if wm.IsCellBlocked(pos) {
+				m.Blocked = append(m.Blocked, pos.X+pos.Y*m.Width)
+			}
+		}
+	}
+
+	for _, a := range w.Actors() {
+		if a.Parent() != nil {
+			continue
+		}
+
+		name, parameters := logic.ActorDefinitionOf(a)
+		if name == "" {
+			continue
+		}
+
+		m.Actors = append(m.Actors, &Actor{Definition: name, Pos: *a.Pos(), Parameters: parameters})
+	}
+
+	return m
+}
+
+// CreateWorldMap creates a grid world map with the size, projection, terrain
+// and blocked cells of the map. Cells that use the terrain a grid world map
+// starts out with don't need it to be in terrain.
+func (m *Map) CreateWorldMap(terrain map[string]*munfall.Terrain) (gridworldmap.GridWorldMap, error) {
+	var wm gridworldmap.GridWorldMap
+	if m.Isometric {
+		wm = gridworldmap.CreateIsometricWorldMap(m.Width, m.Height, m.CellWidth, m.CellHeight)
+	} else {
+		wm = gridworldmap.CreateGridWorldMap(m.Width, m.Height, m.CellWidth, m.CellHeight)
+	}
+
+	initial := wm.CellAt(&munfall.MPos{}).Terrain().Name
+
+	types := make([]*munfall.Terrain, len(m.Terrains))
+	for i, name := range m.Terrains {
+		t, exists := terrain[name]
+		if !exists && name != initial {
+			return nil, fmt.Errorf("mapfile: unknown terrain %s", name)
+		}
+
+		types[i] = t
+	}
+
+	var i uint
+	for _, run := range m.Terrain {
+		t := types[run.Terrain]
+		for end := i + run.Count; i < end; i++ {
+			if t != nil {
+				wm.SetTerrain(&munfall.MPos{X: i % m.Width, Y: i / m.Width}, t)
+			}
+		}
+	}
+
+	for _, index := range m.Blocked {
+		wm.SetCellBlocked(&munfall.MPos{X: index % m.Width, Y: index / m.Width}, true)
+	}
+
+	return wm, nil
+}
+
+// SpawnActors creates the actors of the map and adds them to the world, it
+// stops at the first actor that can't be created.
+func (m *Map) SpawnActors(ar *logic.ActorRegistry, w munfall.World) ([]munfall.Actor, error) {
+	actors := make([]munfall.Actor, 0, len(m.Actors))
+	for i, placed := range m.Actors {
+		a, err := ar.CreateActorE(placed.Definition, placed.Parameters, w, false)
+		if err != nil {
+			return actors, fmt.Errorf("mapfile: actor %d: %w", i, err)
+		}
+
+		pos := placed.Pos
+		a.SetPos(&pos)
+		w.AddToWorld(a)
+		actors = append(actors, a)
+	}
+
+	return actors, nil
+}
+
+// check returns an error if the map is inconsistent.
+func (m *Map) check() error {
+	if m.Width == 0 || m.Height == 0 || m.CellWidth <= 0 || m.CellHeight <= 0 {
+		return fmt.Errorf("mapfile: invalid map size %dx%d with %gx%g cells", m.Width, m.Height, m.CellWidth, m.CellHeight)
+	}
+
+	var cells uint
+	for _, run := range m.Terrain {
+		if run.Terrain >= uint(len(m.Terrains)) {
+			return fmt.Errorf("mapfile: terrain %d is not in the terrain list", run.Terrain)
+		}
+
+		cells += run.Count
+	}
+
+	if cells != m.Width*m.Height {
+		return fmt.Errorf("mapfile: terrain covers %d cells, expected %d", cells, m.Width*m.Height)
+	}
+
+	for _, index := range m.Blocked {
+		if index >= m.Width*m.Height {
+			return fmt.Errorf("mapfile: blocked cell %d is outside the map", index)
+		}
+	}
+
+	for i, a := range m.Actors {
+		if a == nil || a.Definition == "" {
+			return fmt.Errorf("mapfile: actor %d has no definition", i)
+		}
+	}
+
+	return nil
+}
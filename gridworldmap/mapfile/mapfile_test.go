@@ -0,0 +1,172 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package mapfile
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/gridworldmap"
+	"github.com/bluemun/munfall/logic"
+)
+
+type treeTrait struct {
+	owner      munfall.Actor
+	parameters map[string]interface{}
+}
+
+func (t *treeTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	t.owner = a
+	t.parameters = parameters
+}
+
+func (t *treeTrait) Owner() munfall.Actor {
+	return t.owner
+}
+
+var testTerrain = map[string]*munfall.Terrain{
+	"water": {Name: "water", Passable: true, MovementCosts: map[string]float32{"foot": 0}},
+	"road":  {Name: "road", Passable: true},
+}
+
+func createTestMap(isometric bool) *Map {
+	return &Map{
+		Version: Version,
+		Metadata: Metadata{
+			Title:        "Lakes",
+			Author:       "bluemun",
+			Description:  "Two lakes and a road.",
+			PlayerStarts: []*munfall.WPos{{X: 1, Y: 1}, {X: 7, Y: 5}},
+		},
+		Width:      8,
+		Height:     6,
+		CellWidth:  2,
+		CellHeight: 1,
+		Isometric:  isometric,
+		Terrains:   []string{"clear", "water", "road"},
+		Terrain: []TerrainRun{
+			{Terrain: 0, Count: 10}, {Terrain: 1, Count: 5}, {Terrain: 2, Count: 3}, {Terrain: 0, Count: 30},
+		},
+		Blocked: []uint{3, 40},
+		Actors: []*Actor{
+			{Definition: "tree", Pos: munfall.WPos{X: 3, Y: 4}, Parameters: map[string]interface{}{"Size": 2.0, "Kind": "oak"}},
+			{Definition: "tree", Pos: munfall.WPos{X: 5, Y: 1}},
+		},
+	}
+}
+
+func createTestRegistry() *logic.ActorRegistry {
+	ar := logic.CreateActorRegistry()
+	ar.RegisterTrait("Tree", (*treeTrait)(nil))
+	definition := logic.CreateActorDefinition("tree")
+	definition.AddTrait(logic.CreateTraitDefinition("Tree"))
+	ar.RegisterActor(definition)
+	return ar
+}
+
+func TestWriteRead(t *testing.T) {
+	for _, isometric := range []bool{false, true} {
+		m := createTestMap(isometric)
+		var buf bytes.Buffer
+		if err := m.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := Read(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(read, m) {
+			t.Errorf("isometric %v: read\n%+v\nwrote\n%+v", isometric, read, m)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	m := createTestMap(false)
+	name := filepath.Join(t.TempDir(), "lakes.map")
+	if err := m.Save(name); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded, m) {
+		t.Errorf("loaded\n%+v\nsaved\n%+v", loaded, m)
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	for _, isometric := range []bool{false, true} {
+		m := createTestMap(isometric)
+		wm, err := m.CreateWorldMap(testTerrain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := wm.(gridworldmap.IsometricWorldMap); ok != isometric {
+			t.Errorf("isometric %v: created an isometric map: %v", isometric, ok)
+		}
+
+		if wm.CellAt(&munfall.MPos{X: 2, Y: 1}).Terrain() != testTerrain["water"] || !wm.IsCellBlocked(&munfall.MPos{X: 0, Y: 5}) {
+			t.Errorf("isometric %v: terrain or blocked cells weren't applied", isometric)
+		}
+
+		w := logic.CreateWorld(wm)
+		ar := createTestRegistry()
+		actors, err := m.SpawnActors(ar, w)
+		if err != nil || len(actors) != 2 {
+			t.Fatal(err, actors)
+		}
+
+		tree := w.GetTrait(actors[0], (*treeTrait)(nil)).(*treeTrait)
+		if *actors[0].Pos() != m.Actors[0].Pos || tree.parameters["Kind"] != "oak" {
+			t.Errorf("isometric %v: actor spawned at %v with %v", isometric, actors[0].Pos(), tree.parameters)
+		}
+
+		// Attached actors are recreated by their parent and left out.
+		w.Attach(actors[0], ar.CreateActor("tree", nil, w, true), &munfall.WPos{})
+
+		captured := Capture(w, m.Metadata)
+		if !reflect.DeepEqual(captured, m) {
+			t.Errorf("isometric %v: captured\n%+v\nfrom\n%+v", isometric, captured, m)
+		}
+	}
+}
+
+func TestInvalidMaps(t *testing.T) {
+	var buf bytes.Buffer
+	if err := createTestMap(false).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Read(strings.NewReader(strings.Replace(buf.String(), `"Version": 1`, `"Version": 2`, 1))); err == nil {
+		t.Error("a newer version was accepted")
+	}
+
+	if _, err := createTestMap(false).CreateWorldMap(nil); err == nil {
+		t.Error("unknown terrain was accepted")
+	}
+
+	short := createTestMap(false)
+	short.Terrain = short.Terrain[:2]
+	if err := short.Write(&buf); err == nil {
+		t.Error("terrain that doesn't cover the map was accepted")
+	}
+
+	outside := createTestMap(false)
+	outside.Blocked = append(outside.Blocked, 48)
+	if err := outside.Write(&buf); err == nil {
+		t.Error("a blocked cell outside the map was accepted")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package gridworldmap pathfinding.go Defines the A* search used by GetPath.
+package gridworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+)
+
+// searchGraph lets the shared searches walk the cells of the map.
+type searchGraph struct {
+	wm *worldMap2DGrid
+}
+
+func (g searchGraph) Cell(i int) cellmap.Cell {
+	return g.wm.grid[i]
+}
+
+func (g searchGraph) MPos(i int) *munfall.MPos {
+	return g.wm.grid[i].pos
+}
+
+func (g searchGraph) Neighbours(i int, visit func(next int, step float32)) {
+	wm, cell := g.wm, g.wm.grid[i]
+	if cell.lc != nil {
+		visit(i-1, wm.cWidth)
+	}
+	if cell.rc != nil {
+		visit(i+1, wm.cWidth)
+	}
+	if cell.tc != nil {
+		visit(i-int(wm.width), wm.cHeight)
+	}
+	if cell.bc != nil {
+		visit(i+int(wm.width), wm.cHeight)
+	}
+}
+
+func (g searchGraph) Heuristic(from, to int) float32 {
+	return g.wm.heuristic(g.wm.grid[from].pos, g.wm.grid[to].pos)
+}
+
+func (g searchGraph) MinMovementCost(class string) float32 {
+	return g.wm.terrains.MinMovementCost(class)
+}
+
+func (wm *worldMap2DGrid) heuristic(from, to *munfall.MPos) float32 {
+	dx := math.Abs(float64(from.X) - float64(to.X))
+	dy := math.Abs(float64(from.Y) - float64(to.Y))
+	return float32(dx)*wm.cWidth + float32(dy)*wm.cHeight
+}
+
+// findPath runs A* over the cells from start to goal, it returns the cells of
+// the path including start. If the goal can't be reached or limit cells have
+// been expanded, the path to the cell closest to the goal is returned instead.
+func (wm *worldMap2DGrid) findPath(a munfall.Actor, start, goal *cell2DRectGrid, limit int) []*cell2DRectGrid {
+	if wm.search == nil {
+		wm.search = cellmap.CreateSearch(len(wm.grid))
+	}
+
+	index := func(c *cell2DRectGrid) int { return int(c.pos.X + c.pos.Y*wm.width) }
+	path := wm.search.FindPath(searchGraph{wm}, cellmap.CreateFootprint(wm, wm.world, a), index(start), index(goal), limit)
+	cells := make([]*cell2DRectGrid, len(path))
+	for i, j := range path {
+		cells[i] = wm.grid[j]
+	}
+
+	return cells
+}
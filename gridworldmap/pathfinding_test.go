@@ -0,0 +1,168 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// createWalledMap creates a 10 by 10 map with a wall at x 5 that leaves a gap at the top.
+func createWalledMap() *testMap {
+	m := createTestMap(CreateGridWorldMap(10, 10, 1, 1))
+	for y := uint(0); y < 9; y++ {
+		m.wm.SetCellBlocked(&munfall.MPos{X: 5, Y: y}, true)
+	}
+
+	return m
+}
+
+func TestPathAroundWall(t *testing.T) {
+	m := createWalledMap()
+	a := m.spawn(munfall.WPos{X: 1, Y: 1}, nil)
+	p := m.wm.GetPath(a, a.Pos(), &munfall.WPos{X: 8, Y: 1})
+	cells := cellsOf(p)
+	checkSteps(t, m.wm, cells)
+	if len(cells) != 24 {
+		t.Errorf("expected the shortest path of 24 cells, got %d: %v", len(cells), cells)
+	}
+
+	if end := cells[len(cells)-1]; end != (munfall.MPos{X: 8, Y: 1}) || *p.Last().MPos() != end {
+		t.Errorf("path ends at %v", end)
+	}
+}
+
+func TestPathToClosestCell(t *testing.T) {
+	m := createWalledMap()
+	a := m.spawn(munfall.WPos{X: 1, Y: 1}, nil)
+	m.spawn(munfall.WPos{X: 5, Y: 9}, nil)
+	cells := cellsOf(m.wm.GetPath(a, a.Pos(), &munfall.WPos{X: 8, Y: 1}))
+	if end := cells[len(cells)-1]; end != (munfall.MPos{X: 4, Y: 1}) {
+		t.Errorf("expected the path to stop next to the goal on this side of the wall, it ends at %v", end)
+	}
+}
+
+func TestPathSearchLimit(t *testing.T) {
+	m := createWalledMap()
+	a := m.spawn(munfall.WPos{X: 1, Y: 1}, nil)
+	m.wm.SetPathSearchLimit(3)
+	if cells := cellsOf(m.wm.GetPath(a, a.Pos(), &munfall.WPos{X: 3, Y: 8})); len(cells) > 4 {
+		t.Errorf("search expanded past its limit: %v", cells)
+	}
+}
+
+func TestMoveAlongPath(t *testing.T) {
+	m := createWalledMap()
+	a := m.spawn(munfall.WPos{X: 1, Y: 1}, nil)
+	m.wm.Move(a, m.wm.GetPath(a, a.Pos(), &munfall.WPos{X: 3, Y: 1}), 0.5)
+	if a.Pos().X != 1.5 || a.Pos().Y != 1 {
+		t.Errorf("expected the actor halfway to the next cell, it is at %v", a.Pos())
+	}
+
+	p := m.wm.CreatePath([]*munfall.WPos{{X: 0}, {X: 1}, {X: 2}})
+	if p.Last().MPos().X != 2 || len(cellsOf(p)) != 3 {
+		t.Errorf("CreatePath made %v", cellsOf(p))
+	}
+}
+
+func TestAdjacentCells(t *testing.T) {
+	wm := CreateGridWorldMap(10, 10, 1, 1)
+	for _, c := range []struct {
+		pos      munfall.MPos
+		adjacent int
+	}{{munfall.MPos{X: 0, Y: 0}, 2}, {munfall.MPos{X: 5, Y: 0}, 3}, {munfall.MPos{X: 5, Y: 5}, 4}} {
+		if n := len(wm.CellAt(&c.pos).AdjacentCells()); n != c.adjacent {
+			t.Errorf("cell %v has %d adjacent cells, want %d", c.pos, n, c.adjacent)
+		}
+	}
+}
+
+// createRiverMap creates a 20 by 20 map with a river at x 10 that foot units
+// can't cross, the terrain types are returned by name.
+func createRiverMap(t *testing.T) (*testMap, map[string]*munfall.Terrain) {
+	types, err := munfall.ReadTerrainTypes(strings.NewReader(`[
+		{"Name": "water", "Passable": true, "MovementCosts": {"foot": 0, "naval": 1}},
+		{"Name": "road", "Passable": true, "MovementCosts": {"foot": 0.5}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := createTestMap(CreateGridWorldMap(20, 20, 1, 1))
+	for y := uint(0); y < 20; y++ {
+		m.wm.SetTerrain(&munfall.MPos{X: 10, Y: y}, types["water"])
+	}
+
+	return m, types
+}
+
+func TestPathTerrain(t *testing.T) {
+	m, types := createRiverMap(t)
+	foot := m.spawn(munfall.WPos{X: 2, Y: 5}, map[string]interface{}{"Class": "foot"})
+	goal := &munfall.WPos{X: 15, Y: 5}
+	if cells := cellsOf(m.wm.GetPath(foot, foot.Pos(), goal)); cells[len(cells)-1].X >= 10 {
+		t.Fatalf("foot unit crossed the river to %v", cells[len(cells)-1])
+	}
+
+	boat := m.spawn(munfall.WPos{X: 10, Y: 0}, map[string]interface{}{"Class": "naval"})
+	cells := cellsOf(m.wm.GetPath(boat, boat.Pos(), &munfall.WPos{X: 10, Y: 19}))
+	for _, c := range cells {
+		if c.X != 10 {
+			t.Fatalf("boat left the river at %v", c)
+		}
+	}
+
+	// A bridge lets foot units cross, with or without the hierarchy.
+	m.wm.SetTerrain(&munfall.MPos{X: 10, Y: 15}, types["road"])
+	for _, clusterSize := range []uint{0, 5} {
+		m.wm.SetClusterSize(clusterSize)
+		cells = cellsOf(m.wm.GetPath(foot, foot.Pos(), goal))
+		if cells[len(cells)-1] != *m.wm.ConvertToMPos(goal) {
+			t.Fatalf("cluster size %d: foot unit didn't cross the bridge, path ends at %v", clusterSize, cells[len(cells)-1])
+		}
+	}
+}
+
+func TestPathPrefersCheapTerrain(t *testing.T) {
+	m, types := createRiverMap(t)
+	m.wm.SetTerrain(&munfall.MPos{X: 10, Y: 6}, types["road"])
+	// The road along the top is a longer way to the goal than the bridge at
+	// y 6, but a cheaper one.
+	for x := uint(0); x < 20; x++ {
+		m.wm.SetTerrain(&munfall.MPos{X: x, Y: 0}, types["road"])
+	}
+
+	foot := m.spawn(munfall.WPos{X: 2, Y: 4}, map[string]interface{}{"Class": "foot"})
+	cells := cellsOf(m.wm.GetPath(foot, foot.Pos(), &munfall.WPos{X: 18, Y: 4}))
+	crossed := false
+	for _, c := range cells {
+		crossed = crossed || c == munfall.MPos{X: 10, Y: 0}
+	}
+
+	if !crossed {
+		t.Errorf("foot unit didn't take the road: %v", cells)
+	}
+
+	if speed := traits.MovementSpeed(foot, 2); speed != 2 {
+		t.Errorf("speed on clear terrain is %v", speed)
+	}
+
+	foot.SetPos(&munfall.WPos{X: 3, Y: 0})
+	if speed := traits.MovementSpeed(foot, 2); speed != 4 {
+		t.Errorf("speed on the road is %v", speed)
+	}
+
+	f := m.wm.FlowField(&munfall.WPos{X: 18, Y: 4}, "foot")
+	if f == m.wm.FlowField(&munfall.WPos{X: 18, Y: 4}, "naval") {
+		t.Error("flow fields of different movement classes are shared")
+	}
+
+	if !math.IsInf(float64(f.Cost(&munfall.MPos{X: 10, Y: 5})), 1) {
+		t.Error("flow field lets foot units into the river")
+	}
+}
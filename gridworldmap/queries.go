@@ -0,0 +1,180 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package gridworldmap queries.go Defines spatial queries that find actors
+// by the cells they occupy.
+package gridworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+)
+
+// ActorsInRadius returns every actor with a space whose offset lies within
+// radius of center, only actors occupying space on the map can be found.
+func (wm *worldMap2DGrid) ActorsInRadius(center *munfall.WPos, radius float32, filter munfall.ActorFilter) []munfall.Actor {
+	low := &munfall.WPos{X: center.X - radius, Y: center.Y - radius}
+	high := &munfall.WPos{X: center.X + radius, Y: center.Y + radius}
+	r2 := radius * radius
+	return wm.collect(wm.cellsBetween(low, high), func(space munfall.Space) bool {
+		return cellmap.DistanceSquared(space.Offset(), center) <= r2
+	}, filter)
+}
+
+// ActorsInRect returns every actor with a space whose offset lies inside
+// the given rectangle, only actors occupying space on the map can be found.
+func (wm *worldMap2DGrid) ActorsInRect(topLeft, bottomRight *munfall.WPos, filter munfall.ActorFilter) []munfall.Actor {
+	return wm.collect(wm.cellsBetween(topLeft, bottomRight), func(space munfall.Space) bool {
+		pos := space.Offset()
+		return pos.X >= topLeft.X && pos.X <= bottomRight.X &&
+			pos.Y >= topLeft.Y && pos.Y <= bottomRight.Y
+	}, filter)
+}
+
+// ActorsInCells returns every actor occupying one of the given cells,
+// cells outside of the map are ignored.
+func (wm *worldMap2DGrid) ActorsInCells(cells []*munfall.MPos, filter munfall.ActorFilter) []munfall.Actor {
+	grid := make([]*cell2DRectGrid, 0, len(cells))
+	for _, pos := range cells {
+		if wm.InsideMapMPos(pos) {
+			grid = append(grid, wm.grid[pos.X+pos.Y*wm.width])
+		}
+	}
+
+	return wm.collect(grid, func(munfall.Space) bool { return true }, filter)
+}
+
+// NearestActor returns the actor closest to pos that is accepted by the filter,
+// searching outwards cell by cell up to maxDistance, returns nil if none was found.
+func (wm *worldMap2DGrid) NearestActor(pos *munfall.WPos, maxDistance float32, filter munfall.ActorFilter) munfall.Actor {
+	center := wm.ConvertToMPos(pos)
+	cellSize := wm.minCellDistance()
+	rings := int(math.Ceil(float64(maxDistance/cellSize))) + 1
+	if limit := int(wm.width + wm.height); rings > limit {
+		rings = limit
+	}
+
+	var best munfall.Actor
+	bestDistance := maxDistance * maxDistance
+	rejected := make(map[uint]bool)
+	for ring := 0; ring <= rings; ring++ {
+		if best != nil {
+			// Everything in this ring is at least ring-1 cells away from pos.
+			minDistance := float32(ring-1) * cellSize
+			if minDistance*minDistance > bestDistance {
+				break
+			}
+		}
+
+		wm.visitRing(center, ring, func(cell *cell2DRectGrid) {
+			for _, space := range cell.Space() {
+				owner := space.Trait().Owner()
+				if rejected[owner.ActorID()] {
+					continue
+				}
+
+				d := cellmap.DistanceSquared(space.Offset(), pos)
+				if d > bestDistance || (best != nil && d == bestDistance) {
+					continue
+				}
+
+				if filter != nil && !filter(owner) {
+					rejected[owner.ActorID()] = true
+					continue
+				}
+
+				best, bestDistance = owner, d
+			}
+		})
+	}
+
+	return best
+}
+
+// visitRing calls f for every cell on the square ring at the given
+// distance in cells around center.
+func (wm *worldMap2DGrid) visitRing(center *munfall.MPos, ring int, f func(*cell2DRectGrid)) {
+	cx, cy := int(center.X), int(center.Y)
+	visit := func(x, y int) {
+		if x >= 0 && y >= 0 && x < int(wm.width) && y < int(wm.height) {
+			f(wm.grid[x+y*int(wm.width)])
+		}
+	}
+
+	if ring == 0 {
+		visit(cx, cy)
+		return
+	}
+
+	for x := cx - ring; x <= cx+ring; x++ {
+		visit(x, cy-ring)
+		visit(x, cy+ring)
+	}
+
+	for y := cy - ring + 1; y < cy+ring; y++ {
+		visit(cx-ring, y)
+		visit(cx+ring, y)
+	}
+}
+
+// cellsBetween returns the cells covering the rectangle between low and high.
+func (wm *worldMap2DGrid) cellsBetween(low, high *munfall.WPos) []*cell2DRectGrid {
+	// The rectangle is a diamond in cells on isometric maps, the cells around
+	// its corners cover it.
+	minX, minY := wm.toGrid(low)
+	maxX, maxY := minX, minY
+	for _, corner := range [...]munfall.WPos{{X: high.X, Y: low.Y}, {X: low.X, Y: high.Y}, {X: high.X, Y: high.Y}} {
+		x, y := wm.toGrid(&corner)
+		if x < minX {
+			minX = x
+		}
+		if x > maxX {
+			maxX = x
+		}
+		if y < minY {
+			minY = y
+		}
+		if y > maxY {
+			maxY = y
+		}
+	}
+
+	if maxX < 0 || maxY < 0 || minX > float32(wm.width) || minY > float32(wm.height) {
+		return nil
+	}
+
+	firstX, firstY := clampCell(minX, wm.width), clampCell(minY, wm.height)
+	lastX, lastY := clampCell(maxX, wm.width), clampCell(maxY, wm.height)
+	cells := make([]*cell2DRectGrid, 0, (lastX-firstX+1)*(lastY-firstY+1))
+	for y := firstY; y <= lastY; y++ {
+		for x := firstX; x <= lastX; x++ {
+			cells = append(cells, wm.grid[x+y*wm.width])
+		}
+	}
+
+	return cells
+}
+
+// minCellDistance returns the shortest distance in the world between two
+// positions that are a cell apart along either axis.
+func (wm *worldMap2DGrid) minCellDistance() float32 {
+	if wm.isometric {
+		return float32(math.Min(float64(wm.tileWidth), float64(wm.tileHeight)) / math.Sqrt2)
+	}
+
+	return float32(math.Min(float64(wm.cWidth), float64(wm.cHeight)))
+}
+
+// collect returns the owners of the spaces in the given cells that are accepted
+// by both accept and filter, every actor is returned at most once.
+func (wm *worldMap2DGrid) collect(cells []*cell2DRectGrid, accept func(munfall.Space) bool, filter munfall.ActorFilter) []munfall.Actor {
+	c := cellmap.Collector{Accept: accept, Filter: filter}
+	for _, cell := range cells {
+		c.Add(cell.Space())
+	}
+
+	return c.Actors
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/bluemun/munfall"
+)
+
+// spawnScattered adds count units to the centers of distinct random cells.
+func (m *testMap) spawnScattered(r *rand.Rand, count int) []munfall.Actor {
+	width, height := int(m.wm.Width()), int(m.wm.Height())
+	actors := make([]munfall.Actor, count)
+	for i, cell := range r.Perm(width * height)[:count] {
+		pos := munfall.WPos{X: float32(cell%width) + 0.5, Y: float32(cell/width) + 0.5}
+		actors[i] = m.spawn(pos, nil)
+	}
+
+	return actors
+}
+
+// ids returns the sorted IDs of the actors.
+func ids(actors []munfall.Actor) []uint {
+	out := make([]uint, len(actors))
+	for i, a := range actors {
+		out[i] = a.ActorID()
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkQueries compares the queries of the map with checking every actor,
+// the filter only accepts actors with an even ID.
+func checkQueries(t *testing.T, m *testMap, r *rand.Rand, actors []munfall.Actor) {
+	t.Helper()
+	even := func(a munfall.Actor) bool { return a.ActorID()%2 == 0 }
+	for i := 0; i < 50; i++ {
+		center := &munfall.WPos{X: r.Float32() * m.wm.Width(), Y: r.Float32() * m.wm.Height()}
+		radius := r.Float32() * 10
+		low := &munfall.WPos{X: center.X - radius, Y: center.Y - radius/2}
+		high := &munfall.WPos{X: center.X + radius, Y: center.Y + radius/2}
+
+		var inRadius, inRect []munfall.Actor
+		var nearest munfall.Actor
+		nearestDistance := float32(1e9)
+		for _, a := range actors {
+			if !even(a) {
+				continue
+			}
+
+			pos := a.Pos()
+			dx, dy := pos.X-center.X, pos.Y-center.Y
+			d := dx*dx + dy*dy
+			if d <= radius*radius {
+				inRadius = append(inRadius, a)
+			}
+
+			if pos.X >= low.X && pos.X <= high.X && pos.Y >= low.Y && pos.Y <= high.Y {
+				inRect = append(inRect, a)
+			}
+
+			if d < nearestDistance {
+				nearest, nearestDistance = a, d
+			}
+		}
+
+		if got, want := ids(m.wm.ActorsInRadius(center, radius, even)), ids(inRadius); !equalIDs(got, want) {
+			t.Fatalf("radius %v around %v found %v, expected %v", radius, center, got, want)
+		}
+
+		if got, want := ids(m.wm.ActorsInRect(low, high, even)), ids(inRect); !equalIDs(got, want) {
+			t.Fatalf("rect from %v to %v found %v, expected %v", low, high, got, want)
+		}
+
+		if got := m.wm.NearestActor(center, 1000, even); got != nearest {
+			t.Fatalf("nearest actor to %v is %v, expected %v", center, got, nearest)
+		}
+	}
+}
+
+func TestQueries(t *testing.T) {
+	m := createTestMap(CreateGridWorldMap(40, 30, 1, 1))
+	r := rand.New(rand.NewSource(3))
+	actors := m.spawnScattered(r, 200)
+	checkQueries(t, m, r, actors)
+
+	for _, a := range actors[:100] {
+		a.Kill()
+	}
+
+	checkQueries(t, m, r, actors[100:])
+	if got := m.wm.ActorsInRect(&munfall.WPos{}, &munfall.WPos{X: 40, Y: 30}, nil); len(got) != 100 {
+		t.Errorf("the whole map holds %v actors, expected 100", len(got))
+	}
+}
+
+func TestActorsInCells(t *testing.T) {
+	m := createTestMap(CreateGridWorldMap(8, 8, 1, 1))
+	a := m.spawn(munfall.WPos{X: 1.5, Y: 1.5}, nil)
+	b := m.spawn(munfall.WPos{X: 5.5, Y: 2.5}, nil)
+	m.spawn(munfall.WPos{X: 6.5, Y: 6.5}, nil)
+
+	cells := []*munfall.MPos{{X: 1, Y: 1}, {X: 5, Y: 2}, {X: 3, Y: 3}, {X: 1, Y: 1}, {X: 20, Y: 20}}
+	if got := ids(m.wm.ActorsInCells(cells, nil)); !equalIDs(got, ids([]munfall.Actor{a, b})) {
+		t.Errorf("cells hold %v, expected %v and %v once each", got, a.ActorID(), b.ActorID())
+	}
+
+	notA := func(actor munfall.Actor) bool { return actor != a }
+	if got := m.wm.ActorsInCells(cells, notA); len(got) != 1 || got[0] != b {
+		t.Errorf("filtered cells hold %v", ids(got))
+	}
+}
+
+func TestNearestActorLimits(t *testing.T) {
+	m := createTestMap(CreateGridWorldMap(20, 20, 1, 1))
+	near := m.spawn(munfall.WPos{X: 4.5, Y: 4.5}, nil)
+	far := m.spawn(munfall.WPos{X: 15.5, Y: 15.5}, nil)
+	from := &munfall.WPos{X: 2.5, Y: 4.5}
+
+	if got := m.wm.NearestActor(from, 1.5, nil); got != nil {
+		t.Errorf("found %v beyond the max distance", got.ActorID())
+	}
+
+	if got := m.wm.NearestActor(from, 100, nil); got != near {
+		t.Errorf("nearest actor is %v, expected %v", got, near)
+	}
+
+	notNear := func(a munfall.Actor) bool { return a != near }
+	if got := m.wm.NearestActor(from, 100, notNear); got != far {
+		t.Errorf("filtered nearest actor is %v, expected %v", got, far)
+	}
+}
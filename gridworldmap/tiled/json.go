@@ -0,0 +1,205 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package tiled json.go Defines how maps are read from the JSON format.
+package tiled
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type jsonMap struct {
+	Orientation string
+	Width       uint
+	Height      uint
+	TileWidth   float32
+	TileHeight  float32
+	Infinite    bool
+	Layers      []jsonLayer
+	Tilesets    []jsonTileset
+}
+
+type jsonLayer struct {
+	Type        string
+	Name        string
+	Data        json.RawMessage
+	Encoding    string
+	Compression string
+	Objects     []jsonObject
+	Layers      []jsonLayer
+}
+
+type jsonObject struct {
+	ID         int
+	Name       string
+	Type       string
+	Class      string
+	X, Y       float32
+	Width      float32
+	Height     float32
+	GID        uint32
+	Properties []jsonProperty
+}
+
+type jsonProperty struct {
+	Name  string
+	Type  string
+	Value interface{}
+}
+
+type jsonTileset struct {
+	FirstGID uint32
+	Source   string
+	Tiles    []struct {
+		ID         uint32
+		Type       string
+		Class      string
+		Properties []jsonProperty
+	}
+}
+
+// ReadJSON reads a map in the JSON format, open is used to read external
+// tilesets by their source and may be nil if the map has none.
+func ReadJSON(r io.Reader, open func(source string) (io.ReadCloser, error)) (*Map, error) {
+	var jm jsonMap
+	if err := json.NewDecoder(r).Decode(&jm); err != nil {
+		return nil, err
+	}
+
+	m := &Map{
+		Width:      jm.Width,
+		Height:     jm.Height,
+		TileWidth:  jm.TileWidth,
+		TileHeight: jm.TileHeight,
+		terrain:    make(map[uint32]string),
+	}
+
+	if err := m.checkHeader(jm.Orientation, jm.Infinite); err != nil {
+		return nil, err
+	}
+
+	for _, tileset := range jm.Tilesets {
+		if tileset.Source != "" {
+			if ext := strings.ToLower(path.Ext(tileset.Source)); ext != ".json" && ext != ".tsj" {
+				return nil, fmt.Errorf("tiled: JSON maps need JSON tilesets, got %s", tileset.Source)
+			}
+
+			if err := m.readJSONTilesetFile(tileset.FirstGID, tileset.Source, open); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		m.readJSONTileset(tileset.FirstGID, tileset)
+	}
+
+	if err := m.readJSONLayers(jm.Layers); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Map) readJSONTilesetFile(firstGID uint32, source string, open func(string) (io.ReadCloser, error)) error {
+	if open == nil {
+		return fmt.Errorf("tiled: no way to open external tileset %s", source)
+	}
+
+	file, err := open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var tileset jsonTileset
+	if err := json.NewDecoder(file).Decode(&tileset); err != nil {
+		return fmt.Errorf("tiled: tileset %s: %w", source, err)
+	}
+
+	m.readJSONTileset(firstGID, tileset)
+	return nil
+}
+
+func (m *Map) readJSONTileset(firstGID uint32, tileset jsonTileset) {
+	for _, tile := range tileset.Tiles {
+		m.addTile(firstGID, tile.ID, tile.Type+tile.Class, jsonProperties(tile.Properties))
+	}
+}
+
+func (m *Map) readJSONLayers(layers []jsonLayer) error {
+	for _, layer := range layers {
+		switch layer.Type {
+		case "tilelayer":
+			gids, err := jsonTiles(layer)
+			if err != nil {
+				return err
+			}
+
+			if err := m.addLayer(layer.Name, gids); err != nil {
+				return err
+			}
+		case "objectgroup":
+			for _, object := range layer.Objects {
+				m.addObject(&Object{
+					ID:         object.ID,
+					Name:       object.Name,
+					Type:       object.Type + object.Class,
+					X:          object.X,
+					Y:          object.Y,
+					Width:      object.Width,
+					Height:     object.Height,
+					Properties: jsonProperties(object.Properties),
+				}, object.GID)
+			}
+		case "group":
+			if err := m.readJSONLayers(layer.Layers); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonTiles reads the tile data of a layer, stored either as an array of
+// gids or as a base64 string.
+func jsonTiles(layer jsonLayer) ([]uint32, error) {
+	if layer.Data == nil {
+		return nil, fmt.Errorf("tiled: layer %s has no data, infinite maps are not supported", layer.Name)
+	}
+
+	if layer.Encoding == "base64" {
+		var data string
+		if err := json.Unmarshal(layer.Data, &data); err != nil {
+			return nil, fmt.Errorf("tiled: layer %s: %w", layer.Name, err)
+		}
+
+		return decodeTiles(data, layer.Encoding, layer.Compression)
+	}
+
+	var gids []uint32
+	if err := json.Unmarshal(layer.Data, &gids); err != nil {
+		return nil, fmt.Errorf("tiled: layer %s: %w", layer.Name, err)
+	}
+
+	return gids, nil
+}
+
+// jsonProperties converts properties to the same types the TMX reader uses,
+// class properties hold nested values and are passed on as they are.
+func jsonProperties(properties []jsonProperty) map[string]interface{} {
+	out := make(map[string]interface{}, len(properties))
+	for _, property := range properties {
+		out[property.Name] = property.Value
+		if value, isNumber := property.Value.(float64); isNumber && (property.Type == "int" || property.Type == "object") {
+			out[property.Name] = int(value)
+		}
+	}
+
+	return out
+}
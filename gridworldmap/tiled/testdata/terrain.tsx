@@ -0,0 +1,9 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="terrain" tilewidth="16" tileheight="16" tilecount="2" columns="2">
+ <tile id="0" type="water"/>
+ <tile id="1">
+  <properties>
+   <property name="terrain" value="road"/>
+  </properties>
+ </tile>
+</tileset>
@@ -0,0 +1,273 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package tiled tiled.go Defines an importer for maps made with the Tiled map
+// editor, both the TMX and JSON formats are supported.
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/gridworldmap"
+	"github.com/bluemun/munfall/logic"
+)
+
+// gidMask clears the flip and rotation flags Tiled stores in the upper bits
+// of a tile gid.
+const gidMask = 0x0FFFFFFF
+
+// Map is a Tiled map, tile layers are kept in the order they are drawn in
+// and layers inside groups are flattened.
+type Map struct {
+	Width, Height         uint
+	TileWidth, TileHeight float32
+	Layers                []*TileLayer
+	Objects               []*Object
+
+	// terrain holds the terrain name of every tile gid that has one.
+	terrain map[uint32]string
+}
+
+// TileLayer holds the tile gids of a layer row by row, 0 is an empty tile.
+type TileLayer struct {
+	Name string
+	GIDs []uint32
+}
+
+// Object is an object from an object layer, Type holds the Tiled type or
+// class and names the actor definition that is spawned for it.
+type Object struct {
+	ID                  int
+	Name, Type          string
+	X, Y, Width, Height float32
+	Properties          map[string]interface{}
+}
+
+// Load reads a Tiled map from a .tmx or .json file, external tilesets are
+// read relative to the map.
+func Load(name string) (*Map, error) {
+	return LoadFS(os.DirFS(filepath.Dir(name)), filepath.Base(name))
+}
+
+// LoadFS reads a Tiled map from a .tmx, .tmj or .json file in fsys.
+func LoadFS(fsys fs.FS, name string) (*Map, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	open := func(source string) (io.ReadCloser, error) {
+		return fsys.Open(path.Join(path.Dir(name), source))
+	}
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".tmx":
+		return ReadTMX(file, open)
+	case ".tmj", ".json":
+		return ReadJSON(file, open)
+	default:
+		return nil, fmt.Errorf("tiled: unknown map format %s", name)
+	}
+}
+
+// TerrainOf returns the terrain name of the tile, it is read from the tile's
+// "terrain" property or else its type, an empty string if it has neither.
+func (m *Map) TerrainOf(gid uint32) string {
+	return m.terrain[gid&gidMask]
+}
+
+// CreateWorldMap creates a grid world map of the size of the map with the
+// terrain of the tile layers, tiles on later layers replace earlier ones.
+// Tiles without a terrain leave the cell as it is.
+func (m *Map) CreateWorldMap(cellWidth, cellHeight float32, terrain map[string]*munfall.Terrain) (gridworldmap.GridWorldMap, error) {
+	wm := gridworldmap.CreateGridWorldMap(m.Width, m.Height, cellWidth, cellHeight)
+	for _, layer := range m.Layers {
+		for i, gid := range layer.GIDs {
+			name := m.TerrainOf(gid)
+			if name == "" {
+				continue
+			}
+
+			t, exists := terrain[name]
+			if !exists {
+				return nil, fmt.Errorf("tiled: layer %s uses unknown terrain %s", layer.Name, name)
+			}
+
+			wm.SetTerrain(&munfall.MPos{X: uint(i) % m.Width, Y: uint(i) / m.Width}, t)
+		}
+	}
+
+	return wm, nil
+}
+
+// SpawnActors creates an actor for every object that has a type and adds it
+// to the world, the object properties are passed as runtime parameters.
+// Positions are scaled from pixels to the size of the world map.
+func (m *Map) SpawnActors(ar *logic.ActorRegistry, w munfall.World) ([]munfall.Actor, error) {
+	wm := w.WorldMap()
+	scaleX := wm.Width() / (float32(m.Width) * m.TileWidth)
+	scaleY := wm.Height() / (float32(m.Height) * m.TileHeight)
+
+	var actors []munfall.Actor
+	for _, object := range m.Objects {
+		if object.Type == "" {
+			continue
+		}
+
+		a, err := ar.CreateActorE(object.Type, object.Properties, w, false)
+		if err != nil {
+			return actors, fmt.Errorf("tiled: object %d: %w", object.ID, err)
+		}
+
+		a.SetPos(&munfall.WPos{X: object.X * scaleX, Y: object.Y * scaleY})
+		w.AddToWorld(a)
+		actors = append(actors, a)
+	}
+
+	return actors, nil
+}
+
+func (m *Map) addLayer(name string, gids []uint32) error {
+	if uint(len(gids)) != m.Width*m.Height {
+		return fmt.Errorf("tiled: layer %s has %d tiles, expected %d", name, len(gids), m.Width*m.Height)
+	}
+
+	for i := range gids {
+		gids[i] &= gidMask
+	}
+
+	m.Layers = append(m.Layers, &TileLayer{Name: name, GIDs: gids})
+	return nil
+}
+
+// addObject adds an object, tile objects are placed by their bottom left
+// corner in Tiled so they are moved to their top left corner.
+func (m *Map) addObject(object *Object, gid uint32) {
+	if gid != 0 {
+		object.Y -= object.Height
+	}
+
+	if len(object.Properties) == 0 {
+		object.Properties = nil
+	}
+
+	m.Objects = append(m.Objects, object)
+}
+
+func (m *Map) addTile(firstGID, id uint32, tileType string, properties map[string]interface{}) {
+	name, _ := properties["terrain"].(string)
+	if name == "" {
+		name = tileType
+	}
+
+	if name != "" {
+		m.terrain[firstGID+id] = name
+	}
+}
+
+func (m *Map) checkHeader(orientation string, infinite bool) error {
+	if orientation != "" && orientation != "orthogonal" {
+		return fmt.Errorf("tiled: %s maps are not supported", orientation)
+	}
+
+	if infinite {
+		return fmt.Errorf("tiled: infinite maps are not supported")
+	}
+
+	if m.Width == 0 || m.Height == 0 || m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return fmt.Errorf("tiled: invalid map size %dx%d with %gx%g tiles", m.Width, m.Height, m.TileWidth, m.TileHeight)
+	}
+
+	return nil
+}
+
+// decodeTiles decodes the tile data of a layer stored as csv or base64 with
+// optional zlib or gzip compression.
+func decodeTiles(data, encoding, compression string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var gids []uint32
+		for _, field := range strings.Split(data, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("tiled: invalid tile %q", field)
+			}
+
+			gids = append(gids, uint32(gid))
+		}
+
+		return gids, nil
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, err
+		}
+
+		var r io.Reader = bytes.NewReader(raw)
+		switch compression {
+		case "":
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, err
+			}
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("tiled: unsupported compression %s", compression)
+		}
+
+		if raw, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+
+		if len(raw)%4 != 0 {
+			return nil, fmt.Errorf("tiled: tile data is not a multiple of 4 bytes")
+		}
+
+		gids := make([]uint32, len(raw)/4)
+		for i := range gids {
+			gids[i] = binary.LittleEndian.Uint32(raw[i*4:])
+		}
+
+		return gids, nil
+	default:
+		return nil, fmt.Errorf("tiled: unsupported encoding %q", encoding)
+	}
+}
+
+// propertyValue converts a property from its text form using the Tiled type,
+// ints become int, floats float64, bools bool and everything else a string.
+func propertyValue(propertyType, value string) (interface{}, error) {
+	switch propertyType {
+	case "int", "object":
+		return strconv.Atoi(value)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+)
+
+// map.tmx and map.json hold the same map, a 4x3 map of 16 pixel tiles with a
+// ground layer and a group holding a base64 layer and an object layer.
+var testMaps = []string{"map.tmx", "map.json"}
+
+const testTerrain = `[
+	{"Name": "water", "Passable": true, "MovementCosts": {"foot": 0}},
+	{"Name": "road", "Passable": true}]`
+
+type testTrait struct {
+	owner      munfall.Actor
+	parameters map[string]interface{}
+}
+
+func (t *testTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	t.owner = a
+	t.parameters = parameters
+}
+
+func (t *testTrait) Owner() munfall.Actor {
+	return t.owner
+}
+
+func loadTestMap(t *testing.T, name string) *Map {
+	t.Helper()
+	m, err := Load(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	return m
+}
+
+func TestLoadTileLayers(t *testing.T) {
+	for _, name := range testMaps {
+		m := loadTestMap(t, name)
+		if m.Width != 4 || m.Height != 3 || m.TileWidth != 16 || m.TileHeight != 16 {
+			t.Errorf("%s: map is %dx%d with %gx%g tiles", name, m.Width, m.Height, m.TileWidth, m.TileHeight)
+		}
+
+		if len(m.Layers) != 2 || m.Layers[0].Name != "ground" || m.Layers[1].Name != "roads" {
+			t.Fatalf("%s: layers weren't read in drawing order", name)
+		}
+
+		ground := []uint32{1, 1, 0, 0, 0, 2, 2, 0, 0, 0, 0, 1}
+		for i, gid := range m.Layers[0].GIDs {
+			if gid != ground[i] {
+				t.Errorf("%s: ground tile %d is %d, want %d", name, i, gid, ground[i])
+			}
+		}
+
+		// The TMX roads tile is flipped, the flag must be cleared.
+		if gid := m.Layers[1].GIDs[11]; gid != 2 {
+			t.Errorf("%s: roads tile 11 is %#x, want 2", name, gid)
+		}
+
+		if m.TerrainOf(1) != "water" || m.TerrainOf(2) != "road" || m.TerrainOf(0x80000002) != "road" || m.TerrainOf(3) != "" {
+			t.Errorf("%s: tile terrain wasn't read from the tileset", name)
+		}
+	}
+}
+
+func TestCreateWorldMap(t *testing.T) {
+	types, err := munfall.ReadTerrainTypes(strings.NewReader(testTerrain))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range testMaps {
+		m := loadTestMap(t, name)
+		wm, err := m.CreateWorldMap(2, 2, types)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if wm.Width() != 8 || wm.Height() != 6 {
+			t.Errorf("%s: world map is %gx%g", name, wm.Width(), wm.Height())
+		}
+
+		for _, c := range []struct {
+			x, y    uint
+			terrain string
+		}{
+			{0, 0, "water"},
+			{1, 1, "road"},
+			{2, 0, "clear"},
+			// The roads layer replaces the water of the ground layer.
+			{3, 2, "road"},
+		} {
+			if got := wm.CellAt(&munfall.MPos{X: c.x, Y: c.y}).Terrain().Name; got != c.terrain {
+				t.Errorf("%s: cell %d,%d has terrain %s, want %s", name, c.x, c.y, got, c.terrain)
+			}
+		}
+
+		if _, err := m.CreateWorldMap(2, 2, map[string]*munfall.Terrain{"road": types["road"]}); err == nil {
+			t.Errorf("%s: unknown terrain was accepted", name)
+		}
+	}
+}
+
+func TestLoadObjects(t *testing.T) {
+	for _, name := range testMaps {
+		m := loadTestMap(t, name)
+		if len(m.Objects) != 3 {
+			t.Fatalf("%s: read %d objects, want 3", name, len(m.Objects))
+		}
+
+		scout, marker, tower := m.Objects[0], m.Objects[1], m.Objects[2]
+		if scout.ID != 1 || scout.Name != "scout" || scout.Type != "unit" || scout.X != 32 || scout.Y != 16 {
+			t.Errorf("%s: scout read as %+v", name, scout)
+		}
+
+		if marker.Type != "" || marker.Properties != nil {
+			t.Errorf("%s: marker read as %+v", name, marker)
+		}
+
+		// Tile objects are placed by their bottom left corner.
+		if tower.X != 16 || tower.Y != 32 {
+			t.Errorf("%s: tower is at %g,%g, want 16,32", name, tower.X, tower.Y)
+		}
+	}
+}
+
+func TestLoadProperties(t *testing.T) {
+	for _, name := range testMaps {
+		p := loadTestMap(t, name).Objects[0].Properties
+		if p["Count"] != 5 || p["Speed"] != 1.5 || p["Visible"] != true || p["Orders"] != "hold\nguard" {
+			t.Errorf("%s: properties read as %v", name, p)
+		}
+	}
+}
+
+func TestSpawnActors(t *testing.T) {
+	for _, name := range testMaps {
+		m := loadTestMap(t, name)
+		types, _ := munfall.ReadTerrainTypes(strings.NewReader(testTerrain))
+		wm, err := m.CreateWorldMap(2, 2, types)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		w := logic.CreateWorld(wm)
+		ar := logic.CreateActorRegistry()
+		ar.RegisterTrait("Test", (*testTrait)(nil))
+		ad := logic.CreateActorDefinition("unit")
+		ad.AddTrait(logic.CreateTraitDefinition("Test"))
+		ar.RegisterActor(ad)
+
+		actors, err := m.SpawnActors(ar, w)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(actors) != 2 {
+			t.Fatalf("%s: spawned %d actors, want 2", name, len(actors))
+		}
+
+		// Pixels are scaled to the 8x6 world map.
+		if pos := actors[0].Pos(); pos.X != 4 || pos.Y != 2 {
+			t.Errorf("%s: scout spawned at %v", name, pos)
+		}
+
+		if pos := actors[1].Pos(); pos.X != 2 || pos.Y != 4 {
+			t.Errorf("%s: tower spawned at %v", name, pos)
+		}
+
+		trait := w.GetTrait(actors[0], (*testTrait)(nil)).(*testTrait)
+		if trait.parameters["Count"] != 5 {
+			t.Errorf("%s: properties weren't passed to the traits, got %v", name, trait.parameters)
+		}
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("testdata", "malformed", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) == 0 {
+		t.Fatal("no malformed maps found")
+	}
+
+	for _, name := range names {
+		if _, err := Load(name); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+
+	if _, err := Load(filepath.Join("testdata", "terrain.tsx")); err == nil {
+		t.Error("a tileset was loaded as a map")
+	}
+}
+
+func TestReadWithoutTilesets(t *testing.T) {
+	for _, name := range testMaps {
+		file, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		read := ReadTMX
+		if filepath.Ext(name) == ".json" {
+			read = ReadJSON
+		}
+
+		if _, err := read(file, nil); err == nil {
+			t.Errorf("%s: external tileset was read without a way to open it", name)
+		}
+
+		file.Close()
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package tiled tmx.go Defines how maps are read from the TMX format.
+package tiled
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type tmxMap struct {
+	Orientation string       `xml:"orientation,attr"`
+	Width       uint         `xml:"width,attr"`
+	Height      uint         `xml:"height,attr"`
+	TileWidth   float32      `xml:"tilewidth,attr"`
+	TileHeight  float32      `xml:"tileheight,attr"`
+	Infinite    int          `xml:"infinite,attr"`
+	Tilesets    []tmxTileset `xml:"tileset"`
+	Children    []tmxLayer   `xml:",any"`
+}
+
+// tmxLayer holds a layer, object group or group, they are read together to
+// keep the order they are drawn in.
+type tmxLayer struct {
+	XMLName  xml.Name
+	Name     string      `xml:"name,attr"`
+	Data     *tmxData    `xml:"data"`
+	Objects  []tmxObject `xml:"object"`
+	Children []tmxLayer  `xml:",any"`
+}
+
+type tmxData struct {
+	Encoding    string `xml:"encoding,attr"`
+	Compression string `xml:"compression,attr"`
+	Text        string `xml:",chardata"`
+	Tiles       []struct {
+		GID uint32 `xml:"gid,attr"`
+	} `xml:"tile"`
+	Chunks []struct{} `xml:"chunk"`
+}
+
+type tmxObject struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float32       `xml:"x,attr"`
+	Y          float32       `xml:"y,attr"`
+	Width      float32       `xml:"width,attr"`
+	Height     float32       `xml:"height,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+type tmxTileset struct {
+	FirstGID uint32 `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+	Tiles    []struct {
+		ID         uint32        `xml:"id,attr"`
+		Type       string        `xml:"type,attr"`
+		Class      string        `xml:"class,attr"`
+		Properties []tmxProperty `xml:"properties>property"`
+	} `xml:"tile"`
+}
+
+// ReadTMX reads a map in the TMX format, open is used to read external
+// tilesets by their source and may be nil if the map has none.
+func ReadTMX(r io.Reader, open func(source string) (io.ReadCloser, error)) (*Map, error) {
+	var tm tmxMap
+	if err := xml.NewDecoder(r).Decode(&tm); err != nil {
+		return nil, err
+	}
+
+	m := &Map{
+		Width:      tm.Width,
+		Height:     tm.Height,
+		TileWidth:  tm.TileWidth,
+		TileHeight: tm.TileHeight,
+		terrain:    make(map[uint32]string),
+	}
+
+	if err := m.checkHeader(tm.Orientation, tm.Infinite != 0); err != nil {
+		return nil, err
+	}
+
+	for _, tileset := range tm.Tilesets {
+		if err := m.readTMXTileset(tileset, open); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.readTMXLayers(tm.Children); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Map) readTMXTileset(tileset tmxTileset, open func(string) (io.ReadCloser, error)) error {
+	firstGID, source := tileset.FirstGID, tileset.Source
+	if source != "" {
+		if ext := strings.ToLower(path.Ext(source)); ext == ".json" || ext == ".tsj" {
+			return m.readJSONTilesetFile(firstGID, source, open)
+		}
+
+		if open == nil {
+			return fmt.Errorf("tiled: no way to open external tileset %s", source)
+		}
+
+		file, err := open(source)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		tileset = tmxTileset{}
+		if err := xml.NewDecoder(file).Decode(&tileset); err != nil {
+			return fmt.Errorf("tiled: tileset %s: %w", source, err)
+		}
+	}
+
+	for _, tile := range tileset.Tiles {
+		properties, err := tmxProperties(tile.Properties)
+		if err != nil {
+			return err
+		}
+
+		m.addTile(firstGID, tile.ID, tile.Type+tile.Class, properties)
+	}
+
+	return nil
+}
+
+func (m *Map) readTMXLayers(layers []tmxLayer) error {
+	for _, layer := range layers {
+		switch layer.XMLName.Local {
+		case "layer":
+			if layer.Data == nil {
+				return fmt.Errorf("tiled: layer %s has no data", layer.Name)
+			}
+
+			if len(layer.Data.Chunks) > 0 {
+				return fmt.Errorf("tiled: infinite maps are not supported")
+			}
+
+			var gids []uint32
+			if layer.Data.Encoding == "" {
+				for _, tile := range layer.Data.Tiles {
+					gids = append(gids, tile.GID)
+				}
+			} else {
+				var err error
+				if gids, err = decodeTiles(layer.Data.Text, layer.Data.Encoding, layer.Data.Compression); err != nil {
+					return err
+				}
+			}
+
+			if err := m.addLayer(layer.Name, gids); err != nil {
+				return err
+			}
+		case "objectgroup":
+			for _, object := range layer.Objects {
+				properties, err := tmxProperties(object.Properties)
+				if err != nil {
+					return fmt.Errorf("tiled: object %d: %w", object.ID, err)
+				}
+
+				m.addObject(&Object{
+					ID:         object.ID,
+					Name:       object.Name,
+					Type:       object.Type + object.Class,
+					X:          object.X,
+					Y:          object.Y,
+					Width:      object.Width,
+					Height:     object.Height,
+					Properties: properties,
+				}, object.GID)
+			}
+		case "group":
+			if err := m.readTMXLayers(layer.Children); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func tmxProperties(properties []tmxProperty) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(properties))
+	for _, property := range properties {
+		text := property.Value
+		if text == "" {
+			// Multi line strings are stored as the element text.
+			text = property.Text
+		}
+
+		value, err := propertyValue(property.Type, text)
+		if err != nil {
+			return nil, fmt.Errorf("tiled: property %s: %w", property.Name, err)
+		}
+
+		out[property.Name] = value
+	}
+
+	return out, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package gridworldmap visibility.go Defines the fog of war that hides the
+// parts of the map a player can't see.
+package gridworldmap
+
+import (
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+	"github.com/bluemun/munfall/traits"
+)
+
+// Visibility is how much a player knows about a cell.
+type Visibility uint8
+
+const (
+	// Hidden cells have never been seen by the player.
+	Hidden Visibility = iota
+	// Explored cells have been seen before but aren't in sight right now.
+	Explored
+	// Visible cells are in sight of one of the player's actors.
+	Visible
+)
+
+// playerVision holds the visibility of every cell for a single player.
+type playerVision struct {
+	cells []Visibility
+	// visible holds the indices of the cells that are visible right now.
+	visible []int
+}
+
+// SetFogOfWar enables or disables the fog of war, every cell is visible to
+// every player while it is disabled.
+func (wm *worldMap2DGrid) SetFogOfWar(enabled bool) {
+	wm.fogOfWar = enabled
+	wm.visions = make(map[int]*playerVision)
+	if enabled {
+		wm.UpdateVisibility()
+	}
+}
+
+// UpdateVisibility recomputes the visible cells of every player from the
+// RevealsShroud traits of the actors in the world.
+func (wm *worldMap2DGrid) UpdateVisibility() {
+	for _, vision := range wm.visions {
+		for _, i := range vision.visible {
+			vision.cells[i] = Explored
+		}
+
+		vision.visible = vision.visible[:0]
+	}
+
+	if wm.world == nil {
+		return
+	}
+
+	for _, trait := range wm.world.GetAllTraitsImplementing((*traits.RevealsShroud)(nil)) {
+		reveal := trait.(traits.RevealsShroud)
+		owner := reveal.Owner()
+		if owner.IsDead() || !owner.IsInWorld() {
+			continue
+		}
+
+		wm.reveal(wm.visionOf(reveal.Player()), owner.Pos(), reveal.RevealRadius())
+	}
+}
+
+// Visibility returns how much the player knows about the cell.
+func (wm *worldMap2DGrid) Visibility(player int, pos *munfall.MPos) Visibility {
+	if !wm.fogOfWar {
+		return Visible
+	}
+
+	vision, exists := wm.visions[player]
+	if !exists {
+		return Hidden
+	}
+
+	return vision.cells[pos.X+pos.Y*wm.width]
+}
+
+// IsVisible returns if the cell is in sight of the player.
+func (wm *worldMap2DGrid) IsVisible(player int, pos *munfall.MPos) bool {
+	return wm.Visibility(player, pos) == Visible
+}
+
+// IsActorVisible returns if the player can see the actor, it is visible when
+// the cell at its position or one of the cells it occupies is visible.
+func (wm *worldMap2DGrid) IsActorVisible(player int, a munfall.Actor) bool {
+	if wm.IsVisible(player, wm.ConvertToMPos(a.Pos())) {
+		return true
+	}
+
+	if wm.world == nil {
+		return false
+	}
+
+	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil)) {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			if wm.IsVisible(player, wm.ConvertToMPos(space.Offset())) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (wm *worldMap2DGrid) visionOf(player int) *playerVision {
+	vision, exists := wm.visions[player]
+	if !exists {
+		vision = &playerVision{cells: make([]Visibility, len(wm.grid))}
+		wm.visions[player] = vision
+	}
+
+	return vision
+}
+
+// reveal makes the cells within radius of pos that are in line of sight visible.
+func (wm *worldMap2DGrid) reveal(vision *playerVision, pos *munfall.WPos, radius float32) {
+	if !wm.InsideMapWPos(pos) {
+		return
+	}
+
+	origin := wm.ConvertToMPos(pos)
+	low := &munfall.WPos{X: pos.X - radius, Y: pos.Y - radius}
+	high := &munfall.WPos{X: pos.X + radius, Y: pos.Y + radius}
+	r2 := radius * radius
+	for _, cell := range wm.cellsBetween(low, high) {
+		i := int(cell.pos.X + cell.pos.Y*wm.width)
+		if vision.cells[i] == Visible {
+			continue
+		}
+
+		center := wm.fromGrid(float32(cell.pos.X)+0.5, float32(cell.pos.Y)+0.5)
+		if cellmap.DistanceSquared(center, pos) > r2 || !wm.inLineOfSight(origin, cell.pos) {
+			continue
+		}
+
+		vision.cells[i] = Visible
+		vision.visible = append(vision.visible, i)
+	}
+}
+
+// inLineOfSight returns if no cell between from and to blocks sight, the
+// cells at both ends can't block it so walls themselves can be seen.
+func (wm *worldMap2DGrid) inLineOfSight(from, to *munfall.MPos) bool {
+	x, y := int(from.X), int(from.Y)
+	tx, ty := int(to.X), int(to.Y)
+	dx, dy := abs(tx-x), -abs(ty-y)
+	sx, sy := sign(tx-x), sign(ty-y)
+	err := dx + dy
+	for x != tx || y != ty {
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y += sy
+		}
+
+		if (x != tx || y != ty) && wm.grid[x+y*int(wm.width)].terrain.BlocksSight {
+			return false
+		}
+	}
+
+	return true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func sign(v int) int {
+	if v < 0 {
+		return -1
+	}
+
+	if v > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,163 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+)
+
+// scoutTrait reveals the fog of war for the "Player" parameter within the
+// "Radius" parameter.
+type scoutTrait struct {
+	owner  munfall.Actor
+	player int
+	radius float32
+}
+
+func (s *scoutTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	s.owner = a
+	s.player, _ = parameters["Player"].(int)
+	radius, _ := parameters["Radius"].(float64)
+	s.radius = float32(radius)
+}
+
+func (s *scoutTrait) Owner() munfall.Actor {
+	return s.owner
+}
+
+func (s *scoutTrait) RevealRadius() float32 {
+	return s.radius
+}
+
+func (s *scoutTrait) Player() int {
+	return s.player
+}
+
+// createFogMap returns a map with a wall that blocks sight at x 12, the
+// registry also creates "scout" units that reveal the fog.
+func createFogMap(size uint) *testMap {
+	wm := CreateGridWorldMap(size, size, 1, 1)
+	wall := &munfall.Terrain{Name: "wall", BlocksSight: true}
+	for y := uint(0); y < size; y++ {
+		wm.SetTerrain(&munfall.MPos{X: 12, Y: y}, wall)
+	}
+
+	m := createTestMap(wm)
+	m.ar.RegisterTrait("Scout", (*scoutTrait)(nil))
+	definition := logic.CreateActorDefinition("scout")
+	definition.AddTrait(logic.CreateTraitDefinition("Scout"))
+	definition.AddTrait(logic.CreateTraitDefinition("Unit"))
+	m.ar.RegisterActor(definition)
+	return m
+}
+
+// spawnScout adds a scout of the player at the position to the world.
+func (m *testMap) spawnScout(pos munfall.WPos, player int, radius float64) munfall.Actor {
+	a := m.ar.CreateActor("scout", map[string]interface{}{"Player": player, "Radius": radius}, m.world, false)
+	a.SetPos(&pos)
+	m.world.AddToWorld(a)
+	return a
+}
+
+func TestFogDisabled(t *testing.T) {
+	m := createFogMap(30)
+	if !m.wm.IsVisible(3, &munfall.MPos{X: 29, Y: 29}) {
+		t.Error("cells are hidden without a fog of war")
+	}
+
+	m.wm.SetFogOfWar(true)
+	if m.wm.Visibility(3, &munfall.MPos{X: 29, Y: 29}) != Hidden {
+		t.Error("cells aren't hidden by the fog of war")
+	}
+
+	m.wm.SetFogOfWar(false)
+	if !m.wm.IsVisible(3, &munfall.MPos{X: 29, Y: 29}) {
+		t.Error("the fog of war stays after disabling it")
+	}
+}
+
+func TestRevealRadius(t *testing.T) {
+	m := createFogMap(30)
+	m.wm.SetFogOfWar(true)
+	m.spawnScout(munfall.WPos{X: 5.5, Y: 10.5}, 1, 5)
+	m.world.Tick(1)
+
+	for pos, visible := range map[munfall.MPos]bool{
+		{X: 5, Y: 10}: true, {X: 9, Y: 10}: true, {X: 5, Y: 6}: true, {X: 8, Y: 13}: true,
+		{X: 11, Y: 10}: false, {X: 5, Y: 16}: false, {X: 9, Y: 14}: false,
+	} {
+		if m.wm.IsVisible(1, &pos) != visible {
+			t.Errorf("cell %v visible: %v, expected %v", pos, !visible, visible)
+		}
+	}
+
+	if m.wm.IsVisible(2, &munfall.MPos{X: 5, Y: 10}) {
+		t.Error("the scout reveals the fog for another player")
+	}
+}
+
+func TestWallBlocksSight(t *testing.T) {
+	m := createFogMap(30)
+	m.wm.SetFogOfWar(true)
+	m.spawnScout(munfall.WPos{X: 10.5, Y: 10.5}, 1, 5)
+	enemy := m.spawn(munfall.WPos{X: 14.5, Y: 10.5}, nil)
+	near := m.spawn(munfall.WPos{X: 8.5, Y: 12.5}, nil)
+	m.world.Tick(1)
+
+	if !m.wm.IsVisible(1, &munfall.MPos{X: 12, Y: 10}) {
+		t.Error("the wall itself isn't visible")
+	}
+
+	if m.wm.IsVisible(1, &munfall.MPos{X: 13, Y: 10}) || m.wm.IsActorVisible(1, enemy) {
+		t.Error("the scout sees through the wall")
+	}
+
+	if !m.wm.IsActorVisible(1, near) || m.wm.IsActorVisible(2, near) {
+		t.Error("actors in sight aren't visible to the scout's player only")
+	}
+}
+
+func TestExplored(t *testing.T) {
+	m := createFogMap(30)
+	m.wm.SetFogOfWar(true)
+	scout := m.spawnScout(munfall.WPos{X: 5.5, Y: 10.5}, 1, 5)
+	m.world.Tick(1)
+
+	m.wm.Move(scout, m.wm.CreatePath([]*munfall.WPos{{X: 3.5, Y: 25.5}}), 0)
+	if !m.wm.IsVisible(1, &munfall.MPos{X: 5, Y: 10}) {
+		t.Error("the fog changed before the world ticked")
+	}
+
+	m.world.Tick(1)
+	if got := m.wm.Visibility(1, &munfall.MPos{X: 5, Y: 10}); got != Explored {
+		t.Error("the cell the scout left is", got)
+	}
+
+	if !m.wm.IsVisible(1, &munfall.MPos{X: 3, Y: 25}) {
+		t.Error("the cell the scout moved to isn't visible")
+	}
+
+	scout.Kill()
+	m.world.Tick(1)
+	if got := m.wm.Visibility(1, &munfall.MPos{X: 3, Y: 25}); got != Explored {
+		t.Error("a dead scout left the cell", got)
+	}
+}
+
+func BenchmarkUpdateVisibility(b *testing.B) {
+	m := createFogMap(256)
+	m.wm.SetFogOfWar(true)
+	for i := 0; i < 200; i++ {
+		m.spawnScout(munfall.WPos{X: float32((i*37)%256) + 0.5, Y: float32((i*91)%256) + 0.5}, i%4, 8)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.wm.UpdateVisibility()
+	}
+}
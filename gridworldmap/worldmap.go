@@ -6,29 +6,111 @@
 package gridworldmap
 
 import (
-	"sort"
-
 	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
 	"github.com/bluemun/munfall/traits"
 )
 
+// GridWorldMap is a munfall.WorldMap made of a grid of rectangular cells.
+type GridWorldMap interface {
+	munfall.WorldMap
+
+	// Cells returns the amount of cells along each axis.
+	Cells() (width, height uint)
+	// CellSize returns the size of a single cell in world units.
+	CellSize() (width, height float32)
+
+	// SetCellBlocked marks a cell as impassable, paths are routed around it.
+	SetCellBlocked(pos *munfall.MPos, blocked bool)
+	IsCellBlocked(pos *munfall.MPos) bool
+
+	// SetTerrain sets the terrain of a cell, cells start out as clear
+	// terrain that every movement class can pass at a cost of 1.
+	SetTerrain(pos *munfall.MPos, terrain *munfall.Terrain)
+
+	// CreatePath creates a path through the cells of the given positions.
+	CreatePath(positions []*munfall.WPos) munfall.Path
+
+	// SetPathSearchLimit limits the amount of cells GetPath expands before it
+	// gives up and returns the path to the closest cell it found, 0 means no limit.
+	SetPathSearchLimit(limit int)
+
+	// SetClusterSize enables hierarchical path searches over square clusters
+	// of the given size for long paths, 0 disables them. Hierarchical paths
+	// are refined cell by cell while they are walked.
+	SetClusterSize(size uint)
+
+	// FlowField returns a flow field leading to the goal that can be shared
+	// by every actor of the movement class moving there.
+	FlowField(goal *munfall.WPos, movementClass string) *FlowField
+
+	// SetLayers divides the map into height layers by the Z of spaces, spaces
+	// only block each other when their layers collide. Maps start out with a
+	// single ground layer. Flow fields ignore the layers and treat spaces on
+	// any of them as occupying their cell.
+	SetLayers(layers []Layer)
+	// SetLayerCollision sets if spaces on the two layers collide with each other.
+	SetLayerCollision(a, b string, collide bool)
+	// LayerOf returns the name of the layer that holds the position.
+	LayerOf(pos *munfall.WPos) string
+	// LayersCollide returns if spaces at the two positions are on layers that collide.
+	LayersCollide(a, b *munfall.WPos) bool
+	// InLayer returns an ActorFilter that accepts actors standing on one of
+	// the given layers.
+	InLayer(names ...string) munfall.ActorFilter
+
+	// SetFogOfWar enables a fog of war per player that is lifted around the
+	// actors with RevealsShroud traits, it is updated every world tick.
+	SetFogOfWar(enabled bool)
+	// UpdateVisibility brings the fog of war up to date right away.
+	UpdateVisibility()
+	// Visibility returns how much the player knows about the cell.
+	Visibility(player int, pos *munfall.MPos) Visibility
+	IsVisible(player int, pos *munfall.MPos) bool
+	IsActorVisible(player int, a munfall.Actor) bool
+}
+
 type worldMap2DGrid struct {
 	world           munfall.World
 	cWidth, cHeight float32
 	width, height   uint
 	grid            []*cell2DRectGrid
+	searchLimit     int
+	search          *cellmap.Search
+	clusterSize     uint
+	hierarchies     map[string]*hierarchy
+	flowFields      []*FlowField
+	// terrains holds every terrain used on the map.
+	terrains cellmap.Terrains
+	// passability changes whenever cells are blocked, their terrain changes or
+	// the layers change, occupancy whenever the spaces in a cell change. ticks
+	// counts the world ticks, flow fields catch up with occupancy once per tick.
+	passability uint64
+	occupancy   uint64
+	ticks       uint64
+	// layers is sorted by height, collide holds which layers collide.
+	layers  []Layer
+	collide [][]bool
+	// visions holds the fog of war of every player while fogOfWar is enabled.
+	fogOfWar bool
+	visions  map[int]*playerVision
+
+	// isometric maps project cells as diamonds of tileWidth by tileHeight,
+	// cWidth and cHeight then hold the cost of a step along the grid.
+	isometric             bool
+	tileWidth, tileHeight float32
 }
 
 // CreateGridWorldMap creates a 2D implementation of a munfall.
-func CreateGridWorldMap(width, height uint, cellWidth, cellHeight float32) munfall.WorldMap {
+func CreateGridWorldMap(width, height uint, cellWidth, cellHeight float32) GridWorldMap {
 	grid := make([]*cell2DRectGrid, width*height)
 
 	var init uint
 	for y := init; y < height; y++ {
 		for x := init; x < width; x++ {
 			grid[x+y*width] = &cell2DRectGrid{
-				space: make([]munfall.Space, 0),
-				pos:   &munfall.MPos{X: x, Y: y},
+				pos:     &munfall.MPos{X: x, Y: y},
+				terrain: cellmap.ClearTerrain,
 			}
 		}
 	}
@@ -50,115 +132,181 @@ func CreateGridWorldMap(width, height uint, cellWidth, cellHeight float32) munfa
 		}
 	}
 
-	return &worldMap2DGrid{
-		width:   width,
-		height:  height,
-		cWidth:  cellWidth,
-		cHeight: cellHeight,
-		grid:    grid,
+	wm := &worldMap2DGrid{
+		width:       width,
+		height:      height,
+		cWidth:      cellWidth,
+		cHeight:     cellHeight,
+		grid:        grid,
+		hierarchies: make(map[string]*hierarchy),
+		terrains:    cellmap.Terrains{cellmap.ClearTerrain},
+		visions:     make(map[int]*playerVision),
 	}
+
+	wm.SetLayers(defaultLayers)
+	return wm
 }
 
 func (wm *worldMap2DGrid) Width() float32 {
+	if wm.isometric {
+		return float32(wm.width+wm.height) * wm.tileWidth / 2
+	}
+
 	return float32(wm.width) * wm.cWidth
 }
 
 func (wm *worldMap2DGrid) Height() float32 {
+	if wm.isometric {
+		return float32(wm.width+wm.height) * wm.tileHeight / 2
+	}
+
 	return float32(wm.height) * wm.cHeight
 }
 
+func (wm *worldMap2DGrid) Cells() (width, height uint) {
+	return wm.width, wm.height
+}
+
+func (wm *worldMap2DGrid) CellSize() (width, height float32) {
+	if wm.isometric {
+		return wm.tileWidth, wm.tileHeight
+	}
+
+	return wm.cWidth, wm.cHeight
+}
+
 func (wm *worldMap2DGrid) Initialize(world munfall.World) {
 	wm.world = world
 }
 
+// Tick counts the world ticks and brings the fog of war up to date, it is
+// called by the world after its traits have been ticked.
+func (wm *worldMap2DGrid) Tick(deltaUnit float32) {
+	wm.ticks++
+	if wm.fogOfWar {
+		wm.UpdateVisibility()
+	}
+}
+
 func (wm *worldMap2DGrid) CellAt(pos *munfall.MPos) munfall.Cell {
 	return wm.grid[pos.X+pos.Y*wm.width]
 }
 
-func (wm *worldMap2DGrid) CreatePath(positions []*munfall.WPos) munfall.Path {
-	path := &path2DGrid{
-		m:    wm,
-		cell: wm.CellAt(wm.ConvertToMPos(positions[0])).(*cell2DRectGrid),
-	}
-	path.first = path
-	for _, pos := range positions[1:] {
-		cell := wm.CellAt(wm.ConvertToMPos(pos)).(*cell2DRectGrid)
-		npath := &path2DGrid{
-			m:     wm,
-			cell:  cell,
-			first: path.first,
-		}
-		path.next = npath
-		path = npath
+func (wm *worldMap2DGrid) cellAt(pos *munfall.MPos) *cell2DRectGrid {
+	return wm.grid[pos.X+pos.Y*wm.width]
+}
+
+func (wm *worldMap2DGrid) SetCellBlocked(pos *munfall.MPos, blocked bool) {
+	cell := wm.cellAt(pos)
+	if cell.blocked == blocked {
+		return
 	}
 
-	iter := path.First().(*path2DGrid)
-	for {
-		iter.last = path
-		if iter.next == nil {
-			break
-		}
+	cell.blocked = blocked
+	wm.passabilityChanged(pos)
+}
+
+func (wm *worldMap2DGrid) SetTerrain(pos *munfall.MPos, terrain *munfall.Terrain) {
+	cell := wm.cellAt(pos)
+	if cell.terrain == terrain {
+		return
 	}
 
-	return path.First()
+	wm.terrains.Add(terrain)
+	cell.terrain = terrain
+	wm.passabilityChanged(pos)
 }
 
-func (wm *worldMap2DGrid) GetPath(a munfall.Actor, p1, p2 *munfall.WPos) munfall.Path {
-	path := &path2DGrid{
-		m:    wm,
-		cell: wm.CellAt(wm.ConvertToMPos(p1)).(*cell2DRectGrid),
+// passabilityChanged rebuilds the path search data that depends on the cell.
+func (wm *worldMap2DGrid) passabilityChanged(pos *munfall.MPos) {
+	for _, h := range wm.hierarchies {
+		h.markDirty(pos)
 	}
-	path.first = path
 
-	if p2.X < 0 || p2.Y < 0 || p2.X > (float32)(wm.width)*wm.cWidth || p2.Y > (float32)(wm.height)*wm.cHeight {
-		path.last = path
-		return path
+	wm.passability++
+}
+
+func (wm *worldMap2DGrid) IsCellBlocked(pos *munfall.MPos) bool {
+	return wm.cellAt(pos).blocked
+}
+
+func (wm *worldMap2DGrid) SetPathSearchLimit(limit int) {
+	wm.searchLimit = limit
+}
+
+func (wm *worldMap2DGrid) SetClusterSize(size uint) {
+	wm.clusterSize = size
+	wm.hierarchies = make(map[string]*hierarchy)
+}
+
+// hierarchyFor returns the clusters of the movement class, nil if hierarchical
+// searches are disabled.
+func (wm *worldMap2DGrid) hierarchyFor(class string) *hierarchy {
+	if wm.clusterSize == 0 {
+		return nil
 	}
 
-	lastCell := wm.CellAt(wm.ConvertToMPos(p2)).(*cell2DRectGrid)
+	h, exists := wm.hierarchies[class]
+	if !exists {
+		h = createHierarchy(wm, wm.clusterSize, class)
+		wm.hierarchies[class] = h
+	}
 
-	ts := wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil))
-	offset := p2.Subtract(p1)
-	intersects := false
-Outer:
-	for _, trait := range ts {
-		os := trait.(traits.OccupySpace)
-		if os.OutOfBounds(offset) {
-			path.last = path
-			return path
-		}
+	return h
+}
 
-		for _, osspace := range os.Space() {
-			cell := wm.CellAt(wm.ConvertToMPos(osspace.Offset().Add(offset)))
-			for _, space := range cell.Space() {
-				spacetrait := space.Trait().(traits.OccupySpace)
-				if spacetrait.Owner().ActorID() != os.Owner().ActorID() && os.Intersects(spacetrait, offset) {
-					intersects = true
-					break Outer
-				}
-			}
+func (wm *worldMap2DGrid) CreatePath(positions []*munfall.WPos) munfall.Path {
+	cells := make([]*cell2DRectGrid, len(positions))
+	for i, pos := range positions {
+		cells[i] = wm.cellAt(wm.ConvertToMPos(pos))
+	}
+
+	path := wm.createPath(cells, 0)
+	for node, i := path, 0; node != nil; node, i = node.next, i+1 {
+		node.z = positions[i].Z
+	}
+
+	return path
+}
+
+// GetPath finds a path for the actor from p1 to p2 that goes around blocked
+// cells and cells its OccupySpace traits would intersect with. When p2 can't
+// be reached the path leads to the closest cell that can. The path stays at
+// the height of p1.
+func (wm *worldMap2DGrid) GetPath(a munfall.Actor, p1, p2 *munfall.WPos) munfall.Path {
+	start := wm.cellAt(wm.ConvertToMPos(p1))
+	if !wm.InsideMapWPos(p2) {
+		return wm.createPath([]*cell2DRectGrid{start}, p1.Z)
+	}
+
+	goal := wm.cellAt(wm.ConvertToMPos(p2))
+	if h := wm.hierarchyFor(traits.MovementClass(a)); h != nil && h.isFar(start, goal) {
+		if route := h.findRoute(start, goal); route != nil {
+			return wm.createRoutePath(a, route, p1.Z)
 		}
 	}
 
-	if !intersects {
-		path.last = &path2DGrid{
-			m:     wm,
-			cell:  lastCell,
-			first: path,
+	return wm.createPath(wm.findPath(a, start, goal, wm.searchLimit), p1.Z)
+}
+
+// createPath creates a path through the cells at the height z.
+func (wm *worldMap2DGrid) createPath(cells []*cell2DRectGrid, z float32) *path2DGrid {
+	nodes := make([]path2DGrid, len(cells))
+	first, last := &nodes[0], &nodes[len(nodes)-1]
+	for i, cell := range cells {
+		nodes[i] = path2DGrid{m: wm, first: first, last: last, cell: cell, z: z}
+		if i > 0 {
+			nodes[i-1].next = &nodes[i]
 		}
-		path.last.last = path.last
-		path.next = path.last
-	} else {
-		munfall.Logger.Debug("Intersection happened", path.MPos())
-		path.last = path
 	}
 
-	return path
+	return first
 }
 
 func (wm *worldMap2DGrid) InsideMapWPos(pos *munfall.WPos) bool {
-	return pos.X < float32(wm.width)*wm.cWidth && pos.X >= 0 &&
-		pos.Y < float32(wm.height)*wm.cHeight && pos.Y >= 0
+	x, y := wm.toGrid(pos)
+	return x < float32(wm.width) && x >= 0 &&
+		y < float32(wm.height) && y >= 0
 }
 
 func (wm *worldMap2DGrid) InsideMapMPos(pos *munfall.MPos) bool {
@@ -167,15 +315,43 @@ func (wm *worldMap2DGrid) InsideMapMPos(pos *munfall.MPos) bool {
 }
 
 func (wm *worldMap2DGrid) ConvertToWPos(m *munfall.MPos) *munfall.WPos {
-	return &munfall.WPos{X: wm.cWidth * float32(m.X), Y: wm.cHeight * float32(m.Y)}
+	return wm.fromGrid(float32(m.X), float32(m.Y))
 }
 
 func (wm *worldMap2DGrid) ConvertToMPos(w *munfall.WPos) *munfall.MPos {
-	low := munfall.WPos{}
-	high := munfall.WPos{X: wm.cWidth * float32(wm.width), Y: wm.cHeight * float32(wm.height)}
-	realw := w.Clamp(&low, &high)
-	npos := &munfall.MPos{X: uint(realw.X / wm.cWidth), Y: uint(realw.Y / wm.cHeight)}
-	return npos
+	x, y := wm.toGrid(w)
+	return &munfall.MPos{X: clampCell(x, wm.width), Y: clampCell(y, wm.height)}
+}
+
+// toGrid returns the position in cells, the integer part is the cell that holds it.
+func (wm *worldMap2DGrid) toGrid(w *munfall.WPos) (x, y float32) {
+	if wm.isometric {
+		return wm.isometricToGrid(w)
+	}
+
+	return w.X / wm.cWidth, w.Y / wm.cHeight
+}
+
+// fromGrid returns the world position of the position in cells.
+func (wm *worldMap2DGrid) fromGrid(x, y float32) *munfall.WPos {
+	if wm.isometric {
+		return wm.isometricFromGrid(x, y)
+	}
+
+	return &munfall.WPos{X: wm.cWidth * x, Y: wm.cHeight * y}
+}
+
+// clampCell returns the cell at v in cells, clamped to the size of the map.
+func clampCell(v float32, size uint) uint {
+	if v < 0 {
+		return 0
+	}
+
+	if v >= float32(size) {
+		return size - 1
+	}
+
+	return uint(v)
 }
 
 func (wm *worldMap2DGrid) Register(a munfall.Actor) {
@@ -184,39 +360,61 @@ func (wm *worldMap2DGrid) Register(a munfall.Actor) {
 		for _, space := range os.Space() {
 			cell := wm.CellAt(wm.ConvertToMPos(space.Offset())).(*cell2DRectGrid)
 			cell.AddSpace(space)
+			wm.occupancyChanged()
 		}
 	}
 }
 
 func (wm *worldMap2DGrid) Move(a munfall.Actor, p munfall.Path, percent float32) {
-	old := a.Pos()
 	path, exists := p.(*path2DGrid)
 	if !exists {
 		munfall.Logger.Panic("Tried using", p, "on a GridWorldMap, it requires a *path2DGrid type.")
 	}
 
+	wm.setPos(a, path.WPos(percent))
+}
+
+// setPos moves the actor to the given position, updating the cells it occupies,
+// and moves the actors attached to it along.
+func (wm *worldMap2DGrid) setPos(a munfall.Actor, pos *munfall.WPos) {
+	old := a.Pos()
 	spacetraits := wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil))
+	var cells []*cell2DRectGrid
 	for _, trait := range spacetraits {
-		os := trait.(traits.OccupySpace)
-		for _, space := range os.Space() {
-			cell := wm.CellAt(wm.ConvertToMPos(space.Offset())).(*cell2DRectGrid)
-			cell.RemoveSpace(space)
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			cells = append(cells, wm.CellAt(wm.ConvertToMPos(space.Offset())).(*cell2DRectGrid))
 		}
 	}
 
-	a.SetPos(path.WPos(percent))
+	a.SetPos(pos)
 
+	// Spaces that stay in their cell are left alone so the occupancy only
+	// changes when actors move between cells.
+	i := 0
 	for _, trait := range spacetraits {
-		os := trait.(traits.OccupySpace)
-		for _, space := range os.Space() {
+		for _, space := range trait.(traits.OccupySpace).Space() {
 			cell := wm.CellAt(wm.ConvertToMPos(space.Offset())).(*cell2DRectGrid)
-			cell.AddSpace(space)
+			if cell != cells[i] {
+				cells[i].RemoveSpace(space)
+				cell.AddSpace(space)
+				wm.occupancyChanged()
+			}
+
+			i++
 		}
 	}
 
 	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.MoveNotifier)(nil)) {
 		trait.(traits.MoveNotifier).NotifyMove(old, a.Pos())
 	}
+
+	for _, child := range a.Children() {
+		if child.IsInWorld() {
+			wm.setPos(child, pos.Add(child.AttachOffset()))
+		} else {
+			child.SetPos(pos.Add(child.AttachOffset()))
+		}
+	}
 }
 
 func (wm *worldMap2DGrid) Deregister(a munfall.Actor) {
@@ -225,6 +423,7 @@ func (wm *worldMap2DGrid) Deregister(a munfall.Actor) {
 		for _, space := range os.Space() {
 			cell := wm.CellAt(wm.ConvertToMPos(space.Offset())).(*cell2DRectGrid)
 			cell.RemoveSpace(space)
+			wm.occupancyChanged()
 		}
 	}
 }
@@ -236,6 +435,17 @@ type path2DGrid struct {
 
 	cell *cell2DRectGrid
 	next *path2DGrid
+	// z is the height the path passes the cell at.
+	z float32
+	// route is set on hierarchical paths that are still being refined.
+	route *pathRoute
+}
+
+// advance refines a hierarchical path until this node has a next node or
+// the path is complete.
+func (p *path2DGrid) advance() {
+	for p.next == nil && p.route != nil && p.route.refine() {
+	}
 }
 
 func (p *path2DGrid) Cell() munfall.Cell {
@@ -248,11 +458,15 @@ func (p *path2DGrid) MPos() *munfall.MPos {
 
 func (p *path2DGrid) WPos(percent float32) *munfall.WPos {
 	start := p.m.ConvertToWPos(p.cell.pos)
+	start.Z = p.z
+	p.advance()
 	if p.next == nil {
 		return start
 	}
 
-	offset := start.Subtract(p.m.ConvertToWPos(p.next.cell.pos))
+	end := p.m.ConvertToWPos(p.next.cell.pos)
+	end.Z = p.next.z
+	offset := end.Subtract(start)
 	offset.X *= percent
 	offset.Y *= percent
 	offset.Z *= percent
@@ -260,10 +474,12 @@ func (p *path2DGrid) WPos(percent float32) *munfall.WPos {
 }
 
 func (p *path2DGrid) IsEnd() bool {
+	p.advance()
 	return p.next == nil
 }
 
 func (p *path2DGrid) Next() munfall.Path {
+	p.advance()
 	return p.next
 }
 
@@ -271,70 +487,42 @@ func (p *path2DGrid) First() munfall.Path {
 	return p.first
 }
 
+// Last returns the last node, hierarchical paths are refined completely.
 func (p *path2DGrid) Last() munfall.Path {
+	if p.route != nil {
+		for p.route.refine() {
+		}
+
+		return p.route.tail
+	}
+
 	return p.last
 }
 
 type cell2DRectGrid struct {
+	cellmap.Spaces
 	pos            *munfall.MPos
 	lc, rc, tc, bc *cell2DRectGrid
-	space          []munfall.Space
+	blocked        bool
+	terrain        *munfall.Terrain
 }
 
-func (c *cell2DRectGrid) AdjacentCells() []munfall.Cell {
-	cells := make([]munfall.Cell, 4)
-	cells[0] = c.lc
-	cells[0] = c.rc
-	cells[0] = c.tc
-	cells[0] = c.bc
-	return cells
+func (c *cell2DRectGrid) Terrain() *munfall.Terrain {
+	return c.terrain
 }
 
-func (c *cell2DRectGrid) Space() []munfall.Space {
-	return c.space
+// Passable returns if actors of the movement class can enter the cell.
+func (c *cell2DRectGrid) Passable(class string) bool {
+	return !c.blocked && c.terrain.CanPass(class)
 }
 
-func (c *cell2DRectGrid) AddSpace(s munfall.Space) {
-	length := len(c.space)
-	id := s.Trait().Owner().ActorID()
-
-	if length == 0 {
-		c.space = make([]munfall.Space, 1)
-		c.space[0] = s
-		return
-	}
-
-	i := sort.Search(length, func(i int) bool {
-		return id == c.space[i].Trait().Owner().ActorID()
-	})
-
-	if i == length {
-		slice := make([]munfall.Space, 1)
-		slice[0] = s
-		c.space = append(slice, c.space...)
-	} else if i == length-1 {
-		c.space = append(c.space, s)
-	} else {
-		c.space = append(c.space, nil)
-		copy(c.space[i+1:], c.space[i:])
-		c.space[i] = s
+func (c *cell2DRectGrid) AdjacentCells() []munfall.Cell {
+	cells := make([]munfall.Cell, 0, 4)
+	for _, cell := range [...]*cell2DRectGrid{c.lc, c.rc, c.tc, c.bc} {
+		if cell != nil {
+			cells = append(cells, cell)
+		}
 	}
-}
-
-func (c *cell2DRectGrid) RemoveSpace(s munfall.Space) {
-	length := len(c.space)
-	id := s.Trait().Owner().ActorID()
-	index := sort.Search(length, func(arg2 int) bool {
-		return id == c.space[arg2].Trait().Owner().ActorID()
-	})
 
-	if index == -1 {
-		return
-	} else if index == 0 {
-		c.space = c.space[1:]
-	} else if index+1 == length {
-		c.space = c.space[:index]
-	} else {
-		c.space = append(c.space[:index-1], c.space[index+1:]...)
-	}
+	return cells
 }
@@ -0,0 +1,110 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package gridworldmap
+
+import (
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+	"github.com/bluemun/munfall/traits"
+)
+
+// unitTrait occupies the cell at the position of its actor, the optional
+// "Class" parameter sets its movement class.
+type unitTrait struct {
+	owner  munfall.Actor
+	class  string
+	spaces []munfall.Space
+}
+
+func (u *unitTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	u.owner = a
+	u.class, _ = parameters["Class"].(string)
+	space := &traits.SpaceCell{LocalOffset: &munfall.WPos{}}
+	space.Initialize(u)
+	u.spaces = []munfall.Space{space}
+}
+
+func (u *unitTrait) Owner() munfall.Actor {
+	return u.owner
+}
+
+func (u *unitTrait) Intersects(other traits.OccupySpace, offset *munfall.WPos) bool {
+	for _, a := range u.spaces {
+		for _, b := range other.Space() {
+			if a.Intersects(b, offset) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (u *unitTrait) Space() []munfall.Space {
+	return u.spaces
+}
+
+func (u *unitTrait) OutOfBounds(offset *munfall.WPos) bool {
+	return !u.owner.World().WorldMap().InsideMapWPos(u.owner.Pos().Add(offset))
+}
+
+func (u *unitTrait) MovementClass() string {
+	return u.class
+}
+
+// testMap is a map in a world with a registry that creates "unit" actors.
+type testMap struct {
+	wm    GridWorldMap
+	world munfall.World
+	ar    *logic.ActorRegistry
+}
+
+func createTestMap(wm GridWorldMap) *testMap {
+	ar := logic.CreateActorRegistry()
+	ar.RegisterTrait("Unit", (*unitTrait)(nil))
+	definition := logic.CreateActorDefinition("unit")
+	definition.AddTrait(logic.CreateTraitDefinition("Unit"))
+	ar.RegisterActor(definition)
+	return &testMap{wm: wm, world: logic.CreateWorld(wm), ar: ar}
+}
+
+// spawn adds a unit at the position to the world, parameters may be nil.
+func (m *testMap) spawn(pos munfall.WPos, parameters map[string]interface{}) munfall.Actor {
+	a := m.ar.CreateActor("unit", parameters, m.world, false)
+	a.SetPos(&pos)
+	m.world.AddToWorld(a)
+	return a
+}
+
+// cellsOf returns the cells the path goes through.
+func cellsOf(p munfall.Path) []munfall.MPos {
+	var out []munfall.MPos
+	for ; p != nil && !munfall.IsNil(p); p = p.Next() {
+		out = append(out, *p.MPos())
+		if p.IsEnd() {
+			break
+		}
+	}
+
+	return out
+}
+
+// checkSteps fails the test if the path jumps over cells or goes through a blocked cell.
+func checkSteps(t *testing.T, wm GridWorldMap, cells []munfall.MPos) {
+	t.Helper()
+	for i := 1; i < len(cells); i++ {
+		dx := int(cells[i].X) - int(cells[i-1].X)
+		dy := int(cells[i].Y) - int(cells[i-1].Y)
+		if dx*dx+dy*dy != 1 {
+			t.Fatalf("path jumps from %v to %v", cells[i-1], cells[i])
+		}
+
+		if wm.IsCellBlocked(&cells[i]) {
+			t.Fatalf("path goes through blocked cell %v", cells[i])
+		}
+	}
+}
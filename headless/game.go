@@ -0,0 +1,109 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package headless game.go Defines a game that runs the simulation without a
+// window or renderer, it doesn't depend on OpenGL so it can be built for servers
+// and CI machines without cgo.
+package headless
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+)
+
+// OrderSource provides the orders issued to the world after every tick, an
+// input.OrderGenerator or a network connection for example.
+type OrderSource interface {
+	GetOrders() []*munfall.Order
+}
+
+// Game type used to hold the components needed to run a game without a window.
+type Game struct {
+	actorRegistry *logic.ActorRegistry
+	orderSource   OrderSource
+	world         munfall.World
+	stop          chan struct{}
+	stopOnce      sync.Once
+}
+
+// Initialize initializes the game.
+func (g *Game) Initialize(wm munfall.WorldMap) {
+	g.actorRegistry = logic.CreateActorRegistry()
+	g.world = logic.CreateWorld(wm)
+	g.stop = make(chan struct{})
+}
+
+// Start starts the game loop, ticking the world framerate times per second
+// until Stop is called, it returns an error right away if framerate isn't
+// positive.
+func (g *Game) Start(framerate int64) error {
+	if framerate <= 0 {
+		return fmt.Errorf("headless: framerate must be positive, got %d", framerate)
+	}
+
+	ticker := time.NewTicker(time.Second / (time.Duration)(framerate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stop:
+			return nil
+		case <-ticker.C:
+			g.Step(1.0 / (float32)(framerate))
+		}
+	}
+}
+
+// StartUnthrottled ticks the world as fast as possible with a fixed deltaUnit
+// until Stop is called.
+func (g *Game) StartUnthrottled(deltaUnit float32) {
+	for {
+		select {
+		case <-g.stop:
+			return
+		default:
+			g.Step(deltaUnit)
+		}
+	}
+}
+
+// Step advances the game by a single tick, it issues the orders of the order
+// source after ticking the world.
+func (g *Game) Step(deltaUnit float32) {
+	g.world.Tick(deltaUnit)
+	if g.orderSource != nil {
+		for _, order := range g.orderSource.GetOrders() {
+			g.world.IssueGlobalOrder(order)
+		}
+	}
+}
+
+// Stop makes the running game loop return, safe to call from any goroutine.
+func (g *Game) Stop() {
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// SetOrderSource sets the source of the orders issued to the world.
+func (g *Game) SetOrderSource(source OrderSource) {
+	g.orderSource = source
+}
+
+// ActorRegistry returns the inner actor registry for the game.
+func (g *Game) ActorRegistry() *logic.ActorRegistry {
+	return g.actorRegistry
+}
+
+// World returns the underlying world.
+func (g *Game) World() munfall.World {
+	return g.world
+}
+
+// WorldMap returns the underlying worldmap.
+func (g *Game) WorldMap() munfall.WorldMap {
+	return g.world.WorldMap()
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package headless
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/gridworldmap"
+	"github.com/bluemun/munfall/logic"
+)
+
+type orderList []*munfall.Order
+
+func (l orderList) GetOrders() []*munfall.Order {
+	return l
+}
+
+type ticking struct {
+	owner  munfall.Actor
+	ticks  int
+	orders int
+}
+
+func (t *ticking) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	t.owner = a
+}
+
+func (t *ticking) Owner() munfall.Actor {
+	return t.owner
+}
+
+func (t *ticking) Tick(deltaUnit float32) {
+	t.ticks++
+}
+
+func (t *ticking) ResolveOrder(order *munfall.Order) {
+	t.orders++
+}
+
+func TestStepAndStop(t *testing.T) {
+	g := &Game{}
+	g.Initialize(gridworldmap.CreateGridWorldMap(4, 4, 1, 1))
+	g.SetOrderSource(orderList{{Order: "Test"}})
+	g.ActorRegistry().RegisterTrait("Ticking", (*ticking)(nil))
+	definition := logic.CreateActorDefinition("ticking")
+	definition.AddTrait(logic.CreateTraitDefinition("Ticking"))
+	g.ActorRegistry().RegisterActor(definition)
+	a := g.ActorRegistry().CreateActor("ticking", nil, g.World(), true)
+	trait := g.World().GetTrait(a, (*ticking)(nil)).(*ticking)
+	g.Step(1)
+	g.Step(1)
+	if trait.ticks != 2 || trait.orders != 2 {
+		t.Fatalf("expected 2 ticks and orders, got %d and %d", trait.ticks, trait.orders)
+	}
+
+	done := make(chan bool)
+	go func() {
+		g.StartUnthrottled(1)
+		done <- true
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	g.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't end the game loop")
+	}
+}
+
+func TestStartRejectsFramerate(t *testing.T) {
+	g := &Game{}
+	g.Initialize(gridworldmap.CreateGridWorldMap(4, 4, 1, 1))
+	for _, framerate := range []int64{0, -30} {
+		if err := g.Start(framerate); err == nil {
+			t.Errorf("Start accepted framerate %d", framerate)
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- g.Start(1000)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	g.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("stopped game loop returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't end the game loop")
+	}
+}
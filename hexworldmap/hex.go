@@ -0,0 +1,136 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package hexworldmap hex.go Defines axial hex coordinates and the layout of
+// hexes in the world.
+package hexworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+)
+
+var sqrt3 = math.Sqrt(3)
+
+// Orientation defines if hexes have a corner or a flat side at the top.
+type Orientation int
+
+const (
+	// Pointy hexes have a corner at the top, they form rows that are shifted
+	// by half a hex on every odd row.
+	Pointy Orientation = iota
+	// Flat hexes have a flat side at the top, they form columns that are
+	// shifted by half a hex on every odd column.
+	Flat
+)
+
+// Hex is a hex in axial coordinates, the third cube coordinate is -Q-R.
+type Hex struct {
+	Q, R int
+}
+
+// directions holds the offsets to the six neighbours of a hex.
+var directions = [...]Hex{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}
+
+// Add returns the sum of the two hexes.
+func (h Hex) Add(other Hex) Hex {
+	return Hex{h.Q + other.Q, h.R + other.R}
+}
+
+// Neighbours returns the six hexes next to this one.
+func (h Hex) Neighbours() [6]Hex {
+	var out [6]Hex
+	for i, d := range directions {
+		out[i] = h.Add(d)
+	}
+
+	return out
+}
+
+// Distance returns the amount of steps between the two hexes.
+func (h Hex) Distance(other Hex) int {
+	dq, dr := h.Q-other.Q, h.R-other.R
+	return (abs(dq) + abs(dr) + abs(dq+dr)) / 2
+}
+
+// hexRound returns the hex that contains the fractional axial coordinates.
+func hexRound(q, r float64) Hex {
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+
+	return Hex{int(rq), int(rr)}
+}
+
+// offset converts a hex to the column and row it has on a rectangular map,
+// odd rows of pointy maps and odd columns of flat maps are shifted forward.
+func (o Orientation) offset(h Hex) (col, row int) {
+	if o == Flat {
+		return h.Q, h.R + (h.Q-h.Q&1)/2
+	}
+
+	return h.Q + (h.R-h.R&1)/2, h.R
+}
+
+// axial converts a column and row of a rectangular map to a hex.
+func (o Orientation) axial(col, row int) Hex {
+	if o == Flat {
+		return Hex{col, row - (col-col&1)/2}
+	}
+
+	return Hex{col - (row-row&1)/2, row}
+}
+
+// center returns the center of the hex relative to the center of hex 0,0.
+func (o Orientation) center(h Hex, size float64) (x, y float64) {
+	q, r := float64(h.Q), float64(h.R)
+	if o == Flat {
+		return size * 1.5 * q, size * sqrt3 * (r + q/2)
+	}
+
+	return size * sqrt3 * (q + r/2), size * 1.5 * r
+}
+
+// hexAt returns the hex containing the point relative to the center of hex 0,0.
+func (o Orientation) hexAt(x, y, size float64) Hex {
+	if o == Flat {
+		return hexRound(x*2/3/size, (-x/3+sqrt3/3*y)/size)
+	}
+
+	return hexRound((sqrt3/3*x-y/3)/size, y*2/3/size)
+}
+
+// HexOf returns the hex of the cell at the map position.
+func (wm *worldMapHex) HexOf(pos *munfall.MPos) Hex {
+	return wm.orientation.axial(int(pos.X), int(pos.Y))
+}
+
+// MPosOf returns the map position of the hex, nil if it is outside the map.
+func (wm *worldMapHex) MPosOf(h Hex) *munfall.MPos {
+	col, row := wm.orientation.offset(h)
+	if col < 0 || row < 0 || col >= int(wm.width) || row >= int(wm.height) {
+		return nil
+	}
+
+	return &munfall.MPos{X: uint(col), Y: uint(row)}
+}
+
+// Distance returns the amount of steps between the cells at the two map positions.
+func (wm *worldMapHex) Distance(from, to *munfall.MPos) int {
+	return wm.HexOf(from).Distance(wm.HexOf(to))
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package hexworldmap pathfinding.go Defines the A* search used by GetPath.
+package hexworldmap
+
+import (
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+)
+
+// searchGraph lets the shared search walk the hexes of the map.
+type searchGraph struct {
+	wm *worldMapHex
+}
+
+func (g searchGraph) Cell(i int) cellmap.Cell {
+	return g.wm.grid[i]
+}
+
+func (g searchGraph) MPos(i int) *munfall.MPos {
+	return g.wm.grid[i].pos
+}
+
+func (g searchGraph) Neighbours(i int, visit func(next int, step float32)) {
+	step := g.wm.stepCost()
+	for _, next := range g.wm.grid[i].neighbours {
+		visit(g.wm.index(next), step)
+	}
+}
+
+func (g searchGraph) Heuristic(from, to int) float32 {
+	return float32(g.wm.Distance(g.wm.grid[from].pos, g.wm.grid[to].pos)) * g.wm.stepCost()
+}
+
+func (g searchGraph) MinMovementCost(class string) float32 {
+	return g.wm.terrains.MinMovementCost(class)
+}
+
+// stepCost returns the distance between the centers of two neighbouring hexes.
+func (wm *worldMapHex) stepCost() float32 {
+	return wm.size * float32(sqrt3)
+}
+
+// index returns the index of the cell in the grid.
+func (wm *worldMapHex) index(c *cellHex) int {
+	return int(c.pos.X + c.pos.Y*wm.width)
+}
+
+// findPath runs A* over the cells from start to goal, it returns the cells of
+// the path including start. If the goal can't be reached or limit cells have
+// been expanded, the path to the cell closest to the goal is returned instead.
+func (wm *worldMapHex) findPath(a munfall.Actor, start, goal *cellHex, limit int) []*cellHex {
+	if wm.search == nil {
+		wm.search = cellmap.CreateSearch(len(wm.grid))
+	}
+
+	path := wm.search.FindPath(searchGraph{wm}, cellmap.CreateFootprint(wm, wm.world, a), wm.index(start), wm.index(goal), limit)
+	cells := make([]*cellHex, len(path))
+	for i, j := range path {
+		cells[i] = wm.grid[j]
+	}
+
+	return cells
+}
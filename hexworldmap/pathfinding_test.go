@@ -0,0 +1,172 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package hexworldmap
+
+import (
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+	"github.com/bluemun/munfall/traits"
+)
+
+// unitTrait occupies the hex at the position of its actor.
+type unitTrait struct {
+	owner  munfall.Actor
+	spaces []munfall.Space
+}
+
+func (u *unitTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	u.owner = a
+	space := &traits.SpaceCell{LocalOffset: &munfall.WPos{}}
+	space.Initialize(u)
+	u.spaces = []munfall.Space{space}
+}
+
+func (u *unitTrait) Owner() munfall.Actor {
+	return u.owner
+}
+
+func (u *unitTrait) Intersects(other traits.OccupySpace, offset *munfall.WPos) bool {
+	for _, a := range u.spaces {
+		for _, b := range other.Space() {
+			if a.Intersects(b, offset) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (u *unitTrait) Space() []munfall.Space {
+	return u.spaces
+}
+
+func (u *unitTrait) OutOfBounds(offset *munfall.WPos) bool {
+	return !u.owner.World().WorldMap().InsideMapWPos(u.owner.Pos().Add(offset))
+}
+
+// createWalledMap returns a map with a wall at column 5 that is open in the
+// last row, along with a function that spawns units on hexes.
+func createWalledMap() (HexWorldMap, func(pos munfall.MPos) munfall.Actor) {
+	wm := CreateHexWorldMap(10, 10, 1, Pointy)
+	for y := uint(0); y < 9; y++ {
+		wm.SetCellBlocked(&munfall.MPos{X: 5, Y: y}, true)
+	}
+
+	world := logic.CreateWorld(wm)
+	ar := logic.CreateActorRegistry()
+	ar.RegisterTrait("Unit", (*unitTrait)(nil))
+	definition := logic.CreateActorDefinition("unit")
+	definition.AddTrait(logic.CreateTraitDefinition("Unit"))
+	ar.RegisterActor(definition)
+	return wm, func(pos munfall.MPos) munfall.Actor {
+		a := ar.CreateActor("unit", nil, world, false)
+		a.SetPos(wm.ConvertToWPos(&pos))
+		world.AddToWorld(a)
+		return a
+	}
+}
+
+// cellsOf returns the hexes the path goes through.
+func cellsOf(p munfall.Path) []munfall.MPos {
+	var out []munfall.MPos
+	for ; p != nil && !munfall.IsNil(p); p = p.Next() {
+		out = append(out, *p.MPos())
+		if p.IsEnd() {
+			break
+		}
+	}
+
+	return out
+}
+
+func TestPathAroundWall(t *testing.T) {
+	wm, spawn := createWalledMap()
+	a := spawn(munfall.MPos{X: 1, Y: 1})
+	goal := munfall.MPos{X: 8, Y: 1}
+	cells := cellsOf(wm.GetPath(a, a.Pos(), wm.ConvertToWPos(&goal)))
+	if cells[len(cells)-1] != goal {
+		t.Fatal("path doesn't reach the goal:", cells)
+	}
+
+	for i := 1; i < len(cells); i++ {
+		if wm.Distance(&cells[i-1], &cells[i]) != 1 || wm.IsCellBlocked(&cells[i]) {
+			t.Fatalf("bad step from %v to %v", cells[i-1], cells[i])
+		}
+	}
+
+	if shortest := steps(wm, &cells[0], &goal); len(cells)-1 != shortest {
+		t.Errorf("path takes %v steps, the shortest takes %v", len(cells)-1, shortest)
+	}
+}
+
+// steps returns the least amount of steps between two hexes that avoids
+// blocked hexes, found with a breadth first search.
+func steps(wm HexWorldMap, from, to *munfall.MPos) int {
+	dist := map[munfall.Cell]int{wm.CellAt(from): 0}
+	queue := []munfall.Cell{wm.CellAt(from)}
+	blocked := make(map[munfall.Cell]bool)
+	for y := uint(0); y < 10; y++ {
+		for x := uint(0); x < 10; x++ {
+			pos := &munfall.MPos{X: x, Y: y}
+			blocked[wm.CellAt(pos)] = wm.IsCellBlocked(pos)
+		}
+	}
+
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		if cell == wm.CellAt(to) {
+			return dist[cell]
+		}
+
+		for _, next := range cell.AdjacentCells() {
+			if _, seen := dist[next]; !seen && !blocked[next] {
+				dist[next] = dist[cell] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return -1
+}
+
+func TestPathBlockedByUnit(t *testing.T) {
+	wm, spawn := createWalledMap()
+	a := spawn(munfall.MPos{X: 1, Y: 1})
+	spawn(munfall.MPos{X: 5, Y: 9})
+	cells := cellsOf(wm.GetPath(a, a.Pos(), wm.ConvertToWPos(&munfall.MPos{X: 8, Y: 1})))
+	if last := cells[len(cells)-1]; last.X >= 5 {
+		t.Error("path went through the unit in the gap:", cells)
+	}
+}
+
+func TestPathAtHeight(t *testing.T) {
+	wm, spawn := createWalledMap()
+	a := spawn(munfall.MPos{X: 1, Y: 1})
+	a.SetPos(&munfall.WPos{X: a.Pos().X, Y: a.Pos().Y, Z: 5})
+	cells := cellsOf(wm.GetPath(a, a.Pos(), wm.ConvertToWPos(&munfall.MPos{X: 1, Y: 4})))
+	if len(cells) != 4 {
+		t.Error("a unit above the map didn't find the straight path:", cells)
+	}
+}
+
+func TestPathTerrain(t *testing.T) {
+	wm, spawn := createWalledMap()
+	swamp := &munfall.Terrain{Name: "swamp", Passable: true, MovementCosts: map[string]float32{"": 10}}
+	for y := uint(0); y < 9; y++ {
+		wm.SetTerrain(&munfall.MPos{X: 3, Y: y}, swamp)
+	}
+
+	a := spawn(munfall.MPos{X: 1, Y: 4})
+	cells := cellsOf(wm.GetPath(a, a.Pos(), wm.ConvertToWPos(&munfall.MPos{X: 4, Y: 4})))
+	for _, cell := range cells {
+		if cell.X == 3 && cell.Y < 9 {
+			t.Fatal("path crosses the swamp instead of going around it:", cells)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package hexworldmap queries.go Defines spatial queries that find actors
+// by the cells they occupy.
+package hexworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+)
+
+// ActorsInRadius returns every actor with a space whose offset lies within
+// radius of center, only actors occupying space on the map can be found.
+func (wm *worldMapHex) ActorsInRadius(center *munfall.WPos, radius float32, filter munfall.ActorFilter) []munfall.Actor {
+	low := &munfall.WPos{X: center.X - radius, Y: center.Y - radius}
+	high := &munfall.WPos{X: center.X + radius, Y: center.Y + radius}
+	r2 := radius * radius
+	return wm.collect(wm.cellsBetween(low, high), func(space munfall.Space) bool {
+		return cellmap.DistanceSquared(space.Offset(), center) <= r2
+	}, filter)
+}
+
+// ActorsInRect returns every actor with a space whose offset lies inside
+// the given rectangle, only actors occupying space on the map can be found.
+func (wm *worldMapHex) ActorsInRect(topLeft, bottomRight *munfall.WPos, filter munfall.ActorFilter) []munfall.Actor {
+	return wm.collect(wm.cellsBetween(topLeft, bottomRight), func(space munfall.Space) bool {
+		pos := space.Offset()
+		return pos.X >= topLeft.X && pos.X <= bottomRight.X &&
+			pos.Y >= topLeft.Y && pos.Y <= bottomRight.Y
+	}, filter)
+}
+
+// ActorsInCells returns every actor occupying one of the given cells,
+// cells outside of the map are ignored.
+func (wm *worldMapHex) ActorsInCells(cells []*munfall.MPos, filter munfall.ActorFilter) []munfall.Actor {
+	grid := make([]*cellHex, 0, len(cells))
+	for _, pos := range cells {
+		if wm.InsideMapMPos(pos) {
+			grid = append(grid, wm.cellAt(pos))
+		}
+	}
+
+	return wm.collect(grid, func(munfall.Space) bool { return true }, filter)
+}
+
+// NearestActor returns the actor closest to pos that is accepted by the filter,
+// searching outwards ring by ring of hexes up to maxDistance, returns nil if
+// none was found.
+func (wm *worldMapHex) NearestActor(pos *munfall.WPos, maxDistance float32, filter munfall.ActorFilter) munfall.Actor {
+	center := wm.hexAt(pos)
+	rings := int(math.Ceil(float64((maxDistance+2*wm.size)/(1.5*wm.size)))) + 1
+	if limit := int(wm.width + wm.height); rings > limit {
+		rings = limit
+	}
+
+	var best munfall.Actor
+	bestDistance := maxDistance * maxDistance
+	rejected := make(map[uint]bool)
+	for ring := 0; ring <= rings; ring++ {
+		if best != nil {
+			// The centers of hexes in this ring are at least 1.5 sizes per ring
+			// away from the center hex, which is at most a size away from pos.
+			minDistance := (1.5*float32(ring) - 2) * wm.size
+			if minDistance > 0 && minDistance*minDistance > bestDistance {
+				break
+			}
+		}
+
+		wm.visitRing(center, ring, func(cell *cellHex) {
+			for _, space := range cell.Space() {
+				owner := space.Trait().Owner()
+				if rejected[owner.ActorID()] {
+					continue
+				}
+
+				d := cellmap.DistanceSquared(space.Offset(), pos)
+				if d > bestDistance || (best != nil && d == bestDistance) {
+					continue
+				}
+
+				if filter != nil && !filter(owner) {
+					rejected[owner.ActorID()] = true
+					continue
+				}
+
+				best, bestDistance = owner, d
+			}
+		})
+	}
+
+	return best
+}
+
+// visitRing calls f for every cell of the map at the given distance in steps
+// from center.
+func (wm *worldMapHex) visitRing(center Hex, ring int, f func(*cellHex)) {
+	visit := func(h Hex) {
+		if pos := wm.MPosOf(h); pos != nil {
+			f(wm.cellAt(pos))
+		}
+	}
+
+	if ring == 0 {
+		visit(center)
+		return
+	}
+
+	h := Hex{center.Q + directions[4].Q*ring, center.R + directions[4].R*ring}
+	for _, d := range directions {
+		for i := 0; i < ring; i++ {
+			visit(h)
+			h = h.Add(d)
+		}
+	}
+}
+
+// cellsBetween returns the cells that may hold a point of the rectangle
+// between low and high.
+func (wm *worldMapHex) cellsBetween(low, high *munfall.WPos) []*cellHex {
+	if high.X < 0 || high.Y < 0 || low.X > wm.Width() || low.Y > wm.Height() {
+		return nil
+	}
+
+	// Hexes stick out of their column and row by up to half a hex, one extra
+	// column and row on every side covers them.
+	first := wm.ConvertToMPos(&munfall.WPos{X: low.X - wm.size, Y: low.Y - wm.size})
+	last := wm.ConvertToMPos(&munfall.WPos{X: high.X + wm.size, Y: high.Y + wm.size})
+	if first.X > 0 {
+		first.X--
+	}
+	if first.Y > 0 {
+		first.Y--
+	}
+	if last.X < wm.width-1 {
+		last.X++
+	}
+	if last.Y < wm.height-1 {
+		last.Y++
+	}
+
+	cells := make([]*cellHex, 0, (last.X-first.X+1)*(last.Y-first.Y+1))
+	for y := first.Y; y <= last.Y; y++ {
+		for x := first.X; x <= last.X; x++ {
+			cells = append(cells, wm.grid[x+y*wm.width])
+		}
+	}
+
+	return cells
+}
+
+// collect returns the owners of the spaces in the given cells that are accepted
+// by both accept and filter, every actor is returned at most once.
+func (wm *worldMapHex) collect(cells []*cellHex, accept func(munfall.Space) bool, filter munfall.ActorFilter) []munfall.Actor {
+	c := cellmap.Collector{Accept: accept, Filter: filter}
+	for _, cell := range cells {
+		c.Add(cell.Space())
+	}
+
+	return c.Actors
+}
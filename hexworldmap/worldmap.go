@@ -0,0 +1,358 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package hexworldmap worldmap.go Defines a world map made of hexes laid out
+// in a rectangle, cells are addressed by column and row.
+package hexworldmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/internal/cellmap"
+	"github.com/bluemun/munfall/traits"
+)
+
+// HexWorldMap is a world map of hexes, map positions hold the column and row
+// of a hex and world positions of cells are their centers.
+type HexWorldMap interface {
+	munfall.WorldMap
+
+	// Orientation returns if the hexes are pointy or flat.
+	Orientation() Orientation
+	// HexOf returns the hex of the cell at the map position.
+	HexOf(pos *munfall.MPos) Hex
+	// MPosOf returns the map position of the hex, nil if it is outside the map.
+	MPosOf(h Hex) *munfall.MPos
+	// Distance returns the amount of steps between the cells at the two map positions.
+	Distance(from, to *munfall.MPos) int
+
+	// SetCellBlocked marks a cell as impassable, paths are routed around it.
+	SetCellBlocked(pos *munfall.MPos, blocked bool)
+	IsCellBlocked(pos *munfall.MPos) bool
+
+	// SetTerrain sets the terrain of a cell, cells start out as clear
+	// terrain that every movement class can pass at a cost of 1.
+	SetTerrain(pos *munfall.MPos, terrain *munfall.Terrain)
+
+	// CreatePath creates a path through the cells of the given positions.
+	CreatePath(positions []*munfall.WPos) munfall.Path
+
+	// SetPathSearchLimit limits the amount of cells GetPath expands before it
+	// gives up and returns the path to the closest cell it found, 0 means no limit.
+	SetPathSearchLimit(limit int)
+}
+
+type worldMapHex struct {
+	world         munfall.World
+	orientation   Orientation
+	size          float32
+	width, height uint
+	// originX and originY are the center of hex 0,0 in the world.
+	originX, originY float32
+	grid             []*cellHex
+	searchLimit      int
+	search           *cellmap.Search
+	// terrains holds every terrain used on the map.
+	terrains cellmap.Terrains
+}
+
+// CreateHexWorldMap creates a map of width columns and height rows of hexes,
+// size is the distance from the center of a hex to its corners.
+func CreateHexWorldMap(width, height uint, size float32, orientation Orientation) HexWorldMap {
+	wm := &worldMapHex{
+		orientation: orientation,
+		size:        size,
+		width:       width,
+		height:      height,
+		grid:        make([]*cellHex, width*height),
+		terrains:    cellmap.Terrains{cellmap.ClearTerrain},
+	}
+
+	// Hex 0,0 is placed so its corners and sides touch the top and left of the world.
+	wm.originX, wm.originY = size*float32(sqrt3)/2, size
+	if orientation == Flat {
+		wm.originX, wm.originY = size, size*float32(sqrt3)/2
+	}
+
+	var init uint
+	for y := init; y < height; y++ {
+		for x := init; x < width; x++ {
+			wm.grid[x+y*width] = &cellHex{
+				pos:     &munfall.MPos{X: x, Y: y},
+				terrain: cellmap.ClearTerrain,
+			}
+		}
+	}
+
+	for _, cell := range wm.grid {
+		for _, h := range wm.HexOf(cell.pos).Neighbours() {
+			if pos := wm.MPosOf(h); pos != nil {
+				cell.neighbours = append(cell.neighbours, wm.cellAt(pos))
+			}
+		}
+	}
+
+	return wm
+}
+
+// Width returns the width of the area covered by the hexes.
+func (wm *worldMapHex) Width() float32 {
+	if wm.orientation == Flat {
+		return wm.size * (1.5*float32(wm.width) + 0.5)
+	}
+
+	shift := float32(0)
+	if wm.height > 1 {
+		shift = 0.5
+	}
+
+	return wm.size * float32(sqrt3) * (float32(wm.width) + shift)
+}
+
+// Height returns the height of the area covered by the hexes.
+func (wm *worldMapHex) Height() float32 {
+	if wm.orientation == Pointy {
+		return wm.size * (1.5*float32(wm.height) + 0.5)
+	}
+
+	shift := float32(0)
+	if wm.width > 1 {
+		shift = 0.5
+	}
+
+	return wm.size * float32(sqrt3) * (float32(wm.height) + shift)
+}
+
+func (wm *worldMapHex) Orientation() Orientation {
+	return wm.orientation
+}
+
+func (wm *worldMapHex) Initialize(world munfall.World) {
+	wm.world = world
+}
+
+func (wm *worldMapHex) CellAt(pos *munfall.MPos) munfall.Cell {
+	return wm.grid[pos.X+pos.Y*wm.width]
+}
+
+func (wm *worldMapHex) cellAt(pos *munfall.MPos) *cellHex {
+	return wm.grid[pos.X+pos.Y*wm.width]
+}
+
+func (wm *worldMapHex) SetCellBlocked(pos *munfall.MPos, blocked bool) {
+	wm.cellAt(pos).blocked = blocked
+}
+
+func (wm *worldMapHex) IsCellBlocked(pos *munfall.MPos) bool {
+	return wm.cellAt(pos).blocked
+}
+
+func (wm *worldMapHex) SetTerrain(pos *munfall.MPos, terrain *munfall.Terrain) {
+	wm.terrains.Add(terrain)
+	wm.cellAt(pos).terrain = terrain
+}
+
+func (wm *worldMapHex) SetPathSearchLimit(limit int) {
+	wm.searchLimit = limit
+}
+
+func (wm *worldMapHex) CreatePath(positions []*munfall.WPos) munfall.Path {
+	cells := make([]*cellHex, len(positions))
+	for i, pos := range positions {
+		cells[i] = wm.cellAt(wm.ConvertToMPos(pos))
+	}
+
+	return wm.createPath(cells)
+}
+
+// GetPath finds a path for the actor from p1 to p2 that goes around blocked
+// cells and cells its OccupySpace traits would intersect with. When p2 can't
+// be reached the path leads to the closest cell that can.
+func (wm *worldMapHex) GetPath(a munfall.Actor, p1, p2 *munfall.WPos) munfall.Path {
+	start := wm.cellAt(wm.ConvertToMPos(p1))
+	if !wm.InsideMapWPos(p2) {
+		return wm.createPath([]*cellHex{start})
+	}
+
+	return wm.createPath(wm.findPath(a, start, wm.cellAt(wm.ConvertToMPos(p2)), wm.searchLimit))
+}
+
+func (wm *worldMapHex) createPath(cells []*cellHex) *pathHex {
+	nodes := make([]pathHex, len(cells))
+	first, last := &nodes[0], &nodes[len(nodes)-1]
+	for i, cell := range cells {
+		nodes[i] = pathHex{m: wm, first: first, last: last, cell: cell}
+		if i > 0 {
+			nodes[i-1].next = &nodes[i]
+		}
+	}
+
+	return first
+}
+
+// hexAt returns the hex containing the world position, it may lie outside the map.
+func (wm *worldMapHex) hexAt(pos *munfall.WPos) Hex {
+	return wm.orientation.hexAt(float64(pos.X-wm.originX), float64(pos.Y-wm.originY), float64(wm.size))
+}
+
+// InsideMapWPos returns if the position lies inside one of the hexes of the map.
+func (wm *worldMapHex) InsideMapWPos(pos *munfall.WPos) bool {
+	return wm.MPosOf(wm.hexAt(pos)) != nil
+}
+
+func (wm *worldMapHex) InsideMapMPos(pos *munfall.MPos) bool {
+	return pos.X < wm.width && pos.Y < wm.height
+}
+
+// ConvertToWPos returns the center of the hex.
+func (wm *worldMapHex) ConvertToWPos(m *munfall.MPos) *munfall.WPos {
+	x, y := wm.orientation.center(wm.HexOf(m), float64(wm.size))
+	return &munfall.WPos{X: wm.originX + float32(x), Y: wm.originY + float32(y)}
+}
+
+// ConvertToMPos returns the hex containing the position, positions outside
+// the map are moved to the closest column and row.
+func (wm *worldMapHex) ConvertToMPos(w *munfall.WPos) *munfall.MPos {
+	col, row := wm.orientation.offset(wm.hexAt(w))
+	clamp := func(v int, size uint) uint {
+		return uint(math.Max(0, math.Min(float64(v), float64(size)-1)))
+	}
+
+	return &munfall.MPos{X: clamp(col, wm.width), Y: clamp(row, wm.height)}
+}
+
+func (wm *worldMapHex) Register(a munfall.Actor) {
+	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil)) {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.cellAt(wm.ConvertToMPos(space.Offset())).AddSpace(space)
+		}
+	}
+}
+
+func (wm *worldMapHex) Move(a munfall.Actor, p munfall.Path, percent float32) {
+	path, exists := p.(*pathHex)
+	if !exists {
+		munfall.Logger.Panic("Tried using", p, "on a HexWorldMap, it requires a *pathHex type.")
+	}
+
+	wm.setPos(a, path.WPos(percent))
+}
+
+// setPos moves the actor to the given position, updating the cells it occupies,
+// and moves the actors attached to it along.
+func (wm *worldMapHex) setPos(a munfall.Actor, pos *munfall.WPos) {
+	old := a.Pos()
+	spacetraits := wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil))
+	for _, trait := range spacetraits {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.cellAt(wm.ConvertToMPos(space.Offset())).RemoveSpace(space)
+		}
+	}
+
+	a.SetPos(pos)
+
+	for _, trait := range spacetraits {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.cellAt(wm.ConvertToMPos(space.Offset())).AddSpace(space)
+		}
+	}
+
+	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.MoveNotifier)(nil)) {
+		trait.(traits.MoveNotifier).NotifyMove(old, a.Pos())
+	}
+
+	for _, child := range a.Children() {
+		if child.IsInWorld() {
+			wm.setPos(child, pos.Add(child.AttachOffset()))
+		} else {
+			child.SetPos(pos.Add(child.AttachOffset()))
+		}
+	}
+}
+
+func (wm *worldMapHex) Deregister(a munfall.Actor) {
+	for _, trait := range wm.world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil)) {
+		for _, space := range trait.(traits.OccupySpace).Space() {
+			wm.cellAt(wm.ConvertToMPos(space.Offset())).RemoveSpace(space)
+		}
+	}
+}
+
+type pathHex struct {
+	m     *worldMapHex
+	first *pathHex
+	last  *pathHex
+
+	cell *cellHex
+	next *pathHex
+}
+
+func (p *pathHex) Cell() munfall.Cell {
+	return p.cell
+}
+
+func (p *pathHex) MPos() *munfall.MPos {
+	return p.cell.pos
+}
+
+func (p *pathHex) WPos(percent float32) *munfall.WPos {
+	start := p.m.ConvertToWPos(p.cell.pos)
+	if p.next == nil {
+		return start
+	}
+
+	offset := p.m.ConvertToWPos(p.next.cell.pos).Subtract(start)
+	offset.X *= percent
+	offset.Y *= percent
+	offset.Z *= percent
+	return start.Add(offset)
+}
+
+func (p *pathHex) IsEnd() bool {
+	return p.next == nil
+}
+
+func (p *pathHex) Next() munfall.Path {
+	if p.next == nil {
+		return nil
+	}
+
+	return p.next
+}
+
+func (p *pathHex) First() munfall.Path {
+	return p.first
+}
+
+func (p *pathHex) Last() munfall.Path {
+	return p.last
+}
+
+type cellHex struct {
+	cellmap.Spaces
+	pos        *munfall.MPos
+	neighbours []*cellHex
+	blocked    bool
+	terrain    *munfall.Terrain
+}
+
+func (c *cellHex) Terrain() *munfall.Terrain {
+	return c.terrain
+}
+
+// Passable returns if actors of the movement class can enter the cell.
+func (c *cellHex) Passable(class string) bool {
+	return !c.blocked && c.terrain.CanPass(class)
+}
+
+// AdjacentCells returns the up to six cells that share a side with this one.
+func (c *cellHex) AdjacentCells() []munfall.Cell {
+	cells := make([]munfall.Cell, len(c.neighbours))
+	for i, cell := range c.neighbours {
+		cells[i] = cell
+	}
+
+	return cells
+}
@@ -16,16 +16,39 @@ type World interface {
 	AddFrameEndTask(f func())
 	Tick(deltaUnit float32)
 
+	Pause()
+	Resume()
+	IsPaused() bool
+	Step(ticks int)
+
+	GetActor(id uint) Actor
+	Actors() []Actor
+	ActorsWithTag(tag string) []Actor
+
 	GetTrait(a Actor, i interface{}) Trait
 	GetTraitsImplementing(a Actor, i interface{}) []Trait
 	GetAllTraitsImplementing(i interface{}) []Trait
 
+	AddTrait(a Actor, i interface{}, parameters map[string]interface{}) Trait
+	AddInitializedTrait(a Actor, t Trait)
+	RemoveTrait(a Actor, t Trait)
+
 	AddToWorld(a Actor)
 	RemoveFromWorld(a Actor)
 
+	Attach(parent, child Actor, offset *WPos)
+	Detach(child Actor)
+
 	IssueGlobalOrder(order *Order)
 	IssueOrder(a Actor, order *Order)
 
+	QueueCommand(f func(World))
+	QueueKill(a Actor)
+	QueueGlobalOrder(order *Order)
+	QueueOrder(a Actor, order *Order)
+
+	Profiler() *Profiler
+	Random() *Random
 	WorldMap() WorldMap
 }
 
@@ -42,6 +65,11 @@ type WorldMap interface {
 	CellAt(*MPos) Cell
 	GetPath(a Actor, p1, p2 *WPos) Path
 
+	ActorsInRadius(center *WPos, radius float32, filter ActorFilter) []Actor
+	ActorsInRect(topLeft, bottomRight *WPos, filter ActorFilter) []Actor
+	ActorsInCells(cells []*MPos, filter ActorFilter) []Actor
+	NearestActor(pos *WPos, maxDistance float32, filter ActorFilter) Actor
+
 	ConvertToWPos(*MPos) *WPos
 	ConvertToMPos(*WPos) *MPos
 
@@ -50,6 +78,12 @@ type WorldMap interface {
 	Deregister(Actor)
 }
 
+// WorldMapTicker is implemented by world maps that keep state up to date
+// every tick, the world ticks them after its traits.
+type WorldMapTicker interface {
+	Tick(deltaUnit float32)
+}
+
 // Path is an iterator that defines a path through the world map.
 type Path interface {
 	Cell() Cell
@@ -67,6 +101,7 @@ type Path interface {
 type Cell interface {
 	AdjacentCells() []Cell
 	Space() []Space
+	Terrain() *Terrain
 }
 
 // Space defines a space that can be used to
@@ -86,6 +121,42 @@ type Actor interface {
 	Pos() *WPos
 	SetPos(pos *WPos)
 	World() World
+
+	Parent() Actor
+	Children() []Actor
+	AttachOffset() *WPos
+
+	Tags() []string
+	HasTag(tag string) bool
+}
+
+// ActorFilter is used by queries to select actors, a nil filter accepts every actor.
+type ActorFilter func(Actor) bool
+
+// HasTags returns an ActorFilter that accepts actors that have all the given tags.
+func HasTags(tags ...string) ActorFilter {
+	return func(a Actor) bool {
+		for _, tag := range tags {
+			if !a.HasTag(tag) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// HasAnyTag returns an ActorFilter that accepts actors that have at least one of the given tags.
+func HasAnyTag(tags ...string) ActorFilter {
+	return func(a Actor) bool {
+		for _, tag := range tags {
+			if a.HasTag(tag) {
+				return true
+			}
+		}
+
+		return false
+	}
 }
 
 // Renderable interface used to pass data to a renderer.
@@ -94,3 +165,9 @@ type Renderable interface {
 	Pos() *WPos
 	Color() uint32
 }
+
+// DepthSorter is implemented by world maps whose renderables have to be drawn
+// back to front, renderables with a lower draw depth are drawn first.
+type DepthSorter interface {
+	DrawDepth(pos *WPos) float32
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package cellmap cell.go Defines the cells, terrains and spatial query
+// helpers shared by the world maps made of cells.
+package cellmap
+
+import (
+	"sort"
+
+	"github.com/bluemun/munfall"
+)
+
+// Cell is a cell of a world map that actors of a movement class may be unable to enter.
+type Cell interface {
+	munfall.Cell
+	// Passable returns if actors of the movement class can enter the cell.
+	Passable(class string) bool
+}
+
+// ClearTerrain is the terrain cells start out with.
+var ClearTerrain = &munfall.Terrain{Name: "clear", Passable: true}
+
+// Terrains holds every terrain used on a map.
+type Terrains []*munfall.Terrain
+
+// Add adds the terrain if it isn't used on the map yet.
+func (t *Terrains) Add(terrain *munfall.Terrain) {
+	for _, known := range *t {
+		if known == terrain {
+			return
+		}
+	}
+
+	*t = append(*t, terrain)
+}
+
+// MinMovementCost returns the lowest cost multiplier any of the terrains has
+// for the movement class, used to keep the search heuristics admissible.
+func (t Terrains) MinMovementCost(class string) float32 {
+	min := float32(1)
+	for _, terrain := range t {
+		if terrain.CanPass(class) && terrain.MovementCost(class) < min {
+			min = terrain.MovementCost(class)
+		}
+	}
+
+	return min
+}
+
+// UniformCosts returns if every terrain costs the same to cross for the
+// movement class.
+func (t Terrains) UniformCosts(class string) bool {
+	for _, terrain := range t {
+		if terrain.CanPass(class) && terrain.MovementCost(class) != 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Spaces holds the spaces in a cell sorted by the ID of their owner, so
+// queries find the actors in the same order every time.
+type Spaces []munfall.Space
+
+// Space returns the spaces in the cell.
+func (s Spaces) Space() []munfall.Space {
+	return s
+}
+
+// AddSpace adds the space after the other spaces of its owner.
+func (s *Spaces) AddSpace(space munfall.Space) {
+	spaces := *s
+	id := space.Trait().Owner().ActorID()
+	i := sort.Search(len(spaces), func(i int) bool {
+		return spaces[i].Trait().Owner().ActorID() > id
+	})
+
+	spaces = append(spaces, nil)
+	copy(spaces[i+1:], spaces[i:])
+	spaces[i] = space
+	*s = spaces
+}
+
+// RemoveSpace removes the space from the cell.
+func (s *Spaces) RemoveSpace(space munfall.Space) {
+	spaces := *s
+	id := space.Trait().Owner().ActorID()
+	i := sort.Search(len(spaces), func(i int) bool {
+		return spaces[i].Trait().Owner().ActorID() >= id
+	})
+
+	for ; i < len(spaces) && spaces[i].Trait().Owner().ActorID() == id; i++ {
+		if spaces[i] == space {
+			*s = append(spaces[:i], spaces[i+1:]...)
+			return
+		}
+	}
+}
+
+// Collector gathers the owners of the spaces it is given that are accepted by
+// both Accept and Filter, every actor is collected at most once.
+type Collector struct {
+	Accept func(munfall.Space) bool
+	Filter munfall.ActorFilter
+	Actors []munfall.Actor
+	seen   map[uint]bool
+}
+
+// Add collects the owners of the spaces.
+func (c *Collector) Add(spaces []munfall.Space) {
+	if c.seen == nil {
+		c.seen = make(map[uint]bool)
+	}
+
+	for _, space := range spaces {
+		owner := space.Trait().Owner()
+		if c.seen[owner.ActorID()] || !c.Accept(space) {
+			continue
+		}
+
+		c.seen[owner.ActorID()] = true
+		if c.Filter == nil || c.Filter(owner) {
+			c.Actors = append(c.Actors, owner)
+		}
+	}
+}
+
+// DistanceSquared returns the squared distance between the positions on the ground.
+func DistanceSquared(a, b *munfall.WPos) float32 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package cellmap footprint.go Defines the check if an actor fits on a cell.
+package cellmap
+
+import (
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// Footprint checks if an actor fits on a cell with all of its OccupySpace traits.
+type Footprint struct {
+	wm      munfall.WorldMap
+	layered traits.LayeredWorldMap
+	actor   munfall.Actor
+	traits  []traits.OccupySpace
+	// Class is the movement class of the actor.
+	Class string
+}
+
+// CreateFootprint creates the footprint of the actor on the map, the cells of
+// the map have to implement Cell. The actor may be nil, only the passability
+// of cells is checked then.
+func CreateFootprint(wm munfall.WorldMap, world munfall.World, a munfall.Actor) *Footprint {
+	f := &Footprint{wm: wm, actor: a, Class: traits.MovementClass(a)}
+	f.layered, _ = wm.(traits.LayeredWorldMap)
+	if a == nil || world == nil {
+		return f
+	}
+
+	for _, trait := range world.GetTraitsImplementing(a, (*traits.OccupySpace)(nil)) {
+		f.traits = append(f.traits, trait.(traits.OccupySpace))
+	}
+
+	return f
+}
+
+// Fits returns if the actor can stand on the cell at the position without
+// its spaces leaving the map, entering impassable cells or intersecting the
+// spaces of other actors.
+func (f *Footprint) Fits(pos *munfall.MPos) bool {
+	if len(f.traits) == 0 {
+		return f.wm.CellAt(pos).(Cell).Passable(f.Class)
+	}
+
+	// Cells have no height, the actor keeps its own on every cell it moves to.
+	offset := f.wm.ConvertToWPos(pos).Subtract(f.actor.Pos())
+	offset.Z = 0
+	for _, os := range f.traits {
+		if os.OutOfBounds(offset) {
+			return false
+		}
+
+		for _, space := range os.Space() {
+			pos := space.Offset().Add(offset)
+			cell := f.wm.CellAt(f.wm.ConvertToMPos(pos)).(Cell)
+			if !cell.Passable(f.Class) {
+				return false
+			}
+
+			for _, other := range cell.Space() {
+				if f.layered != nil && !f.layered.LayersCollide(pos, other.Offset()) {
+					continue
+				}
+
+				othertrait := other.Trait().(traits.OccupySpace)
+				if !f.Owns(othertrait.Owner()) && os.Intersects(othertrait, offset) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// Owns returns if the actor is the footprint actor or attached to it,
+// those move along and never block the path.
+func (f *Footprint) Owns(a munfall.Actor) bool {
+	for ; a != nil; a = a.Parent() {
+		if a.ActorID() == f.actor.ActorID() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,226 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package cellmap search.go Defines the A* search shared by the world maps
+// made of cells.
+package cellmap
+
+import (
+	"math"
+
+	"github.com/bluemun/munfall"
+)
+
+// Graph is a map of cells that can be searched, cells are addressed by their
+// index in the map.
+type Graph interface {
+	// Cell returns the cell at the index.
+	Cell(i int) Cell
+	// MPos returns the map position of the cell at the index.
+	MPos(i int) *munfall.MPos
+	// Neighbours calls visit for every cell next to the cell at the index
+	// with the cost of the step there before the terrain is applied.
+	Neighbours(i int, visit func(next int, step float32))
+	// Heuristic returns a lower bound of the step costs between two cells.
+	Heuristic(from, to int) float32
+	// MinMovementCost returns the lowest cost multiplier any terrain on the
+	// map has for the movement class.
+	MinMovementCost(class string) float32
+}
+
+// Search holds the per cell state of a search, it is reused between
+// searches and reset lazily by bumping the generation.
+type Search struct {
+	generation uint32
+	nodes      []Node
+	Open       OpenSet
+}
+
+// Node is the state of a cell in a search.
+type Node struct {
+	generation uint32
+	Parent     int
+	G          float32
+	Closed     bool
+	// fit caches if the actor fits on the cell, 0 unknown, 1 fits, 2 doesn't.
+	fit uint8
+}
+
+// CreateSearch creates a search over a map of the given amount of cells.
+func CreateSearch(cells int) *Search {
+	return &Search{nodes: make([]Node, cells)}
+}
+
+// Reset starts a new search, every node is unvisited again.
+func (s *Search) Reset() {
+	s.generation++
+	s.Open = s.Open[:0]
+}
+
+// Node returns the state of the cell at the index in the current search.
+func (s *Search) Node(i int) *Node {
+	n := &s.nodes[i]
+	if n.generation != s.generation {
+		*n = Node{generation: s.generation, Parent: -1, G: float32(math.Inf(1))}
+	}
+
+	return n
+}
+
+// FindPath runs A* over the cells of the graph from start to goal for the
+// footprint, it returns the cell indices of the path including start. If the
+// goal can't be reached or limit cells have been expanded, the path to the
+// cell closest to the goal is returned instead.
+func (s *Search) FindPath(g Graph, fp *Footprint, start, goal, limit int) []int {
+	if start == goal {
+		return []int{start}
+	}
+
+	s.Reset()
+	scale := g.MinMovementCost(fp.Class)
+	best, bestH := start, g.Heuristic(start, goal)*scale
+	s.Node(start).G = 0
+	s.Open.Push(OpenEntry{Index: start, F: bestH, H: bestH})
+
+	expanded := 0
+	var current *Node
+	var currentIndex int
+	visit := func(next int, step float32) {
+		nn := s.Node(next)
+		if nn.Closed {
+			return
+		}
+
+		if nn.fit == 0 {
+			nn.fit = 2
+			if fp.Fits(g.MPos(next)) {
+				nn.fit = 1
+			}
+		}
+
+		if nn.fit != 1 {
+			return
+		}
+
+		if cost := current.G + step*g.Cell(next).Terrain().MovementCost(fp.Class); cost < nn.G {
+			nn.G = cost
+			nn.Parent = currentIndex
+			h := g.Heuristic(next, goal) * scale
+			s.Open.Push(OpenEntry{Index: next, F: cost + h, H: h})
+		}
+	}
+
+	for len(s.Open) > 0 {
+		e := s.Open.Pop()
+		current, currentIndex = s.Node(e.Index), e.Index
+		if current.Closed {
+			continue
+		}
+
+		current.Closed = true
+		if e.Index == goal {
+			best = goal
+			break
+		}
+
+		if e.H < bestH || (e.H == bestH && current.G < s.Node(best).G) {
+			best, bestH = e.Index, e.H
+		}
+
+		expanded++
+		if limit > 0 && expanded >= limit {
+			break
+		}
+
+		g.Neighbours(e.Index, visit)
+	}
+
+	path := s.Path(best)
+	if best != goal {
+		munfall.Logger.Debug("No path to", g.MPos(goal), "found, stopping at", g.MPos(best))
+	}
+
+	return path
+}
+
+// Path returns the cell indices leading from the start of the search to the
+// cell at the index by following the parents.
+func (s *Search) Path(i int) []int {
+	length := 0
+	for j := i; j != -1; j = s.nodes[j].Parent {
+		length++
+	}
+
+	path := make([]int, length)
+	for j := i; j != -1; j = s.nodes[j].Parent {
+		length--
+		path[length] = j
+	}
+
+	return path
+}
+
+// OpenEntry is a cell waiting in an OpenSet, F is the estimated cost of the
+// path through it and H the estimated cost left to the goal.
+type OpenEntry struct {
+	Index int
+	F, H  float32
+}
+
+// OpenSet is a min heap ordered by F, ties are broken on H and then on the
+// cell index so equal searches always find the same path.
+type OpenSet []OpenEntry
+
+func (o OpenSet) less(i, j int) bool {
+	if o[i].F != o[j].F {
+		return o[i].F < o[j].F
+	}
+	if o[i].H != o[j].H {
+		return o[i].H < o[j].H
+	}
+
+	return o[i].Index < o[j].Index
+}
+
+// Push adds the entry to the heap.
+func (o *OpenSet) Push(e OpenEntry) {
+	*o = append(*o, e)
+	h := *o
+	for i := len(h) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !h.less(i, parent) {
+			break
+		}
+
+		h[i], h[parent] = h[parent], h[i]
+		i = parent
+	}
+}
+
+// Pop removes and returns the entry with the lowest F.
+func (o *OpenSet) Pop() OpenEntry {
+	h := *o
+	e := h[0]
+	last := len(h) - 1
+	h[0] = h[last]
+	h = h[:last]
+	for i := 0; ; {
+		smallest, left, right := i, 2*i+1, 2*i+2
+		if left < last && h.less(left, smallest) {
+			smallest = left
+		}
+		if right < last && h.less(right, smallest) {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+
+		h[i], h[smallest] = h[smallest], h[i]
+		i = smallest
+	}
+
+	*o = h
+	return e
+}
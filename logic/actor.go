@@ -6,6 +6,8 @@
 package logic
 
 import (
+	"sort"
+
 	"github.com/bluemun/munfall"
 )
 
@@ -15,6 +17,18 @@ type actor struct {
 	world         *world
 	pos           *munfall.WPos
 	dead, inworld bool
+
+	parent       *actor
+	children     []*actor
+	attachOffset *munfall.WPos
+
+	definition        string
+	runtimeParameters map[string]interface{}
+	tags              map[string]bool
+	// addedTraits holds the traits added after creation, they are kept when the actor is reloaded.
+	addedTraits []munfall.Trait
+	// registry is the ActorRegistry that created the actor and reloads it.
+	registry *ActorRegistry
 }
 
 // World returns the world that this actor currently resides in.
@@ -35,9 +49,21 @@ func (a *actor) SetPos(pos *munfall.WPos) {
 	a.pos = pos
 }
 
+// Kill kills the actor together with every actor attached to it.
 func (a *actor) Kill() {
+	for _, child := range append([]*actor(nil), a.children...) {
+		child.Kill()
+	}
+
+	if a.parent != nil {
+		a.world.Detach(a)
+	}
+
 	a.dead = true
 	a.world.RemoveFromWorld(a)
+	if a.registry != nil {
+		a.registry.forget(a)
+	}
 }
 
 func (a *actor) IsDead() bool {
@@ -47,3 +73,75 @@ func (a *actor) IsDead() bool {
 func (a *actor) IsInWorld() bool {
 	return a.inworld
 }
+
+// Parent returns the actor this actor is attached to, nil if it isn't attached.
+func (a *actor) Parent() munfall.Actor {
+	if a.parent == nil {
+		return nil
+	}
+
+	return a.parent
+}
+
+// Children returns the actors that are attached to this actor.
+func (a *actor) Children() []munfall.Actor {
+	children := make([]munfall.Actor, len(a.children))
+	for i, child := range a.children {
+		children[i] = child
+	}
+
+	return children
+}
+
+// AttachOffset returns the offset from the parent position this actor is kept at,
+// nil if it isn't attached.
+func (a *actor) AttachOffset() *munfall.WPos {
+	return a.attachOffset
+}
+
+// Tags returns the tags this actor was given by its definition, sorted by name.
+func (a *actor) Tags() []string {
+	tags := make([]string, 0, len(a.tags))
+	for tag := range a.tags {
+		tags = append(tags, tag)
+	}
+
+	sort.Strings(tags)
+	return tags
+}
+
+// HasTag returns if this actor has the given tag.
+func (a *actor) HasTag(tag string) bool {
+	return a.tags[tag]
+}
+
+// hasAddedTrait returns if the trait was added to the actor after it was created.
+func (a *actor) hasAddedTrait(t munfall.Trait) bool {
+	for _, trait := range a.addedTraits {
+		if trait == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ActorDefinitionOf returns the name of the definition the actor was created
+// from together with the runtime parameters it was created with, the name is
+// empty if the actor wasn't created by an ActorRegistry.
+func ActorDefinitionOf(a munfall.Actor) (name string, runtimeParameters map[string]interface{}) {
+	if la, ok := a.(*actor); ok {
+		return la.definition, la.runtimeParameters
+	}
+
+	return "", nil
+}
+
+func createTagSet(tags []string) map[string]bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+
+	return set
+}
@@ -7,6 +7,8 @@ package logic
 
 import (
 	"reflect"
+	"runtime/debug"
+	"sort"
 
 	"github.com/bluemun/munfall"
 )
@@ -15,6 +17,7 @@ import (
 type ActorDefinition struct {
 	Name   string
 	traits []*TraitDefinition
+	tags   []string
 }
 
 // TraitDefinition used for constructing a trait.
@@ -49,6 +52,16 @@ func (ad *ActorDefinition) AddTrait(def *TraitDefinition) {
 	ad.traits = append(ad.traits, def)
 }
 
+// AddTags adds tags that are copied to every actor created from this ActorDefinition.
+func (ad *ActorDefinition) AddTags(tags ...string) {
+	ad.tags = append(ad.tags, tags...)
+}
+
+// Tags returns the tags of this ActorDefinition.
+func (ad *ActorDefinition) Tags() []string {
+	return append([]string(nil), ad.tags...)
+}
+
 // TraitCreate holds all the information needed to create a trait.
 type TraitCreate struct {
 	Name       string
@@ -56,11 +69,27 @@ type TraitCreate struct {
 	Parameters map[string]interface{}
 }
 
+// RegistryAwareTrait is implemented by traits that need the registry that
+// creates them, the registry is set before the trait is initialized.
+type RegistryAwareTrait interface {
+	munfall.Trait
+	SetActorRegistry(ar *ActorRegistry)
+}
+
 // ActorRegistry contains definitions for actors.
+//
+// The registry keeps track of every actor it creates so ReloadActor can reach
+// them, until the actor is killed, disposed with DisposeActor or its world is
+// disposed with DisposeWorld. Actors that are only removed from their world
+// stay tracked so they can be reloaded and added again, callers dropping such
+// an actor or a whole world have to dispose of it to release it.
 type ActorRegistry struct {
 	nextID      uint
 	definitions map[string]reflect.Type
 	builders    map[string]*ActorDefinition
+	// live holds the tracked actors by definition name and ID, a name is
+	// removed together with its last actor.
+	live map[string]map[uint]*actor
 }
 
 // CreateActorRegistry creates and initializes an ActorRegistry.
@@ -68,28 +97,88 @@ func CreateActorRegistry() *ActorRegistry {
 	ar := &ActorRegistry{
 		definitions: make(map[string]reflect.Type),
 		builders:    make(map[string]*ActorDefinition),
+		live:        make(map[string]map[uint]*actor),
 	}
 
 	return ar
 }
 
 // CreateActor creates an actor in the given world by using the trait parameters
-// registered to the given name and the provided runtime parameters.
+// registered to the given name and the provided runtime parameters, panics if
+// the actor can't be created.
 func (ar *ActorRegistry) CreateActor(name string, runtimeParameters map[string]interface{}, w munfall.World, addToWorld bool) munfall.Actor {
+	a, err := ar.CreateActorE(name, runtimeParameters, w, addToWorld)
+	if err != nil {
+		munfall.Logger.Panic(err)
+	}
+
+	return a
+}
+
+// CreateActorE creates an actor in the given world by using the trait parameters
+// registered to the given name and the provided runtime parameters. Nothing is
+// added to the world if an error is returned, the ID the actor would have had
+// is not reused.
+func (ar *ActorRegistry) CreateActorE(name string, runtimeParameters map[string]interface{}, w munfall.World, addToWorld bool) (munfall.Actor, error) {
 	world := w.(*world)
-	params := ar.builders[name]
+	params, exists := ar.builders[name]
+	if !exists {
+		return nil, &UnknownActorError{Name: name}
+	}
 
-	a := &actor{actorID: ar.nextID, pos: &munfall.WPos{}, world: world}
+	// The ID is taken first so actors created while the traits initialize get their own.
+	id := ar.nextID
 	ar.nextID++
+	a := &actor{
+		actorID:           id,
+		pos:               &munfall.WPos{},
+		world:             world,
+		definition:        name,
+		runtimeParameters: runtimeParameters,
+		tags:              createTagSet(params.tags),
+		registry:          ar,
+	}
+
+	created, err := ar.createTraits(params, runtimeParameters, w, a)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trait := range created {
+		world.traitDictionary.addTrait(a, trait)
+	}
+
+	live, exists := ar.live[name]
+	if !exists {
+		live = make(map[uint]*actor)
+		ar.live[name] = live
+	}
+
+	live[a.actorID] = a
+	if addToWorld {
+		world.AddToWorld(a)
+	}
+
+	return a, nil
+}
 
-	for _, traitdef := range params.traits {
-		obj := reflect.New(ar.definitions[traitdef.Type])
-		trait := obj.Interface().(munfall.Trait)
+// createTraits creates and initializes the traits described by the definition for the given actor.
+func (ar *ActorRegistry) createTraits(definition *ActorDefinition, runtimeParameters map[string]interface{}, w munfall.World, a *actor) ([]munfall.Trait, error) {
+	created := make([]munfall.Trait, 0, len(definition.traits))
+	for _, traitdef := range definition.traits {
+		traitType, exists := ar.definitions[traitdef.Type]
+		if !exists {
+			return nil, &UnknownTraitError{Actor: definition.Name, Trait: traitdef.Type}
+		}
+
+		trait := reflect.New(traitType).Interface().(munfall.Trait)
+		if aware, ok := trait.(RegistryAwareTrait); ok {
+			aware.SetActorRegistry(ar)
+		}
 
-		if runtimeParameters == nil {
-			trait.Initialize(w, a, traitdef.parameters)
-		} else {
-			np := make(map[string]interface{}, len(runtimeParameters)+len(traitdef.parameters))
+		np := traitdef.parameters
+		if runtimeParameters != nil {
+			np = make(map[string]interface{}, len(runtimeParameters)+len(traitdef.parameters))
 			for key, value := range traitdef.parameters {
 				np[key] = value
 			}
@@ -97,42 +186,212 @@ func (ar *ActorRegistry) CreateActor(name string, runtimeParameters map[string]i
 			for key, value := range runtimeParameters {
 				np[key] = value
 			}
+		}
 
-			trait.Initialize(w, a, np)
+		if reason, stack := initializeTrait(trait, w, a, np); reason != nil {
+			return nil, &InvalidParametersError{Actor: definition.Name, Trait: traitdef.Type, Reason: reason, Stack: stack}
 		}
 
-		world.traitDictionary.addTrait(a, trait)
+		created = append(created, trait)
 	}
 
-	if addToWorld {
-		world.AddToWorld(a)
+	return created, nil
+}
+
+// initializeTrait initializes the trait and returns the value it panicked with
+// and the stack trace of the panic, if it did.
+func initializeTrait(trait munfall.Trait, w munfall.World, a munfall.Actor, parameters map[string]interface{}) (reason interface{}, stack []byte) {
+	defer func() {
+		if reason = recover(); reason != nil {
+			stack = debug.Stack()
+		}
+	}()
+
+	trait.Initialize(w, a, parameters)
+	return nil, nil
+}
+
+// QueueCreateActor queues the creation of an actor in the given world, safe to
+// call from any goroutine. The actor is created at the start of the next world
+// tick and passed to done if it is not nil, done runs on the game loop.
+// Errors are logged when done is nil.
+// The runtimeParameters map must not be modified after it has been queued.
+func (ar *ActorRegistry) QueueCreateActor(name string, runtimeParameters map[string]interface{}, w munfall.World, addToWorld bool, done func(munfall.Actor, error)) {
+	w.QueueCommand(func(w munfall.World) {
+		a, err := ar.CreateActorE(name, runtimeParameters, w, addToWorld)
+		if done != nil {
+			done(a, err)
+		} else if err != nil {
+			munfall.Logger.Error(err)
+		}
+	})
+}
+
+// AddTrait adds a trait of the registered trait type to an existing actor, panics
+// if the trait can't be created.
+func (ar *ActorRegistry) AddTrait(a munfall.Actor, traitType string, parameters map[string]interface{}, w munfall.World) munfall.Trait {
+	trait, err := ar.AddTraitE(a, traitType, parameters, w)
+	if err != nil {
+		munfall.Logger.Panic(err)
 	}
 
-	return a
+	return trait
 }
 
-// DisposeActor disposes of all the traits from the world.
+// AddTraitE adds a trait of the registered trait type to an existing actor,
+// see World.AddInitializedTrait for the notifications that are sent.
+func (ar *ActorRegistry) AddTraitE(a munfall.Actor, traitType string, parameters map[string]interface{}, w munfall.World) (munfall.Trait, error) {
+	td := CreateTraitDefinition(traitType)
+	for key, value := range parameters {
+		td.AddParameter(key, value)
+	}
+
+	definition := CreateActorDefinition(a.(*actor).definition)
+	definition.AddTrait(td)
+	created, err := ar.createTraits(definition, nil, w, a.(*actor))
+	if err != nil {
+		return nil, err
+	}
+
+	w.AddInitializedTrait(a, created[0])
+	return created[0], nil
+}
+
+// DisposeActor disposes of all the traits from the world, the actor is no
+// longer reloaded afterwards.
 func (ar *ActorRegistry) DisposeActor(a munfall.Actor, w munfall.World) {
 	w.(*world).cleanTraits(a)
+	ar.forget(a.(*actor))
+}
+
+// DisposeWorld disposes of the traits of every actor the registry created in
+// the given world, the actors are no longer reloaded afterwards. Used when a
+// world is dropped while the registry lives on.
+func (ar *ActorRegistry) DisposeWorld(w munfall.World) {
+	world := w.(*world)
+	names := make([]string, 0, len(ar.live))
+	for name := range ar.live {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		for _, a := range ar.liveActors(name) {
+			if a.world == world {
+				ar.DisposeActor(a, w)
+			}
+		}
+	}
+}
+
+// forget stops tracking the actor for reloads.
+func (ar *ActorRegistry) forget(a *actor) {
+	if live, exists := ar.live[a.definition]; exists {
+		delete(live, a.actorID)
+		if len(live) == 0 {
+			delete(ar.live, a.definition)
+		}
+	}
 }
 
 // RegisterTrait adds a trait type as a candidate for creation, panics if it already exists.
 func (ar *ActorRegistry) RegisterTrait(name string, t interface{}) {
+	if err := ar.RegisterTraitE(name, t); err != nil {
+		munfall.Logger.Panic(err)
+	}
+}
+
+// RegisterTraitE adds a trait type as a candidate for creation, t must be
+// a pointer to a type implementing munfall.Trait.
+func (ar *ActorRegistry) RegisterTraitE(name string, t interface{}) error {
 	_, exists := ar.definitions[name]
 	if exists {
-		munfall.Logger.Panic("Trait:", name, "already exists in the trait registry.")
+		return &DuplicateTraitError{Name: name}
 	}
 
-	ar.definitions[name] = reflect.TypeOf(t).Elem()
+	traitType := reflect.TypeOf(t)
+	if traitType == nil || traitType.Kind() != reflect.Ptr || !traitType.Implements(reflect.TypeOf((*munfall.Trait)(nil)).Elem()) {
+		return &InvalidTraitTypeError{Name: name, Value: t}
+	}
+
+	ar.definitions[name] = traitType.Elem()
+	return nil
 }
 
 // RegisterActor adds trait parameters to the registered actor name, used by the
-// CreateActor method to
+// CreateActor method to construct actors, panics if the name is already taken.
 func (ar *ActorRegistry) RegisterActor(definition *ActorDefinition) {
+	if err := ar.RegisterActorE(definition); err != nil {
+		munfall.Logger.Panic(err)
+	}
+}
+
+// RegisterActorE adds trait parameters to the registered actor name, used by the
+// CreateActor method to construct actors.
+func (ar *ActorRegistry) RegisterActorE(definition *ActorDefinition) error {
 	_, exists := ar.builders[definition.Name]
 	if exists {
-		munfall.Logger.Panic("An actor with the name", definition.Name, "has already been registered.")
+		return &DuplicateActorError{Name: definition.Name}
 	}
 
 	ar.builders[definition.Name] = definition
+	return nil
+}
+
+// UpdateActor replaces the definition registered under the same name, or registers
+// it if the name is new. Only actors created afterwards use the new definition.
+func (ar *ActorRegistry) UpdateActor(definition *ActorDefinition) {
+	ar.builders[definition.Name] = definition
+}
+
+// ReloadActor replaces the registered definition and re-initializes the live actors
+// created from it in the given world, panics if that fails.
+func (ar *ActorRegistry) ReloadActor(definition *ActorDefinition, w munfall.World) {
+	if err := ar.ReloadActorE(definition, w); err != nil {
+		munfall.Logger.Panic(err)
+	}
+}
+
+// ReloadActorE replaces the registered definition and re-initializes every actor
+// of the given world that was created from it and hasn't been killed or disposed,
+// whether it is in the world or not. The traits of those actors are recreated
+// with the new trait parameters and their original runtime parameters, while
+// their position, ID and attachments are kept and their tags are updated. If any
+// trait fails to initialize nothing is changed and the error is returned.
+func (ar *ActorRegistry) ReloadActorE(definition *ActorDefinition, w munfall.World) error {
+	world := w.(*world)
+	var actors []*actor
+	var created [][]munfall.Trait
+	for _, a := range ar.liveActors(definition.Name) {
+		if a.world != world {
+			continue
+		}
+
+		traits, err := ar.createTraits(definition, a.runtimeParameters, w, a)
+		if err != nil {
+			return err
+		}
+
+		actors = append(actors, a)
+		created = append(created, traits)
+	}
+
+	ar.builders[definition.Name] = definition
+	for i, a := range actors {
+		world.replaceTraits(a, created[i])
+		world.setTags(a, createTagSet(definition.tags))
+	}
+
+	return nil
+}
+
+// liveActors returns the live actors created from the named definition ordered by their ID.
+func (ar *ActorRegistry) liveActors(name string) []*actor {
+	actors := make([]*actor, 0, len(ar.live[name]))
+	for _, a := range ar.live[name] {
+		actors = append(actors, a)
+	}
+
+	sort.Slice(actors, func(i, j int) bool { return actors[i].actorID < actors[j].actorID })
+	return actors
 }
@@ -0,0 +1,209 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/gridworldmap"
+)
+
+type testTrait struct {
+	owner munfall.Actor
+	value interface{}
+}
+
+func (t *testTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	t.owner = a
+	t.value = parameters["Value"]
+}
+
+func (t *testTrait) Owner() munfall.Actor {
+	return t.owner
+}
+
+// spawnerTrait creates a child actor while it initializes.
+type spawnerTrait struct {
+	testTrait
+	ar    *ActorRegistry
+	child munfall.Actor
+}
+
+func (t *spawnerTrait) SetActorRegistry(ar *ActorRegistry) {
+	t.ar = ar
+}
+
+func (t *spawnerTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	t.owner = a
+	t.child = t.ar.CreateActor("plain", nil, w, false)
+}
+
+// brokenTrait panics with a runtime error while it initializes.
+type brokenTrait struct {
+	testTrait
+}
+
+func (t *brokenTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	var parts []int
+	t.owner = a
+	_ = parts[len(parameters)]
+}
+
+func createTestRegistry() (*ActorRegistry, munfall.World) {
+	ar := CreateActorRegistry()
+	ar.RegisterTrait("Test", (*testTrait)(nil))
+	ar.RegisterTrait("Spawner", (*spawnerTrait)(nil))
+	ar.RegisterTrait("Broken", (*brokenTrait)(nil))
+
+	plain := CreateActorDefinition("plain")
+	plain.AddTrait(CreateTraitDefinition("Test"))
+	ar.RegisterActor(plain)
+
+	spawner := CreateActorDefinition("spawner")
+	spawner.AddTrait(CreateTraitDefinition("Spawner"))
+	ar.RegisterActor(spawner)
+
+	broken := CreateActorDefinition("broken")
+	broken.AddTrait(CreateTraitDefinition("Broken"))
+	ar.RegisterActor(broken)
+
+	return ar, CreateWorld(gridworldmap.CreateGridWorldMap(4, 4, 1, 1))
+}
+
+func TestCreateActorDuringTraitInitialization(t *testing.T) {
+	ar, w := createTestRegistry()
+	a := ar.CreateActor("spawner", nil, w, false)
+	child := w.GetTrait(a, (*spawnerTrait)(nil)).(*spawnerTrait).child
+	if a.ActorID() == child.ActorID() {
+		t.Fatalf("parent and child share ID %d", a.ActorID())
+	}
+
+	if next := ar.CreateActor("plain", nil, w, false); next.ActorID() == a.ActorID() || next.ActorID() == child.ActorID() {
+		t.Fatalf("ID %d was handed out twice", next.ActorID())
+	}
+}
+
+func TestCreateActorKeepsPanicStack(t *testing.T) {
+	ar, w := createTestRegistry()
+	_, err := ar.CreateActorE("broken", nil, w, false)
+	perr, ok := err.(*InvalidParametersError)
+	if !ok {
+		t.Fatalf("expected InvalidParametersError, got %v", err)
+	}
+
+	if perr.Unwrap() == nil {
+		t.Errorf("expected the runtime error to be unwrapped, reason was %v", perr.Reason)
+	}
+
+	if !strings.Contains(string(perr.Stack), "brokenTrait") {
+		t.Errorf("stack trace doesn't point at the trait:\n%s", perr.Stack)
+	}
+}
+
+func TestReloadActorOutsideWorld(t *testing.T) {
+	ar, w := createTestRegistry()
+	inWorld := ar.CreateActor("plain", nil, w, true)
+	outside := ar.CreateActor("plain", nil, w, false)
+	removed := ar.CreateActor("plain", nil, w, true)
+	w.RemoveFromWorld(removed)
+	killed := ar.CreateActor("plain", nil, w, true)
+	killed.Kill()
+
+	reloaded := CreateActorDefinition("plain")
+	reloaded.AddTrait(CreateTraitDefinition("Test").AddParameter("Value", 2))
+	ar.ReloadActor(reloaded, w)
+
+	for _, a := range []munfall.Actor{inWorld, outside, removed} {
+		if value := w.GetTrait(a, (*testTrait)(nil)).(*testTrait).value; value != 2 {
+			t.Errorf("actor %d wasn't reloaded, value is %v", a.ActorID(), value)
+		}
+	}
+
+	if len(ar.live["plain"]) != 3 {
+		t.Errorf("expected 3 live actors, got %d", len(ar.live["plain"]))
+	}
+}
+
+func TestRegistryForgetsActors(t *testing.T) {
+	ar, w := createTestRegistry()
+	killed := ar.CreateActor("plain", nil, w, true)
+	disposed := ar.CreateActor("plain", nil, w, true)
+	removed := ar.CreateActor("plain", nil, w, true)
+	w.RemoveFromWorld(removed)
+
+	killed.Kill()
+	ar.DisposeActor(disposed, w)
+	if len(ar.live["plain"]) != 1 || ar.live["plain"][removed.ActorID()] == nil {
+		t.Fatalf("expected only the removed actor to be tracked, got %v", ar.live["plain"])
+	}
+
+	other := CreateWorld(w.WorldMap())
+	kept := ar.CreateActor("plain", nil, other, true)
+	ar.DisposeWorld(w)
+	if len(ar.live["plain"]) != 1 || ar.live["plain"][kept.ActorID()] == nil {
+		t.Fatalf("DisposeWorld left %v tracked", ar.live["plain"])
+	}
+
+	kept.Kill()
+	if len(ar.live) != 0 {
+		t.Errorf("expected no tracked actors, got %v", ar.live)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	ar, w := createTestRegistry()
+	missingTrait := CreateActorDefinition("ghost")
+	missingTrait.AddTrait(CreateTraitDefinition("Missing"))
+	ar.RegisterActor(missingTrait)
+	plain := ar.CreateActor("plain", nil, w, true)
+
+	var unknownActor *UnknownActorError
+	var unknownTrait *UnknownTraitError
+	var invalidType *InvalidTraitTypeError
+	var duplicateTrait *DuplicateTraitError
+	var duplicateActor *DuplicateActorError
+	check := func(source string, err error, target interface{}, matches func() bool) {
+		t.Helper()
+		// Wrapping the error must not hide its type.
+		if err == nil || !errors.As(fmt.Errorf("loading content: %w", err), target) || !matches() {
+			t.Errorf("%s returned %v, expected %T", source, err, target)
+		}
+	}
+
+	_, err := ar.CreateActorE("missing", nil, w, true)
+	check("CreateActorE", err, &unknownActor, func() bool { return unknownActor.Name == "missing" })
+
+	_, err = ar.CreateActorE("ghost", nil, w, true)
+	check("CreateActorE", err, &unknownTrait, func() bool { return unknownTrait.Actor == "ghost" && unknownTrait.Trait == "Missing" })
+
+	_, err = ar.AddTraitE(plain, "Missing", nil, w)
+	check("AddTraitE", err, &unknownTrait, func() bool { return unknownTrait.Trait == "Missing" })
+
+	reloaded := CreateActorDefinition("plain")
+	reloaded.AddTrait(CreateTraitDefinition("Missing"))
+	check("ReloadActorE", ar.ReloadActorE(reloaded, w), &unknownTrait, func() bool { return unknownTrait.Actor == "plain" })
+
+	for _, value := range []interface{}{nil, testTrait{}, new(int)} {
+		check("RegisterTraitE", ar.RegisterTraitE("Invalid", value), &invalidType, func() bool { return invalidType.Name == "Invalid" && invalidType.Value == value })
+	}
+
+	check("RegisterTraitE", ar.RegisterTraitE("Test", (*testTrait)(nil)), &duplicateTrait, func() bool { return duplicateTrait.Name == "Test" })
+
+	check("RegisterActorE", ar.RegisterActorE(CreateActorDefinition("plain")), &duplicateActor, func() bool { return duplicateActor.Name == "plain" })
+
+	var queued error
+	ar.QueueCreateActor("missing", nil, w, true, func(a munfall.Actor, err error) { queued = err })
+	w.Tick(1)
+	check("QueueCreateActor", queued, &unknownActor, func() bool { return unknownActor.Name == "missing" })
+
+	// None of the failed calls may have changed the registry.
+	if _, err := ar.CreateActorE("plain", nil, w, true); err != nil {
+		t.Errorf("registry was left broken: %v", err)
+	}
+}
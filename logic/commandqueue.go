@@ -0,0 +1,34 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package logic commandqueue.go Defines a queue used to hand work to the world
+// from other goroutines.
+package logic
+
+import (
+	"sync"
+)
+
+// commandQueue holds commands issued from any goroutine until the world
+// applies them during its next tick.
+type commandQueue struct {
+	mutex    sync.Mutex
+	commands []func()
+}
+
+// push adds a command to the queue, safe to call from any goroutine.
+func (q *commandQueue) push(f func()) {
+	q.mutex.Lock()
+	q.commands = append(q.commands, f)
+	q.mutex.Unlock()
+}
+
+// drain empties the queue and returns the commands in the order they were pushed.
+func (q *commandQueue) drain() []func() {
+	q.mutex.Lock()
+	commands := q.commands
+	q.commands = nil
+	q.mutex.Unlock()
+	return commands
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package logic
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/bluemun/munfall"
+)
+
+// recordingTrait records the orders it resolves.
+type recordingTrait struct {
+	testTrait
+	orders []string
+}
+
+func (t *recordingTrait) ResolveOrder(order *munfall.Order) {
+	t.orders = append(t.orders, order.Order)
+}
+
+func TestCommandQueueDrainsInOrder(t *testing.T) {
+	var q commandQueue
+	var ran []int
+	for i := 0; i < 5; i++ {
+		i := i
+		q.push(func() { ran = append(ran, i) })
+	}
+
+	for _, command := range q.drain() {
+		command()
+	}
+
+	for i, got := range ran {
+		if got != i {
+			t.Fatalf("commands ran as %v", ran)
+		}
+	}
+
+	if len(ran) != 5 || len(q.drain()) != 0 {
+		t.Errorf("drain returned %d commands and left some behind", len(ran))
+	}
+}
+
+func TestCommandQueueAcrossGoroutines(t *testing.T) {
+	_, w := createTestRegistry()
+	const goroutines, perGoroutine = 8, 100
+	count := 0
+	last := make([]int, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 1; i <= perGoroutine; i++ {
+				i := i
+				w.QueueCommand(func(munfall.World) {
+					// Commands from one goroutine keep their order.
+					if last[g] != i-1 {
+						t.Errorf("goroutine %d: command %d ran after %d", g, i, last[g])
+					}
+
+					last[g] = i
+					count++
+				})
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	if count != 0 {
+		t.Fatalf("%d commands ran before the tick", count)
+	}
+
+	w.Tick(1)
+	if count != goroutines*perGoroutine {
+		t.Errorf("%d commands ran, want %d", count, goroutines*perGoroutine)
+	}
+}
+
+func TestQueuedKillsAndOrders(t *testing.T) {
+	ar, w := createTestRegistry()
+	ar.RegisterTrait("Recording", (*recordingTrait)(nil))
+	recording := CreateActorDefinition("recording")
+	recording.AddTrait(CreateTraitDefinition("Recording"))
+	ar.RegisterActor(recording)
+
+	a := ar.CreateActor("recording", nil, w, true)
+	doomed := ar.CreateActor("recording", nil, w, true)
+	w.QueueOrder(a, &munfall.Order{Order: "first"})
+	w.QueueGlobalOrder(&munfall.Order{Order: "second"})
+	w.QueueKill(doomed)
+	w.QueueOrder(doomed, &munfall.Order{Order: "dropped"})
+	if doomed.IsDead() || len(w.GetTrait(a, (*recordingTrait)(nil)).(*recordingTrait).orders) != 0 {
+		t.Fatal("queued commands were applied before the tick")
+	}
+
+	w.Tick(1)
+	if orders := w.GetTrait(a, (*recordingTrait)(nil)).(*recordingTrait).orders; len(orders) != 2 || orders[0] != "first" || orders[1] != "second" {
+		t.Errorf("orders resolved as %v", orders)
+	}
+
+	if !doomed.IsDead() || w.GetActor(doomed.ActorID()) != nil {
+		t.Error("queued kill wasn't applied")
+	}
+
+	// A second kill of a dead actor is ignored.
+	w.QueueKill(doomed)
+	w.Tick(1)
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package logic errors.go Defines the errors returned by the actor registry.
+package logic
+
+import (
+	"fmt"
+)
+
+// UnknownActorError is returned when an actor is requested by a name that
+// has not been registered.
+type UnknownActorError struct {
+	Name string
+}
+
+func (e *UnknownActorError) Error() string {
+	return fmt.Sprintf("actor %q has not been registered", e.Name)
+}
+
+// UnknownTraitError is returned when an actor definition uses a trait type
+// that has not been registered.
+type UnknownTraitError struct {
+	Actor string
+	Trait string
+}
+
+func (e *UnknownTraitError) Error() string {
+	return fmt.Sprintf("actor %q uses trait %q which has not been registered", e.Actor, e.Trait)
+}
+
+// InvalidParametersError is returned when a trait panics while it initializes,
+// usually because of the parameters it was given. Reason is the value the trait
+// panicked with and Stack the stack trace of the panic, so bugs in the trait
+// itself can still be tracked down.
+type InvalidParametersError struct {
+	Actor  string
+	Trait  string
+	Reason interface{}
+	Stack  []byte
+}
+
+func (e *InvalidParametersError) Error() string {
+	return fmt.Sprintf("trait %q on actor %q failed to initialize: %v", e.Trait, e.Actor, e.Reason)
+}
+
+// Unwrap returns the reason when the trait panicked with an error.
+func (e *InvalidParametersError) Unwrap() error {
+	err, _ := e.Reason.(error)
+	return err
+}
+
+// InvalidTraitTypeError is returned when a value that can't be used to
+// construct traits is registered as a trait.
+type InvalidTraitTypeError struct {
+	Name  string
+	Value interface{}
+}
+
+func (e *InvalidTraitTypeError) Error() string {
+	return fmt.Sprintf("trait %q must be registered with a pointer to a munfall.Trait implementation, got %T", e.Name, e.Value)
+}
+
+// DuplicateTraitError is returned when a trait name is registered twice.
+type DuplicateTraitError struct {
+	Name string
+}
+
+func (e *DuplicateTraitError) Error() string {
+	return fmt.Sprintf("trait %q already exists in the trait registry", e.Name)
+}
+
+// DuplicateActorError is returned when an actor name is registered twice.
+type DuplicateActorError struct {
+	Name string
+}
+
+func (e *DuplicateActorError) Error() string {
+	return fmt.Sprintf("an actor with the name %q has already been registered", e.Name)
+}
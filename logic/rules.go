@@ -0,0 +1,57 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package logic rules.go Defines how actor definitions are read from rule files.
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type ruleActor struct {
+	Name   string
+	Tags   []string
+	Traits []ruleTrait
+}
+
+type ruleTrait struct {
+	Type       string
+	Parameters map[string]interface{}
+}
+
+// ReadActorDefinitions reads actor definitions from a JSON rule file of the form
+//
+//	[{"Name": "tank", "Tags": ["vehicle"], "Traits": [{"Type": "Health", "Parameters": {"HP": 100}}]}]
+//
+// Traits keep the order they are listed in, numbers are read as float64.
+func ReadActorDefinitions(r io.Reader) ([]*ActorDefinition, error) {
+	var rules []ruleActor
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+
+	definitions := make([]*ActorDefinition, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("actor definition %d has no name", len(definitions))
+		}
+
+		definition := CreateActorDefinition(rule.Name)
+		definition.AddTags(rule.Tags...)
+		for _, trait := range rule.Traits {
+			td := CreateTraitDefinition(trait.Type)
+			for key, value := range trait.Parameters {
+				td.AddParameter(key, value)
+			}
+
+			definition.AddTrait(td)
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package logic synchash.go Defines world checksums used to find the tick,
+// actor and trait at which two runs of the same game diverged.
+package logic
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// SyncReport holds the checksum of the world after a tick together with
+// the hashes it was combined from.
+type SyncReport struct {
+	Tick     uint64
+	Checksum uint64
+	Random   uint64
+	Actors   []ActorSyncHash
+}
+
+// ActorSyncHash holds the hashes of a single actor, ordered by trait type.
+type ActorSyncHash struct {
+	ActorID uint
+	Pos     munfall.WPos
+	Traits  []TraitSyncHash
+}
+
+// TraitSyncHash holds the hash reported by a traits.TraitSyncHash trait.
+type TraitSyncHash struct {
+	Type string
+	Hash uint64
+}
+
+// SyncDivergence describes a single difference between two reports.
+type SyncDivergence struct {
+	ActorID uint
+	Trait   string
+	Reason  string
+}
+
+func (d SyncDivergence) String() string {
+	if d.Trait != "" {
+		return fmt.Sprintf("actor %d trait %s: %s", d.ActorID, d.Trait, d.Reason)
+	}
+
+	return fmt.Sprintf("actor %d: %s", d.ActorID, d.Reason)
+}
+
+// SyncHistory keeps the reports of the last ticks.
+type SyncHistory struct {
+	reports []*SyncReport
+	next    int
+	count   int
+}
+
+// EnableSyncHash makes the world compute a SyncReport at the end of every tick,
+// the returned history keeps the last size reports.
+func EnableSyncHash(w munfall.World, size int) *SyncHistory {
+	if size <= 0 {
+		munfall.Logger.Panic("Sync history size must be larger than 0, got", size)
+	}
+
+	history := &SyncHistory{reports: make([]*SyncReport, size)}
+	w.(*world).syncHistory = history
+	return history
+}
+
+// DisableSyncHash stops the world from computing reports.
+func DisableSyncHash(w munfall.World) {
+	w.(*world).syncHistory = nil
+}
+
+// ComputeSyncReport computes a report of the current world state.
+func ComputeSyncReport(w munfall.World) *SyncReport {
+	return computeSyncReport(w.(*world))
+}
+
+func (h *SyncHistory) add(report *SyncReport) {
+	h.reports[h.next] = report
+	h.next = (h.next + 1) % len(h.reports)
+	if h.count < len(h.reports) {
+		h.count++
+	}
+}
+
+// Last returns the most recent report, nil if there is none.
+func (h *SyncHistory) Last() *SyncReport {
+	if h.count == 0 {
+		return nil
+	}
+
+	return h.reports[(h.next+len(h.reports)-1)%len(h.reports)]
+}
+
+// Report returns the report of the given tick, nil if it is no longer kept.
+func (h *SyncHistory) Report(tick uint64) *SyncReport {
+	for _, report := range h.Reports() {
+		if report.Tick == tick {
+			return report
+		}
+	}
+
+	return nil
+}
+
+// Reports returns the kept reports from oldest to newest.
+func (h *SyncHistory) Reports() []*SyncReport {
+	out := make([]*SyncReport, 0, h.count)
+	start := (h.next + len(h.reports) - h.count) % len(h.reports)
+	for i := 0; i < h.count; i++ {
+		out = append(out, h.reports[(start+i)%len(h.reports)])
+	}
+
+	return out
+}
+
+// DiffSyncReports lists the actors and traits that differ between the two reports,
+// the random number generator state is reported with actor ID 0 and trait "Random".
+func DiffSyncReports(a, b *SyncReport) []SyncDivergence {
+	var out []SyncDivergence
+	if a.Random != b.Random {
+		out = append(out, SyncDivergence{Trait: "Random", Reason: "random number generator state differs"})
+	}
+
+	i, j := 0, 0
+	for i < len(a.Actors) || j < len(b.Actors) {
+		switch {
+		case j == len(b.Actors) || (i < len(a.Actors) && a.Actors[i].ActorID < b.Actors[j].ActorID):
+			out = append(out, SyncDivergence{ActorID: a.Actors[i].ActorID, Reason: "only exists in the first report"})
+			i++
+		case i == len(a.Actors) || b.Actors[j].ActorID < a.Actors[i].ActorID:
+			out = append(out, SyncDivergence{ActorID: b.Actors[j].ActorID, Reason: "only exists in the second report"})
+			j++
+		default:
+			out = append(out, diffActors(&a.Actors[i], &b.Actors[j])...)
+			i++
+			j++
+		}
+	}
+
+	return out
+}
+
+func diffActors(a, b *ActorSyncHash) []SyncDivergence {
+	var out []SyncDivergence
+	if a.Pos != b.Pos {
+		out = append(out, SyncDivergence{
+			ActorID: a.ActorID,
+			Reason:  fmt.Sprintf("position %v differs from %v", a.Pos, b.Pos),
+		})
+	}
+
+	for k := 0; k < len(a.Traits) || k < len(b.Traits); k++ {
+		switch {
+		case k >= len(b.Traits):
+			out = append(out, SyncDivergence{ActorID: a.ActorID, Trait: a.Traits[k].Type, Reason: "only exists in the first report"})
+		case k >= len(a.Traits):
+			out = append(out, SyncDivergence{ActorID: a.ActorID, Trait: b.Traits[k].Type, Reason: "only exists in the second report"})
+		case a.Traits[k].Type != b.Traits[k].Type:
+			out = append(out, SyncDivergence{
+				ActorID: a.ActorID,
+				Trait:   a.Traits[k].Type,
+				Reason:  fmt.Sprintf("found trait %s in the second report instead", b.Traits[k].Type),
+			})
+		case a.Traits[k].Hash != b.Traits[k].Hash:
+			out = append(out, SyncDivergence{
+				ActorID: a.ActorID,
+				Trait:   a.Traits[k].Type,
+				Reason:  fmt.Sprintf("hash %x differs from %x", a.Traits[k].Hash, b.Traits[k].Hash),
+			})
+		}
+	}
+
+	return out
+}
+
+func computeSyncReport(w *world) *SyncReport {
+	checksum := fnv.New64a()
+	buf := make([]byte, 8)
+	write := func(v uint64) {
+		binary.BigEndian.PutUint64(buf, v)
+		checksum.Write(buf)
+	}
+
+	random := fnv.New64a()
+	state, _ := w.random.MarshalBinary()
+	random.Write(state)
+
+	report := &SyncReport{Tick: w.tick, Random: random.Sum64()}
+	write(report.Random)
+	for _, a := range w.sortedActors() {
+		ah := ActorSyncHash{ActorID: a.actorID, Pos: *a.pos}
+		write(uint64(a.actorID))
+		write(uint64(math.Float32bits(a.pos.X))<<32 | uint64(math.Float32bits(a.pos.Y)))
+		write(uint64(math.Float32bits(a.pos.Z)))
+
+		hashers := w.traitDictionary.GetTraitsImplementing(a, (*traits.TraitSyncHash)(nil))
+		for _, trait := range hashers {
+			ah.Traits = append(ah.Traits, TraitSyncHash{
+				Type: reflect.TypeOf(trait).String(),
+				Hash: trait.(traits.TraitSyncHash).SyncHash(),
+			})
+		}
+
+		sort.SliceStable(ah.Traits, func(i, j int) bool { return ah.Traits[i].Type < ah.Traits[j].Type })
+		for _, th := range ah.Traits {
+			checksum.Write([]byte(th.Type))
+			write(th.Hash)
+		}
+
+		report.Actors = append(report.Actors, ah)
+	}
+
+	report.Checksum = checksum.Sum64()
+	return report
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package logic
+
+import (
+	"testing"
+
+	"github.com/bluemun/munfall"
+)
+
+// hashedTrait counts its ticks and reports the count as its sync hash.
+type hashedTrait struct {
+	testTrait
+	state uint64
+}
+
+func (t *hashedTrait) Tick(deltaUnit float32) {
+	t.state++
+}
+
+func (t *hashedTrait) SyncHash() uint64 {
+	return t.state
+}
+
+// createSyncedWorld creates a world with two hashed actors that keeps the
+// reports of the last size ticks.
+func createSyncedWorld(size int) (munfall.World, []munfall.Actor, *SyncHistory) {
+	ar, w := createTestRegistry()
+	ar.RegisterTrait("Hashed", (*hashedTrait)(nil))
+	hashed := CreateActorDefinition("hashed")
+	hashed.AddTrait(CreateTraitDefinition("Hashed"))
+	ar.RegisterActor(hashed)
+
+	actors := []munfall.Actor{ar.CreateActor("hashed", nil, w, true), ar.CreateActor("hashed", nil, w, true)}
+	return w, actors, EnableSyncHash(w, size)
+}
+
+func TestSyncHistoryFindsFirstDivergence(t *testing.T) {
+	w1, actors1, h1 := createSyncedWorld(16)
+	w2, actors2, h2 := createSyncedWorld(16)
+	for tick := 1; tick <= 10; tick++ {
+		if tick == 6 {
+			// The second run skips a step of one trait.
+			w2.GetTrait(actors2[1], (*hashedTrait)(nil)).(*hashedTrait).state--
+		}
+
+		w1.Tick(1)
+		w2.Tick(1)
+	}
+
+	var first *SyncReport
+	reports1, reports2 := h1.Reports(), h2.Reports()
+	for i := range reports1 {
+		if reports1[i].Tick != reports2[i].Tick {
+			t.Fatalf("histories hold ticks %d and %d at %d", reports1[i].Tick, reports2[i].Tick, i)
+		}
+
+		if reports1[i].Checksum != reports2[i].Checksum {
+			first = reports1[i]
+			break
+		}
+	}
+
+	if first == nil || first.Tick != 6 {
+		t.Fatalf("first differing report is %+v, want tick 6", first)
+	}
+
+	diff := DiffSyncReports(first, h2.Report(first.Tick))
+	if len(diff) != 1 || diff[0].ActorID != actors1[1].ActorID() || diff[0].Trait != "*logic.hashedTrait" {
+		t.Errorf("diff at tick 6 is %v", diff)
+	}
+
+	if h1.Report(first.Tick-1).Checksum != h2.Report(first.Tick-1).Checksum {
+		t.Error("reports before the divergence differ")
+	}
+}
+
+func TestDiffSyncReports(t *testing.T) {
+	w1, actors1, _ := createSyncedWorld(1)
+	w2, actors2, _ := createSyncedWorld(1)
+	if diff := DiffSyncReports(ComputeSyncReport(w1), ComputeSyncReport(w2)); len(diff) != 0 {
+		t.Fatalf("identical worlds differ: %v", diff)
+	}
+
+	actors2[0].SetPos(&munfall.WPos{X: 1})
+	w2.Random().Uint64()
+	actors1[1].Kill()
+	diff := DiffSyncReports(ComputeSyncReport(w1), ComputeSyncReport(w2))
+	if len(diff) != 3 {
+		t.Fatalf("expected 3 differences, got %v", diff)
+	}
+
+	if diff[0].Trait != "Random" {
+		t.Errorf("random state difference reported as %v", diff[0])
+	}
+
+	if diff[1].ActorID != actors1[0].ActorID() || diff[1].Trait != "" {
+		t.Errorf("position difference reported as %v", diff[1])
+	}
+
+	if diff[2].ActorID != actors2[1].ActorID() || diff[2].Reason != "only exists in the second report" {
+		t.Errorf("missing actor reported as %v", diff[2])
+	}
+}
+
+func TestSyncHistoryKeepsLastTicks(t *testing.T) {
+	w, _, h := createSyncedWorld(4)
+	if h.Last() != nil {
+		t.Fatal("empty history has a last report")
+	}
+
+	for i := 0; i < 10; i++ {
+		w.Tick(1)
+	}
+
+	reports := h.Reports()
+	if len(reports) != 4 || reports[0].Tick != 7 || h.Last().Tick != 10 {
+		t.Fatalf("history holds %d reports from tick %d to %d", len(reports), reports[0].Tick, h.Last().Tick)
+	}
+
+	if h.Report(6) != nil || h.Report(8) != reports[1] {
+		t.Error("Report doesn't look up the kept ticks")
+	}
+
+	DisableSyncHash(w)
+	w.Tick(1)
+	if h.Last().Tick != 10 {
+		t.Error("reports were added after DisableSyncHash")
+	}
+}
@@ -12,17 +12,47 @@ import (
 	"github.com/bluemun/munfall"
 )
 
-// TraitDictionary holds traits for easy lookup.
+// TraitDictionary holds traits for easy lookup, traits are kept in a dense
+// store per trait type so iterating all traits of a type walks a single slice.
 type traitDictionary struct {
-	traits map[reflect.Type]map[uint][]munfall.Trait
-	world  *world
+	// stores are kept in the order their type was first added so iteration
+	// order doesn't depend on map ordering.
+	stores []*traitStore
+	byType map[reflect.Type]*traitStore
+	// implementing caches the stores whose type implements an interface type.
+	implementing map[reflect.Type][]*traitStore
+	world        *world
+}
+
+// sparsePageSize is the number of actor IDs covered by a page of a
+// traitStore's sparse array.
+const sparsePageSize = 1024
+
+// traitStore is a sparse set of the traits of a single type. dense holds the
+// traits packed together, sparse maps an actor ID to the position of the
+// actor's first trait in dense, an actor with more than one trait of the same
+// type chains the rest through next. Positions are stored off by one so the
+// zero value means none.
+//
+// sparse is split into pages that are allocated when the first actor in
+// their range gets a trait and freed when the last one loses it, actor IDs
+// aren't reused so pages of long dead actors don't stay around.
+type traitStore struct {
+	traitType reflect.Type
+	dense     []munfall.Trait
+	owners    []uint
+	next      []int32
+	sparse    [][]int32
+	// used counts the actors in every page that have a trait.
+	used []int
 }
 
 // CreateTraitDictionary creates and initializes the traitManager.
 func createTraitDictionary(w *world) *traitDictionary {
 	return &traitDictionary{
-		traits: make(map[reflect.Type]map[uint][]munfall.Trait),
-		world:  w,
+		byType:       make(map[reflect.Type]*traitStore),
+		implementing: make(map[reflect.Type][]*traitStore),
+		world:        w,
 	}
 }
 
@@ -33,24 +63,29 @@ func (td *traitDictionary) addTrait(a *actor, t munfall.Trait) {
 	}
 
 	traittype := reflect.TypeOf(t)
-	at, exist := td.traits[traittype]
+	store, exist := td.byType[traittype]
 	if !exist {
-		at = make(map[uint][]munfall.Trait)
-		td.traits[traittype] = at
+		store = &traitStore{traitType: traittype}
+		td.byType[traittype] = store
+		td.stores = append(td.stores, store)
+		for required, stores := range td.implementing {
+			if traittype.Implements(required) {
+				td.implementing[required] = append(stores, store)
+			}
+		}
 	}
 
-	traits, exist := at[a.ActorID()]
-	if !exist {
-		at[a.ActorID()] = []munfall.Trait{t}
-	} else {
-		at[a.ActorID()] = append(traits, t)
-	}
+	store.add(a.ActorID(), t)
+}
+
+func (td *traitDictionary) removeTrait(a *actor, t munfall.Trait) bool {
+	store, exists := td.byType[reflect.TypeOf(t)]
+	return exists && store.remove(a.ActorID(), t)
 }
 
 func (td *traitDictionary) removeActor(a *actor) {
-	for _, at := range td.traits {
-		munfall.Logger.Info("Deleted", a.ActorID(), at)
-		delete(at, a.ActorID())
+	for _, store := range td.stores {
+		store.removeActor(a.ActorID())
 	}
 }
 
@@ -58,26 +93,25 @@ func (td *traitDictionary) removeActor(a *actor) {
 // panics if the trait doesn't exist.
 func (td *traitDictionary) GetTrait(a *actor, i interface{}) munfall.Trait {
 	t := reflect.TypeOf(i)
-	traits, exists := td.traits[t][a.ActorID()]
-	if !exists || len(traits) != 1 {
-		munfall.Logger.Panic("Trait", t, "doesnt exist on actor", a.ActorID())
+	store, exists := td.byType[t]
+	if exists {
+		if first := store.first(a.ActorID()); first >= 0 && store.next[first] == 0 {
+			return store.dense[first]
+		}
 	}
 
-	return traits[0]
+	munfall.Logger.Panic("Trait", t, "doesnt exist on actor", a.ActorID())
+	return nil
 }
 
 // GetTraitsImplementing gets all the traits on the given actor that implement
 // the given Trait interface.
 func (td *traitDictionary) GetTraitsImplementing(a *actor, i interface{}) []munfall.Trait {
 	out := make([]munfall.Trait, 0, 1)
-	requiredType := reflect.TypeOf(i).Elem()
-	for traitType, actorMap := range td.traits {
-		munfall.Logger.Debug(traitType, "->", requiredType, "=", traitType.Implements(requiredType), "_", a.ActorID(), ":", actorMap)
-		traits, exists := actorMap[a.ActorID()]
-		if exists && traitType.Implements(requiredType) {
-			for _, trait := range traits {
-				out = append(out, trait)
-			}
+	id := a.ActorID()
+	for _, store := range td.storesImplementing(i) {
+		for index := store.first(id); index >= 0; index = int(store.next[index]) - 1 {
+			out = append(out, store.dense[index])
 		}
 	}
 
@@ -87,18 +121,131 @@ func (td *traitDictionary) GetTraitsImplementing(a *actor, i interface{}) []munf
 // GetAllTraitsImplementing gets all the traits that are in the dictionary
 // that implement the given interface.
 func (td *traitDictionary) GetAllTraitsImplementing(i interface{}) []munfall.Trait {
-	out := make([]munfall.Trait, 0, 1)
-	requiredType := reflect.TypeOf(i).Elem()
-	munfall.Logger.Debug("Check for traits implementing", requiredType)
-	for traitType, actorMap := range td.traits {
-		munfall.Logger.Debug(traitType, "->", requiredType, "=", traitType.Implements(requiredType), "_", actorMap)
-		if traitType.Implements(requiredType) {
-			for _, y := range actorMap {
-				out = append(out, y...)
+	stores := td.storesImplementing(i)
+	count := 0
+	for _, store := range stores {
+		count += len(store.dense)
+	}
+
+	out := make([]munfall.Trait, 0, count)
+	for _, store := range stores {
+		out = append(out, store.dense...)
+	}
+
+	return out
+}
+
+// storesImplementing returns the stores whose trait type implements the
+// interface i points to, the result is cached until a new trait type is added.
+func (td *traitDictionary) storesImplementing(i interface{}) []*traitStore {
+	required := reflect.TypeOf(i).Elem()
+	stores, exists := td.implementing[required]
+	if !exists {
+		stores = make([]*traitStore, 0, 1)
+		for _, store := range td.stores {
+			if store.traitType.Implements(required) {
+				stores = append(stores, store)
 			}
 		}
+
+		td.implementing[required] = stores
 	}
 
-	munfall.Logger.Debug(requiredType, ":=", out)
-	return out
+	return stores
+}
+
+// first returns the dense position of the first trait of the actor, -1 if
+// the actor has none.
+func (s *traitStore) first(id uint) int {
+	page := id / sparsePageSize
+	if page >= uint(len(s.sparse)) || s.sparse[page] == nil {
+		return -1
+	}
+
+	return int(s.sparse[page][id%sparsePageSize]) - 1
+}
+
+func (s *traitStore) add(id uint, t munfall.Trait) {
+	page := int(id / sparsePageSize)
+	for page >= len(s.sparse) {
+		s.sparse = append(s.sparse, nil)
+		s.used = append(s.used, 0)
+	}
+
+	if s.sparse[page] == nil {
+		s.sparse[page] = make([]int32, sparsePageSize)
+	}
+
+	s.dense = append(s.dense, t)
+	s.owners = append(s.owners, id)
+	s.next = append(s.next, 0)
+	position := int32(len(s.dense))
+	link := s.head(id)
+	if *link == 0 {
+		s.used[page]++
+	}
+
+	for *link != 0 {
+		link = &s.next[*link-1]
+	}
+
+	*link = position
+}
+
+func (s *traitStore) remove(id uint, t munfall.Trait) bool {
+	for index := s.first(id); index >= 0; index = int(s.next[index]) - 1 {
+		if s.dense[index] == t {
+			s.removeAt(index)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *traitStore) removeActor(id uint) {
+	for index := s.first(id); index >= 0; index = s.first(id) {
+		s.removeAt(index)
+	}
+}
+
+// removeAt unlinks the trait at index and moves the last trait into its place.
+func (s *traitStore) removeAt(index int) {
+	owner := s.owners[index]
+	*s.linkTo(index) = s.next[index]
+	if *s.head(owner) == 0 {
+		page := owner / sparsePageSize
+		s.used[page]--
+		if s.used[page] == 0 {
+			s.sparse[page] = nil
+		}
+	}
+
+	last := len(s.dense) - 1
+	if index != last {
+		*s.linkTo(last) = int32(index + 1)
+		s.dense[index] = s.dense[last]
+		s.owners[index] = s.owners[last]
+		s.next[index] = s.next[last]
+	}
+
+	s.dense[last] = nil
+	s.dense = s.dense[:last]
+	s.owners = s.owners[:last]
+	s.next = s.next[:last]
+}
+
+// head returns the link to the first trait of the actor, its page has to exist.
+func (s *traitStore) head(id uint) *int32 {
+	return &s.sparse[id/sparsePageSize][id%sparsePageSize]
+}
+
+// linkTo returns the link that points at the given dense position.
+func (s *traitStore) linkTo(index int) *int32 {
+	link := s.head(s.owners[index])
+	for int(*link)-1 != index {
+		link = &s.next[*link-1]
+	}
+
+	return link
 }
@@ -0,0 +1,206 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package logic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// mapTraitDictionary is the map of maps layout the trait dictionary used before
+// it switched to sparse sets, kept to compare the two in the benchmarks. The
+// debug logging of the old layout is left out so only the layouts are compared.
+type mapTraitDictionary struct {
+	traits map[reflect.Type]map[uint][]munfall.Trait
+}
+
+func (td *mapTraitDictionary) addTrait(a *actor, t munfall.Trait) {
+	traittype := reflect.TypeOf(t)
+	at, exist := td.traits[traittype]
+	if !exist {
+		at = make(map[uint][]munfall.Trait)
+		td.traits[traittype] = at
+	}
+
+	at[a.ActorID()] = append(at[a.ActorID()], t)
+}
+
+func (td *mapTraitDictionary) GetTraitsImplementing(a *actor, i interface{}) []munfall.Trait {
+	out := make([]munfall.Trait, 0, 1)
+	requiredType := reflect.TypeOf(i).Elem()
+	for traitType, actorMap := range td.traits {
+		traits, exists := actorMap[a.ActorID()]
+		if exists && traitType.Implements(requiredType) {
+			out = append(out, traits...)
+		}
+	}
+
+	return out
+}
+
+func (td *mapTraitDictionary) GetAllTraitsImplementing(i interface{}) []munfall.Trait {
+	out := make([]munfall.Trait, 0, 1)
+	requiredType := reflect.TypeOf(i).Elem()
+	for traitType, actorMap := range td.traits {
+		if traitType.Implements(requiredType) {
+			for _, traits := range actorMap {
+				out = append(out, traits...)
+			}
+		}
+	}
+
+	return out
+}
+
+type tickingTrait struct {
+	testTrait
+	ticks int
+}
+
+func (t *tickingTrait) Tick(deltaUnit float32) {
+	t.ticks++
+}
+
+type orderTrait struct {
+	testTrait
+}
+
+func (t *orderTrait) ResolveOrder(order *munfall.Order) {}
+
+// dictionaryLayout is implemented by both layouts.
+type dictionaryLayout interface {
+	addTrait(a *actor, t munfall.Trait)
+	GetTraitsImplementing(a *actor, i interface{}) []munfall.Trait
+	GetAllTraitsImplementing(i interface{}) []munfall.Trait
+}
+
+// fillDictionary gives n actors a ticking trait and a plain trait, every
+// other actor also gets an order trait.
+func fillDictionary(td dictionaryLayout, n int) []*actor {
+	actors := make([]*actor, n)
+	for i := range actors {
+		a := &actor{actorID: uint(i)}
+		td.addTrait(a, &tickingTrait{testTrait: testTrait{owner: a}})
+		td.addTrait(a, &testTrait{owner: a})
+		if i%2 == 0 {
+			td.addTrait(a, &orderTrait{testTrait: testTrait{owner: a}})
+		}
+
+		actors[i] = a
+	}
+
+	return actors
+}
+
+var layouts = []struct {
+	name   string
+	create func() dictionaryLayout
+}{
+	{"SparseSet", func() dictionaryLayout { return createTraitDictionary(nil) }},
+	{"MapOfMaps", func() dictionaryLayout {
+		return &mapTraitDictionary{traits: make(map[reflect.Type]map[uint][]munfall.Trait)}
+	}},
+}
+
+func TestTraitDictionaryMatchesMapLayout(t *testing.T) {
+	sparse := createTraitDictionary(nil)
+	maps := &mapTraitDictionary{traits: make(map[reflect.Type]map[uint][]munfall.Trait)}
+	actors := fillDictionary(sparse, 100)
+	fillDictionary(maps, 100)
+
+	for _, i := range []interface{}{(*traits.TraitTicker)(nil), (*traits.TraitOrderResolver)(nil), (*munfall.Trait)(nil)} {
+		if got, want := len(sparse.GetAllTraitsImplementing(i)), len(maps.GetAllTraitsImplementing(i)); got != want {
+			t.Errorf("%T: got %d traits, want %d", i, got, want)
+		}
+
+		for _, a := range actors {
+			for _, trait := range sparse.GetTraitsImplementing(a, i) {
+				if trait.Owner() != a {
+					t.Fatalf("%T: actor %d got a trait of actor %d", i, a.ActorID(), trait.Owner().ActorID())
+				}
+			}
+
+			if got, want := len(sparse.GetTraitsImplementing(a, i)), len(maps.GetTraitsImplementing(&actor{actorID: a.actorID}, i)); got != want {
+				t.Errorf("%T: actor %d got %d traits, want %d", i, a.ActorID(), got, want)
+			}
+		}
+	}
+}
+
+func TestTraitStoreFreesPages(t *testing.T) {
+	td := createTraitDictionary(nil)
+	var alive []*actor
+	// Actors come and go with ever growing IDs, only the last few stay.
+	for id := uint(0); id < 10*sparsePageSize; id++ {
+		a := &actor{actorID: id}
+		td.addTrait(a, &testTrait{owner: a})
+		td.addTrait(a, &testTrait{owner: a})
+		alive = append(alive, a)
+		if len(alive) > 8 {
+			td.removeActor(alive[0])
+			alive = alive[1:]
+		}
+	}
+
+	store := td.byType[reflect.TypeOf(&testTrait{})]
+	pages := 0
+	for _, page := range store.sparse {
+		if page != nil {
+			pages++
+		}
+	}
+
+	if pages > 2 {
+		t.Errorf("%d pages are allocated for 8 actors", pages)
+	}
+
+	for _, a := range alive {
+		if got := len(td.GetTraitsImplementing(a, (*munfall.Trait)(nil))); got != 2 {
+			t.Errorf("actor %d has %d traits, want 2", a.ActorID(), got)
+		}
+	}
+
+	td.removeTrait(alive[0], td.GetTraitsImplementing(alive[0], (*munfall.Trait)(nil))[0])
+	if got := len(td.GetTraitsImplementing(alive[0], (*munfall.Trait)(nil))); got != 1 {
+		t.Errorf("actor %d has %d traits after removing one, want 1", alive[0].ActorID(), got)
+	}
+
+	if first := store.first(0); first != -1 {
+		t.Errorf("removed actor 0 still has a trait at %d", first)
+	}
+}
+
+func BenchmarkGetAllTraitsImplementing50k(b *testing.B) {
+	for _, layout := range layouts {
+		b.Run(layout.name, func(b *testing.B) {
+			td := layout.create()
+			fillDictionary(td, 50000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, trait := range td.GetAllTraitsImplementing((*traits.TraitTicker)(nil)) {
+					trait.(traits.TraitTicker).Tick(1)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetTraitsImplementing50k(b *testing.B) {
+	for _, layout := range layouts {
+		b.Run(layout.name, func(b *testing.B) {
+			td := layout.create()
+			actors := fillDictionary(td, 50000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, a := range actors {
+					td.GetTraitsImplementing(a, (*traits.TraitOrderResolver)(nil))
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,9 @@
 package logic
 
 import (
+	"reflect"
+	"sort"
+
 	"github.com/bluemun/munfall"
 	"github.com/bluemun/munfall/traits"
 )
@@ -15,12 +18,24 @@ type world struct {
 	actors          map[uint]*actor
 	traitDictionary *traitDictionary
 	endtasks        []func()
+	commands        commandQueue
+	random          *munfall.Random
+	profiler        *munfall.Profiler
 	wm              munfall.WorldMap
+
+	tick        uint64
+	syncHistory *SyncHistory
+
+	paused bool
+	steps  int
+
+	tagged map[string]map[uint]*actor
 }
 
-// CreateWorld creates and initializes the World.
+// CreateWorld creates and initializes the World, its random number generator
+// starts with seed 0 and can be reseeded through Random.
 func CreateWorld(wm munfall.WorldMap) munfall.World {
-	world := &world{actors: make(map[uint]*actor, 10), endtasks: nil, wm: wm}
+	world := &world{actors: make(map[uint]*actor, 10), endtasks: nil, random: munfall.CreateRandom(0), profiler: munfall.CreateProfiler(), tagged: make(map[string]map[uint]*actor), wm: wm}
 	world.traitDictionary = createTraitDictionary(world)
 	wm.Initialize(world)
 	return (munfall.World)(world)
@@ -43,12 +58,91 @@ func (w *world) GetAllTraitsImplementing(i interface{}) []munfall.Trait {
 	return w.traitDictionary.GetAllTraitsImplementing(i)
 }
 
+// AddTrait creates a trait of the type given as a typed nil pointer, the same way
+// GetTrait is called, initializes it with the parameters and adds it to the actor.
+func (w *world) AddTrait(a munfall.Actor, i interface{}, parameters map[string]interface{}) munfall.Trait {
+	t := reflect.TypeOf(i)
+	if t == nil || t.Kind() != reflect.Ptr || !t.Implements(reflect.TypeOf((*munfall.Trait)(nil)).Elem()) {
+		munfall.Logger.Panic(i, "is not a pointer to a Trait implementation.")
+	}
+
+	trait := reflect.New(t.Elem()).Interface().(munfall.Trait)
+	trait.Initialize(w, a, parameters)
+	w.AddInitializedTrait(a, trait)
+	return trait
+}
+
+// AddInitializedTrait adds an already initialized trait to the actor. If the actor
+// is in the world its map registration is updated, the trait is notified that it
+// was added to the world and the other traits on the actor are notified of the new trait.
+func (w *world) AddInitializedTrait(a munfall.Actor, t munfall.Trait) {
+	actor := a.(*actor)
+	if actor.inworld {
+		w.wm.Deregister(a)
+	}
+
+	w.traitDictionary.addTrait(actor, t)
+	actor.addedTraits = append(actor.addedTraits, t)
+
+	if actor.inworld {
+		w.wm.Register(a)
+		if notify, ok := t.(traits.TraitAddedToWorldNotifier); ok {
+			notify.NotifyAddedToWorld()
+		}
+	}
+
+	for _, trait := range w.traitDictionary.GetTraitsImplementing(actor, (*traits.TraitAddedNotifier)(nil)) {
+		if trait != t {
+			trait.(traits.TraitAddedNotifier).NotifyTraitAdded(t)
+		}
+	}
+}
+
+// RemoveTrait removes the trait from the actor, the trait is notified that it was
+// removed from the world and the remaining traits are notified of its removal.
+func (w *world) RemoveTrait(a munfall.Actor, t munfall.Trait) {
+	actor := a.(*actor)
+	if actor.inworld {
+		w.wm.Deregister(a)
+	}
+
+	removed := w.traitDictionary.removeTrait(actor, t)
+	if actor.inworld {
+		w.wm.Register(a)
+	}
+
+	if !removed {
+		return
+	}
+
+	for i, trait := range actor.addedTraits {
+		if trait == t {
+			actor.addedTraits = append(actor.addedTraits[:i:i], actor.addedTraits[i+1:]...)
+			break
+		}
+	}
+
+	if notify, ok := t.(traits.TraitRemovedFromWorldNotifier); ok && actor.inworld {
+		notify.NotifyRemovedFromWorld()
+	}
+
+	for _, trait := range w.traitDictionary.GetTraitsImplementing(actor, (*traits.TraitRemovedNotifier)(nil)) {
+		trait.(traits.TraitRemovedNotifier).NotifyTraitRemoved(t)
+	}
+
+	if disposer, ok := t.(traits.TraitDisposer); ok {
+		disposer.Dispose()
+	}
+}
+
 // IssueGlobalOrder issues an order to be resolved by every TraitOrderResolver.
 func (w *world) IssueGlobalOrder(order *munfall.Order) {
 	order.IsGlobal = true
 	resolvers := w.traitDictionary.GetAllTraitsImplementing((*traits.TraitOrderResolver)(nil))
 	for _, trait := range resolvers {
+		sample := w.profiler.Begin()
 		trait.(traits.TraitOrderResolver).ResolveOrder(order)
+		w.profiler.End("ResolveOrder", trait, sample)
 	}
 }
 
@@ -57,17 +151,81 @@ func (w *world) IssueOrder(a munfall.Actor, order *munfall.Order) {
 	order.IsGlobal = false
 	resolvers := w.traitDictionary.GetTraitsImplementing(a.(*actor), (*traits.TraitOrderResolver)(nil))
 	for _, trait := range resolvers {
+		sample := w.profiler.Begin()
 		trait.(traits.TraitOrderResolver).ResolveOrder(order)
+		w.profiler.End("ResolveOrder", trait, sample)
 	}
 }
 
-// Tick ticks all traits on the traitmanager that implement the Tick interface.
+// QueueCommand queues a function to be run on the world at the start of the
+// next tick, safe to call from any goroutine.
+func (w *world) QueueCommand(f func(munfall.World)) {
+	w.commands.push(func() { f(w) })
+}
+
+// QueueKill queues the given actor to be killed at the start of the next tick,
+// safe to call from any goroutine.
+func (w *world) QueueKill(a munfall.Actor) {
+	w.commands.push(func() {
+		if !a.IsDead() {
+			a.Kill()
+		}
+	})
+}
+
+// QueueGlobalOrder queues a global order to be issued at the start of the next tick,
+// safe to call from any goroutine.
+func (w *world) QueueGlobalOrder(order *munfall.Order) {
+	w.commands.push(func() { w.IssueGlobalOrder(order) })
+}
+
+// QueueOrder queues an order for the given actor to be issued at the start of
+// the next tick, safe to call from any goroutine. The order is dropped if the
+// actor has died in the meantime.
+func (w *world) QueueOrder(a munfall.Actor, order *munfall.Order) {
+	w.commands.push(func() {
+		if !a.IsDead() {
+			w.IssueOrder(a, order)
+		}
+	})
+}
+
+// Tick applies all queued commands and then ticks all traits on the
+// traitmanager that implement the Tick interface. While the world is paused
+// only the commands and frame end tasks are run.
 func (w *world) Tick(deltaUnit float32) {
+	for _, command := range w.commands.drain() {
+		command()
+	}
+
+	if w.paused {
+		if w.steps == 0 {
+			w.runEndTasks()
+			return
+		}
+
+		w.steps--
+	}
+
 	tickers := w.traitDictionary.GetAllTraitsImplementing((*traits.TraitTicker)(nil))
 	for _, ticker := range tickers {
+		sample := w.profiler.Begin()
 		ticker.(traits.TraitTicker).Tick(deltaUnit)
+		w.profiler.End("Tick", ticker, sample)
+	}
+
+	w.runEndTasks()
+	if ticker, ok := w.wm.(munfall.WorldMapTicker); ok {
+		ticker.Tick(deltaUnit)
+	}
+
+	w.tick++
+	if w.syncHistory != nil {
+		w.syncHistory.add(computeSyncReport(w))
 	}
+}
 
+func (w *world) runEndTasks() {
 	for _, task := range w.endtasks {
 		task()
 	}
@@ -75,23 +233,134 @@ func (w *world) Tick(deltaUnit float32) {
 	w.endtasks = nil
 }
 
+// Pause stops traits from being ticked until Resume is called.
+func (w *world) Pause() {
+	w.paused = true
+	w.steps = 0
+}
+
+// Resume continues ticking traits after Pause.
+func (w *world) Resume() {
+	w.paused = false
+	w.steps = 0
+}
+
+// IsPaused returns if the world is paused.
+func (w *world) IsPaused() bool {
+	return w.paused
+}
+
+// Step lets the given number of ticks run while the world is paused.
+func (w *world) Step(ticks int) {
+	if w.paused {
+		w.steps += ticks
+	}
+}
+
+// GetActor returns the actor with the given ID if it is in the world, nil otherwise.
+func (w *world) GetActor(id uint) munfall.Actor {
+	a, exists := w.actors[id]
+	if !exists {
+		return nil
+	}
+
+	return a
+}
+
+// Actors returns all actors in the world ordered by their ID.
+func (w *world) Actors() []munfall.Actor {
+	sorted := w.sortedActors()
+	actors := make([]munfall.Actor, len(sorted))
+	for i, a := range sorted {
+		actors[i] = a
+	}
+
+	return actors
+}
+
+// ActorsWithTag returns the actors in the world that have the given tag ordered by their ID.
+func (w *world) ActorsWithTag(tag string) []munfall.Actor {
+	tagged := w.tagged[tag]
+	ids := make([]uint, 0, len(tagged))
+	for id := range tagged {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	actors := make([]munfall.Actor, len(ids))
+	for i, id := range ids {
+		actors[i] = tagged[id]
+	}
+
+	return actors
+}
+
+func (w *world) indexTags(a *actor) {
+	for tag := range a.tags {
+		tagged, exists := w.tagged[tag]
+		if !exists {
+			tagged = make(map[uint]*actor)
+			w.tagged[tag] = tagged
+		}
+
+		tagged[a.actorID] = a
+	}
+}
+
+func (w *world) unindexTags(a *actor) {
+	for tag := range a.tags {
+		delete(w.tagged[tag], a.actorID)
+	}
+}
+
+// setTags replaces the tags of the actor and keeps the tag index up to date.
+func (w *world) setTags(a *actor, tags map[string]bool) {
+	if a.inworld {
+		w.unindexTags(a)
+	}
+
+	a.tags = tags
+	if a.inworld {
+		w.indexTags(a)
+	}
+}
+
+// AddToWorld adds the actor to the world, attached actors that are not
+// in the world yet are added with it.
 func (w *world) AddToWorld(a munfall.Actor) {
 	actor := a.(*actor)
 	actor.inworld = true
 	w.actors[a.ActorID()] = actor
+	w.indexTags(actor)
 	w.wm.Register(a)
 	notify := w.GetTraitsImplementing(a, (*traits.TraitAddedToWorldNotifier)(nil))
 	for _, trait := range notify {
 		trait.(traits.TraitAddedToWorldNotifier).NotifyAddedToWorld()
 	}
+
+	for _, child := range actor.children {
+		if !child.inworld && !child.dead {
+			w.AddToWorld(child)
+		}
+	}
 }
 
+// RemoveFromWorld removes the actor from the world, attached actors
+// are removed with it.
 func (w *world) RemoveFromWorld(a munfall.Actor) {
 	if a == nil {
 		panic("Trying to remove nil as an Actor!")
 	}
 
+	for _, child := range a.(*actor).children {
+		if child.inworld {
+			w.RemoveFromWorld(child)
+		}
+	}
+
 	a.(*actor).inworld = false
+	delete(w.actors, a.ActorID())
+	w.unindexTags(a.(*actor))
 	w.wm.Deregister(a)
 	notify := w.traitDictionary.GetTraitsImplementing(a.(*actor), (*traits.TraitRemovedFromWorldNotifier)(nil))
 	for _, trait := range notify {
@@ -99,8 +368,128 @@ func (w *world) RemoveFromWorld(a munfall.Actor) {
 	}
 }
 
+// Attach attaches the child to the parent, the child is placed at the parent
+// position plus the offset and follows the parent when it moves on the world map.
+// Attached actors are removed from the world and killed together with their parent.
+func (w *world) Attach(parent, child munfall.Actor, offset *munfall.WPos) {
+	p, c := parent.(*actor), child.(*actor)
+	for iter := p; iter != nil; iter = iter.parent {
+		if iter == c {
+			munfall.Logger.Panic("Attaching actor", c.ActorID(), "to", p.ActorID(), "would create a cycle.")
+		}
+	}
+
+	if c.parent != nil {
+		w.Detach(c)
+	}
+
+	if offset == nil {
+		offset = &munfall.WPos{}
+	}
+
+	c.parent = p
+	c.attachOffset = offset
+	p.children = append(p.children, c)
+
+	old := c.pos
+	if c.inworld {
+		w.wm.Deregister(c)
+	}
+
+	c.SetPos(p.pos.Add(offset))
+	if c.inworld {
+		w.wm.Register(c)
+	}
+
+	for _, trait := range w.GetTraitsImplementing(c, (*traits.MoveNotifier)(nil)) {
+		trait.(traits.MoveNotifier).NotifyMove(old, c.pos)
+	}
+}
+
+// Detach detaches the child from its parent, it keeps its current position.
+func (w *world) Detach(child munfall.Actor) {
+	c := child.(*actor)
+	if c.parent == nil {
+		return
+	}
+
+	children := c.parent.children
+	for i, other := range children {
+		if other == c {
+			c.parent.children = append(children[:i], children[i+1:]...)
+			break
+		}
+	}
+
+	c.parent = nil
+	c.attachOffset = nil
+}
+
+// replaceTraits swaps all the traits on the actor for the given ones, the
+// old traits are notified of their removal and disposed of and the new ones
+// are notified of their addition.
+func (w *world) replaceTraits(a *actor, created []munfall.Trait) {
+	if a.inworld {
+		w.wm.Deregister(a)
+		notify := w.traitDictionary.GetTraitsImplementing(a, (*traits.TraitRemovedFromWorldNotifier)(nil))
+		for _, trait := range notify {
+			trait.(traits.TraitRemovedFromWorldNotifier).NotifyRemovedFromWorld()
+		}
+	}
+
+	disposers := w.traitDictionary.GetTraitsImplementing(a, (*traits.TraitDisposer)(nil))
+	w.traitDictionary.removeActor(a)
+	for _, trait := range created {
+		w.traitDictionary.addTrait(a, trait)
+	}
+
+	for _, trait := range a.addedTraits {
+		w.traitDictionary.addTrait(a, trait)
+	}
+
+	if a.inworld {
+		w.wm.Register(a)
+		notify := w.traitDictionary.GetTraitsImplementing(a, (*traits.TraitAddedToWorldNotifier)(nil))
+		for _, trait := range notify {
+			trait.(traits.TraitAddedToWorldNotifier).NotifyAddedToWorld()
+		}
+	}
+
+	for _, trait := range disposers {
+		if !a.hasAddedTrait(trait) {
+			trait.(traits.TraitDisposer).Dispose()
+		}
+	}
+}
+
+// sortedActors returns the actors in the world ordered by their ID.
+func (w *world) sortedActors() []*actor {
+	actors := make([]*actor, 0, len(w.actors))
+	for _, a := range w.actors {
+		actors = append(actors, a)
+	}
+
+	sort.Slice(actors, func(i, j int) bool { return actors[i].actorID < actors[j].actorID })
+	return actors
+}
+
 func (w *world) cleanTraits(a munfall.Actor) {
+	disposers := w.traitDictionary.GetTraitsImplementing(a.(*actor), (*traits.TraitDisposer)(nil))
 	w.traitDictionary.removeActor(a.(*actor))
+	for _, trait := range disposers {
+		trait.(traits.TraitDisposer).Dispose()
+	}
+}
+
+// Profiler returns the profiler that measures the traits called by the world.
+func (w *world) Profiler() *munfall.Profiler {
+	return w.profiler
+}
+
+// Random returns the deterministic random number generator owned by the world,
+// traits should derive their own stream from it with Stream.
+func (w *world) Random() *munfall.Random {
+	return w.random
 }
 
 func (w *world) WorldMap() munfall.WorldMap {
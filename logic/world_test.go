@@ -0,0 +1,357 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package logic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/traits"
+)
+
+// buffTrait records the lifecycle notifications it receives.
+type buffTrait struct {
+	testTrait
+	events []string
+}
+
+func (t *buffTrait) NotifyAddedToWorld() {
+	t.events = append(t.events, "added to world")
+}
+
+func (t *buffTrait) NotifyRemovedFromWorld() {
+	t.events = append(t.events, "removed from world")
+}
+
+func (t *buffTrait) Dispose() {
+	t.events = append(t.events, "disposed")
+}
+
+// observerTrait records the traits added to and removed from its actor.
+type observerTrait struct {
+	testTrait
+	added, removed []munfall.Trait
+}
+
+func (t *observerTrait) NotifyTraitAdded(trait munfall.Trait) {
+	t.added = append(t.added, trait)
+}
+
+func (t *observerTrait) NotifyTraitRemoved(trait munfall.Trait) {
+	t.removed = append(t.removed, trait)
+}
+
+// spaceTrait occupies the cell at the position of its actor.
+type spaceTrait struct {
+	testTrait
+	spaces []munfall.Space
+}
+
+func (t *spaceTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	t.owner = a
+	space := &traits.SpaceCell{LocalOffset: &munfall.WPos{}}
+	space.Initialize(t)
+	t.spaces = []munfall.Space{space}
+}
+
+func (t *spaceTrait) Intersects(other traits.OccupySpace, offset *munfall.WPos) bool {
+	return false
+}
+
+func (t *spaceTrait) Space() []munfall.Space {
+	return t.spaces
+}
+
+func (t *spaceTrait) OutOfBounds(offset *munfall.WPos) bool {
+	return false
+}
+
+func TestAttachFollowsParent(t *testing.T) {
+	ar, w := createTestRegistry()
+	parent := ar.CreateActor("plain", nil, w, false)
+	parent.SetPos(&munfall.WPos{X: 1, Y: 1})
+	w.AddToWorld(parent)
+	child := ar.CreateActor("plain", nil, w, false)
+	child.SetPos(&munfall.WPos{})
+	w.AddToWorld(child)
+
+	offset := &munfall.WPos{X: 0.5, Y: -0.5}
+	w.Attach(parent, child, offset)
+	if *child.Pos() != *parent.Pos().Add(offset) {
+		t.Fatalf("child at %v wasn't moved to its parent at %v", child.Pos(), parent.Pos())
+	}
+
+	if child.Parent() != parent || len(parent.Children()) != 1 || parent.Children()[0] != child {
+		t.Fatal("attachment isn't visible through Parent and Children")
+	}
+
+	wm := w.WorldMap()
+	start := *parent.Pos()
+	wm.Move(parent, wm.GetPath(parent, parent.Pos(), &munfall.WPos{X: 3, Y: 2}), 1)
+	if *parent.Pos() == start {
+		t.Fatal("parent didn't move")
+	}
+
+	if *child.Pos() != *parent.Pos().Add(offset) {
+		t.Errorf("child at %v didn't follow its parent to %v", child.Pos(), parent.Pos())
+	}
+
+	w.Detach(child)
+	detached := *child.Pos()
+	if child.Parent() != nil || len(parent.Children()) != 0 || child.AttachOffset() != nil {
+		t.Fatal("child is still attached after Detach")
+	}
+
+	wm.Move(parent, wm.GetPath(parent, parent.Pos(), &munfall.WPos{X: 0, Y: 0}), 1)
+	if *child.Pos() != detached {
+		t.Errorf("detached child moved from %v to %v", detached, *child.Pos())
+	}
+}
+
+func TestAttachMovesChildBetweenParents(t *testing.T) {
+	ar, w := createTestRegistry()
+	first := ar.CreateActor("plain", nil, w, true)
+	second := ar.CreateActor("plain", nil, w, true)
+	child := ar.CreateActor("plain", nil, w, true)
+
+	w.Attach(first, child, nil)
+	w.Attach(second, child, nil)
+	if len(first.Children()) != 0 || len(second.Children()) != 1 || child.Parent() != second {
+		t.Fatal("child wasn't moved to its new parent")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("attaching a parent to its own child didn't panic")
+		}
+	}()
+
+	w.Attach(child, second, nil)
+}
+
+func TestKillRemovesChildren(t *testing.T) {
+	ar, w := createTestRegistry()
+	parent := ar.CreateActor("plain", nil, w, false)
+	child := ar.CreateActor("plain", nil, w, false)
+	grandchild := ar.CreateActor("plain", nil, w, false)
+	w.Attach(parent, child, nil)
+	w.Attach(child, grandchild, nil)
+
+	// Attached actors enter the world with their parent.
+	w.AddToWorld(parent)
+	if !child.IsInWorld() || !grandchild.IsInWorld() {
+		t.Fatal("attached actors weren't added with their parent")
+	}
+
+	parent.Kill()
+	for _, a := range []munfall.Actor{child, grandchild} {
+		if !a.IsDead() || a.IsInWorld() || w.GetActor(a.ActorID()) != nil {
+			t.Errorf("actor %d survived its parent", a.ActorID())
+		}
+	}
+}
+
+func TestWorldProfilesTickers(t *testing.T) {
+	ar, w := createTestRegistry()
+	ar.RegisterTrait("Ticking", (*tickingTrait)(nil))
+	ar.RegisterTrait("Recording", (*recordingTrait)(nil))
+	ticking := CreateActorDefinition("ticking")
+	ticking.AddTrait(CreateTraitDefinition("Ticking"))
+	ticking.AddTrait(CreateTraitDefinition("Recording"))
+	ar.RegisterActor(ticking)
+	for i := 0; i < 3; i++ {
+		ar.CreateActor("ticking", nil, w, true)
+	}
+	ar.CreateActor("plain", nil, w, true)
+
+	w.Tick(1)
+	if len(w.Profiler().Entries()) != 0 {
+		t.Fatal("disabled profiler collected counters")
+	}
+
+	w.Profiler().Enable()
+	w.Tick(1)
+	w.Tick(1)
+	w.IssueGlobalOrder(&munfall.Order{Order: "stop"})
+
+	calls := make(map[string]uint64)
+	for _, entry := range w.Profiler().Entries() {
+		calls[entry.Section+" "+entry.Trait] = entry.Calls
+	}
+
+	if len(calls) != 2 || calls["Tick *logic.tickingTrait"] != 6 || calls["ResolveOrder *logic.recordingTrait"] != 3 {
+		t.Errorf("profiler recorded %v", calls)
+	}
+}
+
+func TestAddAndRemoveTraitNotifications(t *testing.T) {
+	ar, w := createTestRegistry()
+	ar.RegisterTrait("Observer", (*observerTrait)(nil))
+	observed := CreateActorDefinition("observed")
+	observed.AddTrait(CreateTraitDefinition("Observer"))
+	ar.RegisterActor(observed)
+	a := ar.CreateActor("observed", nil, w, true)
+	observer := w.GetTrait(a, (*observerTrait)(nil)).(*observerTrait)
+
+	buff := w.AddTrait(a, (*buffTrait)(nil), map[string]interface{}{"Value": 3}).(*buffTrait)
+	if buff.value != 3 || buff.Owner() != a || w.GetTrait(a, (*buffTrait)(nil)) != buff {
+		t.Fatal("added trait wasn't initialized and stored")
+	}
+
+	if !reflect.DeepEqual(buff.events, []string{"added to world"}) {
+		t.Errorf("added trait got %v", buff.events)
+	}
+
+	if len(observer.added) != 1 || observer.added[0] != buff {
+		t.Errorf("observer saw %v added", observer.added)
+	}
+
+	w.RemoveTrait(a, buff)
+	if !reflect.DeepEqual(buff.events, []string{"added to world", "removed from world", "disposed"}) {
+		t.Errorf("removed trait got %v", buff.events)
+	}
+
+	if len(observer.removed) != 1 || observer.removed[0] != buff {
+		t.Errorf("observer saw %v removed", observer.removed)
+	}
+
+	if len(w.GetTraitsImplementing(a, (*traits.TraitDisposer)(nil))) != 0 {
+		t.Error("removed trait is still on the actor")
+	}
+
+	// Removing a trait the actor doesn't have does nothing.
+	w.RemoveTrait(a, buff)
+	if len(buff.events) != 3 || len(observer.removed) != 1 {
+		t.Error("removing the trait twice notified again")
+	}
+}
+
+func TestAddTraitOutsideWorld(t *testing.T) {
+	ar, w := createTestRegistry()
+	a := ar.CreateActor("plain", nil, w, false)
+	buff := w.AddTrait(a, (*buffTrait)(nil), nil).(*buffTrait)
+	if len(buff.events) != 0 {
+		t.Fatalf("trait on an actor outside the world got %v", buff.events)
+	}
+
+	w.AddToWorld(a)
+	if !reflect.DeepEqual(buff.events, []string{"added to world"}) {
+		t.Errorf("trait got %v when its actor was added to the world", buff.events)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("adding a trait that isn't a pointer didn't panic")
+		}
+	}()
+
+	w.AddTrait(a, buffTrait{}, nil)
+}
+
+func TestAddTraitRegistersSpace(t *testing.T) {
+	ar, w := createTestRegistry()
+	a := ar.CreateActor("plain", nil, w, false)
+	a.SetPos(&munfall.WPos{X: 1.5, Y: 2.5})
+	w.AddToWorld(a)
+	cell := []*munfall.MPos{{X: 1, Y: 2}}
+	if len(w.WorldMap().ActorsInCells(cell, nil)) != 0 {
+		t.Fatal("actor without space occupies a cell")
+	}
+
+	space := w.AddTrait(a, (*spaceTrait)(nil), nil)
+	if got := w.WorldMap().ActorsInCells(cell, nil); len(got) != 1 || got[0] != a {
+		t.Fatalf("actor with an added space isn't found in its cell, got %v", got)
+	}
+
+	w.RemoveTrait(a, space)
+	if len(w.WorldMap().ActorsInCells(cell, nil)) != 0 {
+		t.Error("actor still occupies its cell after the space was removed")
+	}
+}
+
+// tagIDs returns the IDs of the actors the world finds with the tag.
+func tagIDs(w munfall.World, tag string) []uint {
+	var out []uint
+	for _, a := range w.ActorsWithTag(tag) {
+		out = append(out, a.ActorID())
+	}
+
+	return out
+}
+
+func TestTagIndex(t *testing.T) {
+	ar, w := createTestRegistry()
+	soldier := CreateActorDefinition("soldier")
+	soldier.AddTags("infantry", "selectable")
+	soldier.AddTrait(CreateTraitDefinition("Test"))
+	ar.RegisterActor(soldier)
+
+	a := ar.CreateActor("soldier", nil, w, true)
+	b := ar.CreateActor("soldier", nil, w, true)
+	outside := ar.CreateActor("soldier", nil, w, false)
+	plain := ar.CreateActor("plain", nil, w, true)
+	if !reflect.DeepEqual(a.Tags(), []string{"infantry", "selectable"}) || !a.HasTag("infantry") || plain.HasTag("infantry") {
+		t.Fatalf("tags weren't copied from the definition, got %v", a.Tags())
+	}
+
+	if got := tagIDs(w, "infantry"); !reflect.DeepEqual(got, []uint{a.ActorID(), b.ActorID()}) {
+		t.Fatalf("infantry is %v, actors outside the world must not be found", got)
+	}
+
+	// Traits come and go without touching the tags.
+	w.RemoveTrait(a, w.GetTrait(a, (*testTrait)(nil)))
+	w.AddTrait(b, (*buffTrait)(nil), nil)
+	if got := tagIDs(w, "infantry"); !reflect.DeepEqual(got, []uint{a.ActorID(), b.ActorID()}) {
+		t.Errorf("infantry is %v after changing traits", got)
+	}
+
+	a.Kill()
+	w.AddToWorld(outside)
+	if got := tagIDs(w, "selectable"); !reflect.DeepEqual(got, []uint{b.ActorID(), outside.ActorID()}) {
+		t.Errorf("selectable is %v after a kill and an add", got)
+	}
+
+	w.RemoveFromWorld(b)
+	if got := tagIDs(w, "infantry"); !reflect.DeepEqual(got, []uint{outside.ActorID()}) {
+		t.Errorf("infantry is %v after removing an actor", got)
+	}
+
+	// Reloading the definition updates the tags of live actors.
+	reloaded := CreateActorDefinition("soldier")
+	reloaded.AddTags("veteran")
+	reloaded.AddTrait(CreateTraitDefinition("Test"))
+	ar.ReloadActor(reloaded, w)
+	if len(tagIDs(w, "infantry")) != 0 || !reflect.DeepEqual(tagIDs(w, "veteran"), []uint{outside.ActorID()}) {
+		t.Errorf("reload left infantry %v and veteran %v", tagIDs(w, "infantry"), tagIDs(w, "veteran"))
+	}
+}
+
+func TestTagFilters(t *testing.T) {
+	ar, w := createTestRegistry()
+	for name, tags := range map[string][]string{"tank": {"vehicle", "armored"}, "jeep": {"vehicle"}} {
+		definition := CreateActorDefinition(name)
+		definition.AddTags(tags...)
+		definition.AddTrait(CreateTraitDefinition("Test"))
+		ar.RegisterActor(definition)
+	}
+
+	tank := ar.CreateActor("tank", nil, w, true)
+	jeep := ar.CreateActor("jeep", nil, w, true)
+	plain := ar.CreateActor("plain", nil, w, true)
+	for _, a := range []munfall.Actor{tank, jeep, plain} {
+		w.AddTrait(a, (*spaceTrait)(nil), nil)
+	}
+
+	everywhere := &munfall.WPos{X: 4, Y: 4}
+	wm := w.WorldMap()
+	if got := wm.ActorsInRect(&munfall.WPos{}, everywhere, munfall.HasTags("vehicle", "armored")); len(got) != 1 || got[0] != tank {
+		t.Errorf("HasTags found %v", got)
+	}
+
+	if got := wm.ActorsInRect(&munfall.WPos{}, everywhere, munfall.HasAnyTag("armored", "vehicle")); len(got) != 2 {
+		t.Errorf("HasAnyTag found %d actors, want 2", len(got))
+	}
+}
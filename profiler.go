@@ -0,0 +1,194 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package munfall profiler.go Defines a profiler that collects performance
+// counters per trait type.
+package munfall
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+const allocsMetric = "/gc/heap/allocs:objects"
+
+// Profiler collects timings, call counts and allocation counts per trait type
+// and section, a section names the kind of call such as "Tick". It is disabled
+// by default and costs next to nothing until it is enabled. Allocation counts
+// are process wide, so allocations made by other goroutines during a call are
+// included.
+type Profiler struct {
+	enabled int32
+	mutex   sync.Mutex
+	entries map[profileKey]*ProfileEntry
+	since   time.Time
+	sample  []metrics.Sample
+}
+
+type profileKey struct {
+	section   string
+	traitType reflect.Type
+}
+
+// ProfileEntry holds the counters of a single trait type in a section.
+type ProfileEntry struct {
+	Section string
+	Trait   string
+	Calls   uint64
+	Time    time.Duration
+	Allocs  uint64
+}
+
+// ProfileSample is returned by Begin and passed to End to measure a call.
+type ProfileSample struct {
+	start  time.Time
+	allocs uint64
+}
+
+// CreateProfiler creates a disabled profiler.
+func CreateProfiler() *Profiler {
+	return &Profiler{
+		entries: make(map[profileKey]*ProfileEntry),
+		since:   time.Now(),
+		sample:  []metrics.Sample{{Name: allocsMetric}},
+	}
+}
+
+// Enable starts collecting counters.
+func (p *Profiler) Enable() {
+	atomic.StoreInt32(&p.enabled, 1)
+}
+
+// Disable stops collecting counters, the collected counters are kept.
+func (p *Profiler) Disable() {
+	atomic.StoreInt32(&p.enabled, 0)
+}
+
+// Enabled returns if the profiler is collecting counters.
+func (p *Profiler) Enabled() bool {
+	return atomic.LoadInt32(&p.enabled) == 1
+}
+
+// Begin starts measuring a call, returns an empty sample when disabled.
+func (p *Profiler) Begin() ProfileSample {
+	if !p.Enabled() {
+		return ProfileSample{}
+	}
+
+	return ProfileSample{start: time.Now(), allocs: p.readAllocs()}
+}
+
+// End records the call started by Begin for the type of the given trait.
+func (p *Profiler) End(section string, trait Trait, sample ProfileSample) {
+	if sample.start.IsZero() {
+		return
+	}
+
+	elapsed := time.Since(sample.start)
+	allocs := p.readAllocs() - sample.allocs
+	traitType := reflect.TypeOf(trait)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := profileKey{section: section, traitType: traitType}
+	entry, exists := p.entries[key]
+	if !exists {
+		entry = &ProfileEntry{Section: section, Trait: fmt.Sprint(traitType)}
+		p.entries[key] = entry
+	}
+
+	entry.Calls++
+	entry.Time += elapsed
+	entry.Allocs += allocs
+}
+
+// Entries returns a copy of the collected counters, most expensive first.
+func (p *Profiler) Entries() []ProfileEntry {
+	p.mutex.Lock()
+	out := make([]ProfileEntry, 0, len(p.entries))
+	for _, entry := range p.entries {
+		out = append(out, *entry)
+	}
+	p.mutex.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Time != out[j].Time {
+			return out[i].Time > out[j].Time
+		}
+
+		return out[i].Section+out[i].Trait < out[j].Section+out[j].Trait
+	})
+	return out
+}
+
+// Reset clears all collected counters.
+func (p *Profiler) Reset() {
+	p.mutex.Lock()
+	p.entries = make(map[profileKey]*ProfileEntry)
+	p.since = time.Now()
+	p.mutex.Unlock()
+}
+
+// Report writes a table of the collected counters to w.
+func (p *Profiler) Report(w io.Writer) {
+	p.mutex.Lock()
+	since := p.since
+	p.mutex.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Profile over the last %v\n", time.Since(since).Round(time.Millisecond))
+	fmt.Fprintln(tw, "Section\tTrait\tCalls\tTotal\tPer call\tAllocs\tAllocs per call\t")
+	for _, entry := range p.Entries() {
+		calls := entry.Calls
+		if calls == 0 {
+			calls = 1
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%v\t%v\t%d\t%.1f\t\n",
+			entry.Section, entry.Trait, entry.Calls, entry.Time,
+			entry.Time/time.Duration(calls), entry.Allocs, float64(entry.Allocs)/float64(calls))
+	}
+
+	tw.Flush()
+}
+
+// ReportEvery writes a report to w every interval and resets the counters
+// afterwards, call the returned function to stop reporting.
+func (p *Profiler) ReportEvery(interval time.Duration, w io.Writer) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				p.Report(w)
+				p.Reset()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (p *Profiler) readAllocs() uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	metrics.Read(p.sample)
+	if p.sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return p.sample[0].Value.Uint64()
+}
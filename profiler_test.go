@@ -0,0 +1,89 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package munfall
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+type slowTrait struct{}
+
+func (t *slowTrait) Initialize(w World, a Actor, parameters map[string]interface{}) {}
+
+func (t *slowTrait) Owner() Actor {
+	return nil
+}
+
+type allocatingTrait struct {
+	slowTrait
+	kept [][]byte
+}
+
+func TestProfilerKeepsEntriesPerTrait(t *testing.T) {
+	p := CreateProfiler()
+	slow, allocating := &slowTrait{}, &allocatingTrait{}
+	p.End("Tick", slow, p.Begin())
+	if len(p.Entries()) != 0 {
+		t.Fatal("disabled profiler collected counters")
+	}
+
+	p.Enable()
+	for i := 0; i < 3; i++ {
+		sample := p.Begin()
+		time.Sleep(2 * time.Millisecond)
+		p.End("Tick", slow, sample)
+	}
+
+	for i := 0; i < 5; i++ {
+		sample := p.Begin()
+		// Small allocations are only counted once their span is used up,
+		// large ones right away.
+		allocating.kept = append(allocating.kept, make([]byte, 64<<10))
+		p.End("Tick", allocating, sample)
+	}
+
+	p.End("ResolveOrder", slow, p.Begin())
+
+	entries := p.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+
+	// Entries are sorted by time, the sleeping ticks come first.
+	if e := entries[0]; e.Section != "Tick" || e.Trait != "*munfall.slowTrait" || e.Calls != 3 || e.Time < 6*time.Millisecond {
+		t.Errorf("slow trait ticks recorded as %+v", e)
+	}
+
+	for _, e := range entries[1:] {
+		switch e.Section + " " + e.Trait {
+		case "Tick *munfall.allocatingTrait":
+			if e.Calls != 5 || e.Allocs < 5 {
+				t.Errorf("allocating trait ticks recorded as %+v", e)
+			}
+		case "ResolveOrder *munfall.slowTrait":
+			if e.Calls != 1 {
+				t.Errorf("slow trait orders recorded as %+v", e)
+			}
+		default:
+			t.Errorf("unexpected entry %+v", e)
+		}
+	}
+
+	var report bytes.Buffer
+	p.Report(&report)
+	if !strings.Contains(report.String(), "*munfall.allocatingTrait") {
+		t.Errorf("report doesn't list the traits:\n%s", report.String())
+	}
+
+	p.Reset()
+	p.Disable()
+	p.End("Tick", slow, p.Begin())
+	if len(p.Entries()) != 0 {
+		t.Error("counters survived Reset or were collected after Disable")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package munfall random.go Defines a deterministic random number generator
+// that gives the same results on every run and platform.
+package munfall
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// Random is a seedable, deterministic random number generator (splitmix64).
+// Independent streams can be derived from it by name, so systems that draw
+// numbers don't disturb each other's sequences.
+type Random struct {
+	seed    uint64
+	state   uint64
+	streams map[string]*Random
+}
+
+const randomFormatVersion = 1
+
+// CreateRandom creates a Random seeded with the given seed.
+func CreateRandom(seed uint64) *Random {
+	return &Random{seed: seed, state: seed}
+}
+
+// Seed returns the seed this Random was last seeded with.
+func (r *Random) Seed() uint64 {
+	return r.seed
+}
+
+// Reseed restarts the sequence from the given seed, streams derived from
+// this Random are reseeded in place.
+func (r *Random) Reseed(seed uint64) {
+	r.seed, r.state = seed, seed
+	for name, stream := range r.streams {
+		stream.Reseed(deriveSeed(seed, name))
+	}
+}
+
+// Stream returns the stream with the given name, its seed is derived from the
+// seed of this Random and the name so it doesn't depend on when it is first used.
+func (r *Random) Stream(name string) *Random {
+	stream, exists := r.streams[name]
+	if !exists {
+		if r.streams == nil {
+			r.streams = make(map[string]*Random)
+		}
+
+		stream = CreateRandom(deriveSeed(r.seed, name))
+		r.streams[name] = stream
+	}
+
+	return stream
+}
+
+// Uint64 returns the next number in the sequence.
+func (r *Random) Uint64() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	return mix(r.state)
+}
+
+// Intn returns a number in [0, n), panics if n <= 0.
+func (r *Random) Intn(n int) int {
+	if n <= 0 {
+		Logger.Panic("Intn called with", n, "it must be larger than 0.")
+	}
+
+	bound := uint64(n)
+	limit := math.MaxUint64 - math.MaxUint64%bound
+	for {
+		if v := r.Uint64(); v < limit {
+			return int(v % bound)
+		}
+	}
+}
+
+// Float64 returns a number in [0, 1).
+func (r *Random) Float64() float64 {
+	return float64(r.Uint64()>>11) / (1 << 53)
+}
+
+// Float32 returns a number in [0, 1).
+func (r *Random) Float32() float32 {
+	return float32(r.Uint64()>>40) / (1 << 24)
+}
+
+// Range returns a number in [low, high).
+func (r *Random) Range(low, high float32) float32 {
+	return low + r.Float32()*(high-low)
+}
+
+// MarshalBinary encodes the seed and position of this Random and all its
+// streams, used to store it in save games.
+func (r *Random) MarshalBinary() ([]byte, error) {
+	data := []byte{randomFormatVersion}
+	return r.appendBinary(data), nil
+}
+
+// UnmarshalBinary restores the state written by MarshalBinary, streams that
+// already exist are restored in place.
+func (r *Random) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != randomFormatVersion {
+		return errors.New("munfall: unsupported random state version")
+	}
+
+	rest, err := r.readBinary(data[1:])
+	if err == nil && len(rest) != 0 {
+		err = errors.New("munfall: trailing data after random state")
+	}
+
+	return err
+}
+
+func (r *Random) appendBinary(data []byte) []byte {
+	names := make([]string, 0, len(r.streams))
+	for name := range r.streams {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	data = binary.BigEndian.AppendUint64(data, r.seed)
+	data = binary.BigEndian.AppendUint64(data, r.state)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(names)))
+	for _, name := range names {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(name)))
+		data = append(data, name...)
+		data = r.streams[name].appendBinary(data)
+	}
+
+	return data
+}
+
+func (r *Random) readBinary(data []byte) ([]byte, error) {
+	errShort := errors.New("munfall: random state is truncated")
+	if len(data) < 20 {
+		return nil, errShort
+	}
+
+	r.seed = binary.BigEndian.Uint64(data)
+	r.state = binary.BigEndian.Uint64(data[8:])
+	count := binary.BigEndian.Uint32(data[16:])
+	data = data[20:]
+	for i := uint32(0); i < count; i++ {
+		if len(data) < 4 {
+			return nil, errShort
+		}
+
+		length := binary.BigEndian.Uint32(data)
+		if uint32(len(data)-4) < length {
+			return nil, errShort
+		}
+
+		name := string(data[4 : 4+length])
+		var err error
+		if data, err = r.Stream(name).readBinary(data[4+length:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func deriveSeed(seed uint64, name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return mix(seed ^ h.Sum64())
+}
+
+func mix(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
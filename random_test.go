@@ -0,0 +1,138 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package munfall
+
+import (
+	"testing"
+)
+
+// draw returns the next n numbers of the Random.
+func draw(r *Random, n int) []uint64 {
+	out := make([]uint64, n)
+	for i := range out {
+		out[i] = r.Uint64()
+	}
+
+	return out
+}
+
+func equalDraws(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRandomIsDeterministic(t *testing.T) {
+	if !equalDraws(draw(CreateRandom(42), 16), draw(CreateRandom(42), 16)) {
+		t.Error("the same seed gave different sequences")
+	}
+
+	if equalDraws(draw(CreateRandom(42), 16), draw(CreateRandom(43), 16)) {
+		t.Error("different seeds gave the same sequence")
+	}
+
+	r := CreateRandom(7)
+	for i := 0; i < 1000; i++ {
+		if n := r.Intn(10); n < 0 || n >= 10 {
+			t.Fatalf("Intn(10) returned %d", n)
+		}
+
+		if f := r.Float64(); f < 0 || f >= 1 {
+			t.Fatalf("Float64 returned %v", f)
+		}
+
+		if f := r.Range(-2, 3); f < -2 || f >= 3 {
+			t.Fatalf("Range(-2, 3) returned %v", f)
+		}
+	}
+}
+
+func TestRandomStreams(t *testing.T) {
+	a, b := CreateRandom(5), CreateRandom(5)
+	// Drawing from the parent or other streams first doesn't change a stream.
+	draw(a, 10)
+	draw(a.Stream("ai"), 3)
+	if !equalDraws(draw(a.Stream("combat"), 8), draw(b.Stream("combat"), 8)) {
+		t.Error("stream depends on what was drawn before it was created")
+	}
+
+	if equalDraws(draw(CreateRandom(5).Stream("ai"), 8), draw(CreateRandom(5).Stream("combat"), 8)) {
+		t.Error("streams with different names gave the same sequence")
+	}
+
+	r := CreateRandom(5)
+	combat := r.Stream("combat")
+	first := draw(combat, 8)
+	r.Reseed(5)
+	if !equalDraws(draw(combat, 8), first) {
+		t.Error("Reseed didn't restart the stream in place")
+	}
+}
+
+func TestRandomMarshalBinary(t *testing.T) {
+	r := CreateRandom(1)
+	r.Reseed(99)
+	draw(r, 5)
+	draw(r.Stream("combat"), 3)
+	draw(r.Stream("ai").Stream("pathing"), 2)
+
+	data, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top, combat, pathing := draw(r, 8), draw(r.Stream("combat"), 8), draw(r.Stream("ai").Stream("pathing"), 8)
+
+	restored := CreateRandom(0)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Seed() != 99 {
+		t.Errorf("restored seed is %d, want 99", restored.Seed())
+	}
+
+	if !equalDraws(draw(restored, 8), top) {
+		t.Error("restored Random continues with a different sequence")
+	}
+
+	if !equalDraws(draw(restored.Stream("combat"), 8), combat) || !equalDraws(draw(restored.Stream("ai").Stream("pathing"), 8), pathing) {
+		t.Error("restored streams continue with a different sequence")
+	}
+
+	// Restoring into a Random with existing streams rewinds them in place.
+	existing := r.Stream("combat")
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalDraws(draw(existing, 8), combat) {
+		t.Error("existing stream wasn't restored in place")
+	}
+}
+
+func TestRandomUnmarshalBinaryErrors(t *testing.T) {
+	r := CreateRandom(3)
+	r.Stream("combat")
+	data, _ := r.MarshalBinary()
+	for name, bad := range map[string][]byte{
+		"empty":     nil,
+		"version":   append([]byte{randomFormatVersion + 1}, data[1:]...),
+		"truncated": data[:len(data)-3],
+		"trailing":  append(append([]byte(nil), data...), 0),
+	} {
+		if err := CreateRandom(0).UnmarshalBinary(bad); err == nil {
+			t.Errorf("%s state was accepted", name)
+		}
+	}
+}
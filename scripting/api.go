@@ -0,0 +1,239 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package scripting api.go Defines the sandboxed API scripts can use
+// to interact with the world.
+package scripting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bluemun/munfall"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const actorTypeName = "actor"
+
+// unsafeGlobals are removed from the base library so scripts can't reach
+// the file system or load code from outside the sandbox.
+var unsafeGlobals = []string{
+	"dofile", "loadfile", "load", "loadstring", "require", "module",
+	"getfenv", "setfenv", "collectgarbage", "newproxy",
+}
+
+// createSandbox creates a Lua state that only has access to the base, table,
+// string and math libraries and to the world API of the given trait.
+func createSandbox(st *ScriptTrait) *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range unsafeGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	// Every actor draws from its own world stream so scripts stay deterministic
+	// whatever order they run in.
+	random := st.world.Random().Stream("scripting").Stream(fmt.Sprint(st.owner.ActorID()))
+	mathlib := L.GetGlobal(lua.MathLibName)
+	L.SetField(mathlib, "randomseed", lua.LNil)
+	L.SetField(mathlib, "random", L.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			L.Push(lua.LNumber(random.Float64()))
+			return 1
+		}
+
+		low, high := 1, L.CheckInt(1)
+		if L.GetTop() > 1 {
+			low, high = high, L.CheckInt(2)
+		}
+
+		if high < low {
+			L.ArgError(L.GetTop(), "interval is empty")
+		}
+
+		L.Push(lua.LNumber(low + random.Intn(high-low+1)))
+		return 1
+	}))
+
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		args := make([]string, L.GetTop())
+		for i := range args {
+			args[i] = L.Get(i + 1).String()
+		}
+
+		munfall.Logger.Info(st.name, ":", strings.Join(args, " "))
+		return 0
+	}))
+
+	mt := L.NewTypeMetatable(actorTypeName)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"id": func(L *lua.LState) int {
+			L.Push(lua.LNumber(checkActor(L, 1).ActorID()))
+			return 1
+		},
+		"pos": func(L *lua.LState) int {
+			pos := checkActor(L, 1).Pos()
+			L.Push(lua.LNumber(pos.X))
+			L.Push(lua.LNumber(pos.Y))
+			L.Push(lua.LNumber(pos.Z))
+			return 3
+		},
+		"is_dead": func(L *lua.LState) int {
+			L.Push(lua.LBool(checkActor(L, 1).IsDead()))
+			return 1
+		},
+		"has_tag": func(L *lua.LState) int {
+			L.Push(lua.LBool(checkActor(L, 1).HasTag(L.CheckString(2))))
+			return 1
+		},
+		"tags": func(L *lua.LState) int {
+			t := L.NewTable()
+			for _, tag := range checkActor(L, 1).Tags() {
+				t.Append(lua.LString(tag))
+			}
+			L.Push(t)
+			return 1
+		},
+		"is_in_world": func(L *lua.LState) int {
+			L.Push(lua.LBool(checkActor(L, 1).IsInWorld()))
+			return 1
+		},
+		"parent": func(L *lua.LState) int {
+			L.Push(st.actorValue(checkActor(L, 1).Parent()))
+			return 1
+		},
+		"kill": func(L *lua.LState) int {
+			a := checkActor(L, 1)
+			st.world.AddFrameEndTask(func() {
+				if !a.IsDead() {
+					a.Kill()
+				}
+			})
+			return 0
+		},
+		"issue_order": func(L *lua.LState) int {
+			a := checkActor(L, 1)
+			order := &munfall.Order{Order: L.CheckString(2), Value: fromLua(L.Get(3))}
+			st.world.AddFrameEndTask(func() {
+				if !a.IsDead() {
+					st.world.IssueOrder(a, order)
+				}
+			})
+			return 0
+		},
+	}))
+	L.SetField(mt, "__tostring", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(fmt.Sprint("actor ", checkActor(L, 1).ActorID())))
+		return 1
+	}))
+
+	L.SetGlobal("world", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"actors_in_radius": func(L *lua.LState) int {
+			center := &munfall.WPos{X: float32(L.CheckNumber(1)), Y: float32(L.CheckNumber(2))}
+			L.Push(st.actorList(st.world.WorldMap().ActorsInRadius(center, float32(L.CheckNumber(3)), optTag(L, 4))))
+			return 1
+		},
+		"actors_in_rect": func(L *lua.LState) int {
+			topLeft := &munfall.WPos{X: float32(L.CheckNumber(1)), Y: float32(L.CheckNumber(2))}
+			bottomRight := &munfall.WPos{X: float32(L.CheckNumber(3)), Y: float32(L.CheckNumber(4))}
+			L.Push(st.actorList(st.world.WorldMap().ActorsInRect(topLeft, bottomRight, optTag(L, 5))))
+			return 1
+		},
+		"nearest_actor": func(L *lua.LState) int {
+			pos := &munfall.WPos{X: float32(L.CheckNumber(1)), Y: float32(L.CheckNumber(2))}
+			self, tagged := st.owner, optTag(L, 4)
+			L.Push(st.actorValue(st.world.WorldMap().NearestActor(pos, float32(L.CheckNumber(3)), func(a munfall.Actor) bool {
+				return a != self && (tagged == nil || tagged(a))
+			})))
+			return 1
+		},
+		"actors_with_tag": func(L *lua.LState) int {
+			L.Push(st.actorList(st.world.ActorsWithTag(L.CheckString(1))))
+			return 1
+		},
+		"issue_global_order": func(L *lua.LState) int {
+			order := &munfall.Order{Order: L.CheckString(1), Value: fromLua(L.Get(2))}
+			st.world.AddFrameEndTask(func() { st.world.IssueGlobalOrder(order) })
+			return 0
+		},
+		"spawn": func(L *lua.LState) int {
+			if st.registry == nil {
+				L.RaiseError("spawning requires the trait to be created by an ActorRegistry")
+			}
+
+			name := L.CheckString(1)
+			pos := &munfall.WPos{X: float32(L.CheckNumber(2)), Y: float32(L.CheckNumber(3))}
+			var params map[string]interface{}
+			if table := L.OptTable(4, nil); table != nil {
+				params, _ = fromLua(table).(map[string]interface{})
+			}
+
+			a, err := st.registry.CreateActorE(name, params, st.world, false)
+			if err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+
+			a.SetPos(pos)
+			st.world.AddToWorld(a)
+			L.Push(st.actorValue(a))
+			return 1
+		},
+	}))
+
+	return L
+}
+
+// actorValue wraps the actor in a userdata value, nil actors become nil.
+func (st *ScriptTrait) actorValue(a munfall.Actor) lua.LValue {
+	if munfall.IsNil(a) {
+		return lua.LNil
+	}
+
+	ud := st.state.NewUserData()
+	ud.Value = a
+	st.state.SetMetatable(ud, st.state.GetTypeMetatable(actorTypeName))
+	return ud
+}
+
+func (st *ScriptTrait) actorList(actors []munfall.Actor) *lua.LTable {
+	t := st.state.NewTable()
+	for _, a := range actors {
+		t.Append(st.actorValue(a))
+	}
+
+	return t
+}
+
+// optTag returns a filter for the optional tag argument, nil if it wasn't given.
+func optTag(L *lua.LState, n int) munfall.ActorFilter {
+	if tag := L.OptString(n, ""); tag != "" {
+		return munfall.HasTags(tag)
+	}
+
+	return nil
+}
+
+func checkActor(L *lua.LState, n int) munfall.Actor {
+	a, ok := L.CheckUserData(n).Value.(munfall.Actor)
+	if !ok {
+		L.ArgError(n, "actor expected")
+	}
+
+	return a
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package scripting convert.go Defines how values are passed between Go and Lua.
+package scripting
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bluemun/munfall"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// toLua converts a Go value to a Lua value, actors become actor userdata and
+// positions become tables with x, y and z fields. Values without a Lua
+// counterpart are passed as their string representation.
+func toLua(st *ScriptTrait, value interface{}) lua.LValue {
+	L := st.state
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case lua.LValue:
+		return v
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case int:
+		return lua.LNumber(v)
+	case int32:
+		return lua.LNumber(v)
+	case int64:
+		return lua.LNumber(v)
+	case uint:
+		return lua.LNumber(v)
+	case uint32:
+		return lua.LNumber(v)
+	case uint64:
+		return lua.LNumber(v)
+	case float32:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case munfall.Actor:
+		return st.actorValue(v)
+	case *munfall.WPos:
+		t := L.NewTable()
+		t.RawSetString("x", lua.LNumber(v.X))
+		t.RawSetString("y", lua.LNumber(v.Y))
+		t.RawSetString("z", lua.LNumber(v.Z))
+		return t
+	case []interface{}:
+		t := L.NewTable()
+		for _, item := range v {
+			t.Append(toLua(st, item))
+		}
+		return t
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		t := L.NewTable()
+		for _, key := range keys {
+			t.RawSetString(key, toLua(st, v[key]))
+		}
+		return t
+	default:
+		return lua.LString(fmt.Sprint(v))
+	}
+}
+
+// fromLua converts a Lua value to a Go value, numbers become float64, tables
+// become []interface{} when they are sequences and map[string]interface{} otherwise.
+func fromLua(value lua.LValue) interface{} {
+	switch v := value.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LString:
+		return string(v)
+	case lua.LNumber:
+		return float64(v)
+	case *lua.LUserData:
+		return v.Value
+	case *lua.LTable:
+		if n := v.Len(); n > 0 {
+			list := make([]interface{}, n)
+			for i := range list {
+				list[i] = fromLua(v.RawGetInt(i + 1))
+			}
+			return list
+		}
+
+		m := make(map[string]interface{})
+		v.ForEach(func(key, item lua.LValue) {
+			m[key.String()] = fromLua(item)
+		})
+		return m
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package scripting scripttrait.go Defines a trait that runs its behaviour
+// from a Lua script instead of compiled Go code.
+package scripting
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/logic"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// ScriptTrait is a trait that runs a Lua script, register it with
+//
+//	ar.RegisterTrait("Script", (*scripting.ScriptTrait)(nil))
+//
+// The script is given by the "Script" parameter as source or the "ScriptFile"
+// parameter as a path. It may define the global functions Initialize(self, params),
+// Tick(self, dt), ResolveOrder(self, order), NotifyAddedToWorld(self),
+// NotifyRemovedFromWorld(self) and NotifyMove(self, old, new), which are called
+// when the matching Go method is called on the trait. Errors raised by hooks
+// are logged and don't stop the game, hooks that run longer than HookTimeout
+// are stopped with an error. Orders issued by scripts are delivered and
+// actors killed by scripts die at the end of the current tick. The script
+// stops running once its actor is killed or the trait is disposed.
+type ScriptTrait struct {
+	owner    munfall.Actor
+	world    munfall.World
+	registry *logic.ActorRegistry
+	state    *lua.LState
+	self     lua.LValue
+	name     string
+	// running counts the hooks that are running, a trait disposed of while
+	// one runs is closed once they have all returned.
+	running  int
+	disposed bool
+}
+
+// HookTimeout is how long a script may run for a single hook, or for its
+// top level code when it is loaded, before it is stopped.
+var HookTimeout = 100 * time.Millisecond
+
+var protoCache = struct {
+	sync.Mutex
+	protos map[string]*lua.FunctionProto
+}{protos: make(map[string]*lua.FunctionProto)}
+
+// SetActorRegistry sets the registry used by the script to spawn actors.
+func (st *ScriptTrait) SetActorRegistry(ar *logic.ActorRegistry) {
+	st.registry = ar
+}
+
+// Initialize compiles and runs the script, then calls its Initialize hook
+// with the remaining parameters.
+func (st *ScriptTrait) Initialize(w munfall.World, a munfall.Actor, parameters map[string]interface{}) {
+	st.owner = a
+	st.world = w
+
+	source, isSource := parameters["Script"].(string)
+	st.name = "Script"
+	if !isSource {
+		file, isFile := parameters["ScriptFile"].(string)
+		if !isFile {
+			munfall.Logger.Panic("ScriptTrait requires a Script or ScriptFile parameter.")
+		}
+
+		bytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			munfall.Logger.Panic("Failed to read script", file, ":", err)
+		}
+
+		source, st.name = string(bytes), file
+	}
+
+	proto, err := compile(source, st.name)
+	if err != nil {
+		munfall.Logger.Panic("Failed to compile script", st.name, ":", err)
+	}
+
+	st.state = createSandbox(st)
+	st.self = st.actorValue(a)
+	err = st.limit(func() error {
+		st.state.Push(st.state.NewFunctionFromProto(proto))
+		return st.state.PCall(0, lua.MultRet, nil)
+	})
+	if err != nil {
+		st.Dispose()
+		munfall.Logger.Panic("Failed to run script", st.name, ":", err)
+	}
+
+	params := make(map[string]interface{}, len(parameters))
+	for key, value := range parameters {
+		if key != "Script" && key != "ScriptFile" {
+			params[key] = value
+		}
+	}
+
+	if err := st.call("Initialize", toLua(st, params)); err != nil {
+		st.Dispose()
+		munfall.Logger.Panic(err)
+	}
+}
+
+// Owner returns the actor this trait belongs to.
+func (st *ScriptTrait) Owner() munfall.Actor {
+	return st.owner
+}
+
+// Tick calls the Tick hook of the script.
+func (st *ScriptTrait) Tick(deltaUnit float32) {
+	st.callLogged("Tick", lua.LNumber(deltaUnit))
+}
+
+// ResolveOrder calls the ResolveOrder hook of the script with a table
+// holding the order, value and whether the order is global.
+func (st *ScriptTrait) ResolveOrder(order *munfall.Order) {
+	if !st.hasHook("ResolveOrder") {
+		return
+	}
+
+	t := st.state.NewTable()
+	t.RawSetString("order", lua.LString(order.Order))
+	t.RawSetString("value", toLua(st, order.Value))
+	t.RawSetString("global", lua.LBool(order.IsGlobal))
+	st.callLogged("ResolveOrder", t)
+}
+
+// NotifyAddedToWorld calls the NotifyAddedToWorld hook of the script.
+func (st *ScriptTrait) NotifyAddedToWorld() {
+	st.callLogged("NotifyAddedToWorld")
+}
+
+// NotifyRemovedFromWorld calls the NotifyRemovedFromWorld hook of the script,
+// the script is disposed of afterwards if its actor was killed.
+func (st *ScriptTrait) NotifyRemovedFromWorld() {
+	st.callLogged("NotifyRemovedFromWorld")
+	if st.owner.IsDead() {
+		st.Dispose()
+	}
+}
+
+// Dispose closes the Lua state of the script, its hooks do nothing afterwards.
+// When a hook is running the state is closed once it returns.
+func (st *ScriptTrait) Dispose() {
+	st.disposed = true
+	if st.state != nil && st.running == 0 {
+		st.state.Close()
+		st.state = nil
+	}
+}
+
+// NotifyMove calls the NotifyMove hook of the script.
+func (st *ScriptTrait) NotifyMove(old, new *munfall.WPos) {
+	if !st.hasHook("NotifyMove") {
+		return
+	}
+
+	st.callLogged("NotifyMove", toLua(st, old), toLua(st, new))
+}
+
+func (st *ScriptTrait) hasHook(name string) bool {
+	return st.state != nil && !st.disposed && st.state.GetGlobal(name).Type() == lua.LTFunction
+}
+
+// call calls the given hook with self and the arguments, does nothing if
+// the script doesn't define the hook or has been disposed of.
+func (st *ScriptTrait) call(name string, args ...lua.LValue) error {
+	if !st.hasHook(name) {
+		return nil
+	}
+
+	fn := st.state.GetGlobal(name)
+	return st.limit(func() error {
+		return st.state.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, append([]lua.LValue{st.self}, args...)...)
+	})
+}
+
+// limit runs f with the Lua state stopping after HookTimeout, hooks called
+// while another hook of the script runs keep to its deadline as well.
+func (st *ScriptTrait) limit(f func() error) error {
+	L := st.state
+	parent := L.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, cancel := context.WithTimeout(parent, HookTimeout)
+	L.SetContext(ctx)
+	st.running++
+	defer func() {
+		st.running--
+		cancel()
+		if parent == context.Background() {
+			L.RemoveContext()
+		} else {
+			L.SetContext(parent)
+		}
+
+		if st.running == 0 && st.disposed {
+			st.Dispose()
+		}
+	}()
+
+	return f()
+}
+
+func (st *ScriptTrait) callLogged(name string, args ...lua.LValue) {
+	if err := st.call(name, args...); err != nil {
+		munfall.Logger.Error("Script", st.name, "on actor", st.owner.ActorID(), "failed in", name, ":", err)
+	}
+}
+
+// compile compiles the source, scripts shared by many actors are only compiled once.
+func compile(source, name string) (*lua.FunctionProto, error) {
+	protoCache.Lock()
+	defer protoCache.Unlock()
+	if proto, exists := protoCache.protos[source]; exists {
+		return proto, nil
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(source), name)
+	if err != nil {
+		return nil, err
+	}
+
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, err
+	}
+
+	protoCache.protos[source] = proto
+	return proto, nil
+}
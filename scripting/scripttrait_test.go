@@ -0,0 +1,190 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package scripting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bluemun/munfall"
+	"github.com/bluemun/munfall/gridworldmap"
+	"github.com/bluemun/munfall/logic"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func createScripted(script string) (*logic.ActorRegistry, munfall.World) {
+	ar := logic.CreateActorRegistry()
+	ar.RegisterTrait("Script", (*ScriptTrait)(nil))
+	definition := logic.CreateActorDefinition("scripted")
+	definition.AddTrait(logic.CreateTraitDefinition("Script").AddParameter("Script", script))
+	ar.RegisterActor(definition)
+	return ar, logic.CreateWorld(gridworldmap.CreateGridWorldMap(8, 8, 1, 1))
+}
+
+func scriptOf(w munfall.World, a munfall.Actor) *ScriptTrait {
+	return w.GetTrait(a, (*ScriptTrait)(nil)).(*ScriptTrait)
+}
+
+func TestHookTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { HookTimeout = timeout }(HookTimeout)
+	HookTimeout = 10 * time.Millisecond
+
+	ar, w := createScripted(`
+ticks = 0
+function Tick(self, dt)
+	ticks = ticks + 1
+	if ticks == 1 then
+		while true do end
+	end
+end`)
+	a := ar.CreateActor("scripted", nil, w, true)
+
+	done := make(chan bool)
+	go func() {
+		w.Tick(1)
+		w.Tick(1)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("endless Tick hook was not stopped")
+	}
+
+	if ticks := scriptOf(w, a).state.GetGlobal("ticks"); ticks != lua.LNumber(2) {
+		t.Errorf("script stopped working after the timeout, ticks is %v", ticks)
+	}
+}
+
+func TestScriptDisposal(t *testing.T) {
+	ar, w := createScripted(`function Tick(self, dt) end`)
+	killed := ar.CreateActor("scripted", nil, w, true)
+	disposed := ar.CreateActor("scripted", nil, w, true)
+	removed := ar.CreateActor("scripted", nil, w, true)
+
+	scripts := []*ScriptTrait{scriptOf(w, killed), scriptOf(w, disposed), scriptOf(w, removed)}
+	killed.Kill()
+	ar.DisposeActor(disposed, w)
+	w.RemoveTrait(removed, scripts[2])
+	w.Tick(1)
+
+	for i, script := range scripts {
+		if script.state != nil {
+			t.Errorf("script %d still holds its Lua state", i)
+		}
+	}
+
+	reloaded := ar.CreateActor("scripted", nil, w, true)
+	old := scriptOf(w, reloaded)
+	definition := logic.CreateActorDefinition("scripted")
+	definition.AddTrait(logic.CreateTraitDefinition("Script").AddParameter("Script", `function Tick(self, dt) end`))
+	ar.ReloadActor(definition, w)
+	if old.state != nil {
+		t.Error("reloaded script still holds its Lua state")
+	}
+}
+
+func TestRandomPerActor(t *testing.T) {
+	draw := func(order []int) map[int]float64 {
+		ar, w := createScripted(`function Draw(self) value = math.random() end`)
+		actors := []munfall.Actor{
+			ar.CreateActor("scripted", nil, w, true),
+			ar.CreateActor("scripted", nil, w, true),
+		}
+
+		values := make(map[int]float64)
+		for _, i := range order {
+			script := scriptOf(w, actors[i])
+			if err := script.call("Draw"); err != nil {
+				t.Fatal(err)
+			}
+
+			values[i] = float64(script.state.GetGlobal("value").(lua.LNumber))
+		}
+
+		return values
+	}
+
+	forward, backward := draw([]int{0, 1}), draw([]int{1, 0})
+	for i := range forward {
+		if forward[i] != backward[i] {
+			t.Errorf("actor %d drew %v and %v depending on the order", i, forward[i], backward[i])
+		}
+	}
+}
+
+func TestSelfKillFromTick(t *testing.T) {
+	ar, w := createScripted(`
+function Tick(self, dt)
+	self:kill()
+	dead = self:is_dead()
+end`)
+	a := ar.CreateActor("scripted", nil, w, true)
+	script := scriptOf(w, a)
+	w.Tick(1)
+
+	if !a.IsDead() {
+		t.Fatal("the actor wasn't killed at the end of the tick")
+	}
+
+	if script.state != nil {
+		t.Error("the script of the killed actor still holds its Lua state")
+	}
+
+	w.Tick(1)
+}
+
+func TestDisposeDuringHook(t *testing.T) {
+	ar, w := createScripted(`
+function Tick(self, dt)
+	dispose()
+	after = true
+end`)
+	script := scriptOf(w, ar.CreateActor("scripted", nil, w, true))
+	state := script.state
+	state.SetGlobal("dispose", state.NewFunction(func(L *lua.LState) int {
+		script.Dispose()
+		return 0
+	}))
+
+	w.Tick(1)
+	if state.GetGlobal("after") != lua.LTrue {
+		t.Error("the hook stopped when the trait was disposed")
+	}
+
+	if script.state != nil {
+		t.Error("the Lua state wasn't closed after the hook returned")
+	}
+}
+
+func TestNestedHookKeepsTimeout(t *testing.T) {
+	ar, w := createScripted(`
+function Inner(self) end
+function Tick(self, dt)
+	inner()
+end`)
+	script := scriptOf(w, ar.CreateActor("scripted", nil, w, true))
+	var before, after context.Context
+	script.state.SetGlobal("inner", script.state.NewFunction(func(L *lua.LState) int {
+		before = L.Context()
+		if err := script.call("Inner"); err != nil {
+			L.RaiseError("%v", err)
+		}
+
+		after = L.Context()
+		return 0
+	}))
+
+	w.Tick(1)
+	if before == nil || after != before {
+		t.Error("calling a hook from a hook replaced the deadline of the outer hook")
+	}
+
+	if script.state.Context() != nil {
+		t.Error("the deadline is kept after the hooks returned")
+	}
+}
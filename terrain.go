@@ -0,0 +1,75 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+// Package munfall terrain.go Defines the terrain types cells are made of.
+package munfall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Terrain defines the ground of a cell, how it can be crossed is defined per
+// movement class so boats, infantry and vehicles can treat the same cell
+// differently.
+type Terrain struct {
+	Name     string
+	Passable bool
+	// BlocksSight hides what lies behind the terrain from the fog of war.
+	BlocksSight bool
+	// MovementCosts multiplies the cost of moving over the terrain per movement
+	// class, classes that aren't listed use 1. A cost of 0 or less makes the
+	// terrain impassable for the class.
+	MovementCosts map[string]float32
+}
+
+// CanPass returns if actors of the movement class can move over the terrain.
+func (t *Terrain) CanPass(class string) bool {
+	if !t.Passable {
+		return false
+	}
+
+	cost, exists := t.MovementCosts[class]
+	return !exists || cost > 0
+}
+
+// MovementCost returns the cost multiplier of moving over the terrain for
+// the movement class, only meaningful if CanPass returns true.
+func (t *Terrain) MovementCost(class string) float32 {
+	if cost, exists := t.MovementCosts[class]; exists {
+		return cost
+	}
+
+	return 1
+}
+
+// ReadTerrainTypes reads terrain types from a JSON file of the form
+//
+//	[{"Name": "water", "Passable": true, "MovementCosts": {"foot": 0, "wheeled": 0}},
+//	 {"Name": "road", "Passable": true, "MovementCosts": {"foot": 0.8, "wheeled": 0.5}},
+//	 {"Name": "cliff", "Passable": false, "BlocksSight": true}]
+//
+// and returns them by name.
+func ReadTerrainTypes(r io.Reader) (map[string]*Terrain, error) {
+	var types []*Terrain
+	if err := json.NewDecoder(r).Decode(&types); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]*Terrain, len(types))
+	for i, t := range types {
+		if t.Name == "" {
+			return nil, fmt.Errorf("terrain type %d has no name", i)
+		}
+
+		if _, exists := out[t.Name]; exists {
+			return nil, fmt.Errorf("terrain type %s is defined twice", t.Name)
+		}
+
+		out[t.Name] = t
+	}
+
+	return out, nil
+}
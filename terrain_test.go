@@ -0,0 +1,60 @@
+// Copyright 2017 The bluemun Authors. All rights reserved.
+// Use of this source code is governed by a MIT License
+// license that can be found in the LICENSE file.
+
+package munfall
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTerrain = `[
+	{"Name": "water", "Passable": true, "MovementCosts": {"foot": 0, "naval": 1}},
+	{"Name": "road", "Passable": true, "MovementCosts": {"foot": 0.5}},
+	{"Name": "cliff", "Passable": false, "BlocksSight": true}]`
+
+func TestReadTerrainTypes(t *testing.T) {
+	types, err := ReadTerrainTypes(strings.NewReader(testTerrain))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		terrain, class string
+		canPass        bool
+		cost           float32
+	}{
+		{"water", "foot", false, 0},
+		{"water", "naval", true, 1},
+		{"water", "wheeled", true, 1},
+		{"road", "foot", true, 0.5},
+		{"road", "", true, 1},
+		{"cliff", "foot", false, 1},
+	} {
+		terrain := types[c.terrain]
+		if terrain.CanPass(c.class) != c.canPass {
+			t.Errorf("%s CanPass(%q) = %v", c.terrain, c.class, !c.canPass)
+		}
+
+		if c.canPass && terrain.MovementCost(c.class) != c.cost {
+			t.Errorf("%s MovementCost(%q) = %v, want %v", c.terrain, c.class, terrain.MovementCost(c.class), c.cost)
+		}
+	}
+
+	if !types["cliff"].BlocksSight || types["road"].BlocksSight {
+		t.Error("BlocksSight wasn't read")
+	}
+}
+
+func TestReadTerrainTypesErrors(t *testing.T) {
+	for _, source := range []string{
+		`[{"Passable": true}]`,
+		`[{"Name": "road"}, {"Name": "road"}]`,
+		`{"Name": "road"}`,
+	} {
+		if _, err := ReadTerrainTypes(strings.NewReader(source)); err == nil {
+			t.Errorf("%s was accepted", source)
+		}
+	}
+}
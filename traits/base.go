@@ -20,3 +20,10 @@ type TraitOrderResolver interface {
 	munfall.Trait
 	ResolveOrder(order *munfall.Order)
 }
+
+// TraitSyncHash is implemented by traits whose state is included in the world
+// checksum used to detect desyncs, the hash must only depend on simulation state.
+type TraitSyncHash interface {
+	munfall.Trait
+	SyncHash() uint64
+}
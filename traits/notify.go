@@ -26,3 +26,23 @@ type MoveNotifier interface {
 	munfall.Trait
 	NotifyMove(old, new *munfall.WPos)
 }
+
+// TraitAddedNotifier is notified when a trait is added to its actor after the actor was created.
+type TraitAddedNotifier interface {
+	munfall.Trait
+	NotifyTraitAdded(trait munfall.Trait)
+}
+
+// TraitRemovedNotifier is notified when a trait is removed from its actor.
+type TraitRemovedNotifier interface {
+	munfall.Trait
+	NotifyTraitRemoved(trait munfall.Trait)
+}
+
+// TraitDisposer is a trait that gets notified when it is removed from its actor,
+// replaced by a reload or disposed of together with its actor, it should
+// release the resources it holds.
+type TraitDisposer interface {
+	munfall.Trait
+	Dispose()
+}
@@ -38,9 +38,155 @@ func (s *SpaceCell) Intersects(other munfall.Space, offset *munfall.WPos) bool {
 		offset = &munfall.WPos{}
 	}
 
-	mpos1 := s.trait.Owner().World().WorldMap().ConvertToMPos(s.Offset().Add(offset))
-	mpos2 := s.trait.Owner().World().WorldMap().ConvertToMPos(other.Offset())
-	return *mpos1 == *mpos2
+	wm := s.trait.Owner().World().WorldMap()
+	pos := s.Offset().Add(offset)
+	if layered, ok := wm.(LayeredWorldMap); ok && !layered.LayersCollide(pos, other.Offset()) {
+		return false
+	}
+
+	return *wm.ConvertToMPos(pos) == *wm.ConvertToMPos(other.Offset())
+}
+
+// LayeredWorldMap is implemented by world maps that divide the world into
+// height layers, spaces on layers that don't collide never intersect.
+type LayeredWorldMap interface {
+	munfall.WorldMap
+	LayersCollide(a, b *munfall.WPos) bool
+}
+
+// ShapedSpace is a Space with an extent in the world instead of a single
+// point, world maps without cells index them by their bounds.
+type ShapedSpace interface {
+	munfall.Space
+	// Bounds returns the corners of the box around the space in the world.
+	Bounds() (low, high *munfall.WPos)
+	OverlapsCircle(center *munfall.WPos, radius float32) bool
+	OverlapsBox(low, high *munfall.WPos) bool
+}
+
+// SpaceCircle defines a Space that is a circle around its offset.
+type SpaceCircle struct {
+	trait munfall.Trait
+
+	LocalOffset *munfall.WPos
+	Radius      float32
+}
+
+// Initialize called when this space is created.
+func (s *SpaceCircle) Initialize(trait munfall.Trait) {
+	s.trait = trait
+}
+
+// Trait returns the trait the owns this Space
+func (s *SpaceCircle) Trait() munfall.Trait {
+	return s.trait
+}
+
+// Offset returns the center of the circle in the world.
+func (s *SpaceCircle) Offset() *munfall.WPos {
+	return s.LocalOffset.Add(s.trait.Owner().Pos())
+}
+
+// Bounds returns the corners of the box around the circle.
+func (s *SpaceCircle) Bounds() (low, high *munfall.WPos) {
+	c := s.Offset()
+	return &munfall.WPos{X: c.X - s.Radius, Y: c.Y - s.Radius}, &munfall.WPos{X: c.X + s.Radius, Y: c.Y + s.Radius}
+}
+
+// OverlapsCircle returns if the circle overlaps the given circle.
+func (s *SpaceCircle) OverlapsCircle(center *munfall.WPos, radius float32) bool {
+	c := s.Offset()
+	dx, dy, r := c.X-center.X, c.Y-center.Y, s.Radius+radius
+	return dx*dx+dy*dy < r*r
+}
+
+// OverlapsBox returns if the circle overlaps the given box.
+func (s *SpaceCircle) OverlapsBox(low, high *munfall.WPos) bool {
+	return circleOverlapsBox(s.Offset(), s.Radius, low, high)
+}
+
+// Intersects returns if the circle moved by offset intersects the other
+// space, spaces that aren't shaped are treated as a point.
+func (s *SpaceCircle) Intersects(other munfall.Space, offset *munfall.WPos) bool {
+	center := s.Offset()
+	if offset != nil {
+		center = center.Add(offset)
+	}
+
+	if shaped, ok := other.(ShapedSpace); ok {
+		return shaped.OverlapsCircle(center, s.Radius)
+	}
+
+	p := other.Offset()
+	dx, dy := p.X-center.X, p.Y-center.Y
+	return dx*dx+dy*dy < s.Radius*s.Radius
+}
+
+// SpaceBox defines a Space that is a box centered on its offset.
+type SpaceBox struct {
+	trait munfall.Trait
+
+	LocalOffset   *munfall.WPos
+	Width, Height float32
+}
+
+// Initialize called when this space is created.
+func (s *SpaceBox) Initialize(trait munfall.Trait) {
+	s.trait = trait
+}
+
+// Trait returns the trait the owns this Space
+func (s *SpaceBox) Trait() munfall.Trait {
+	return s.trait
+}
+
+// Offset returns the center of the box in the world.
+func (s *SpaceBox) Offset() *munfall.WPos {
+	return s.LocalOffset.Add(s.trait.Owner().Pos())
+}
+
+// Bounds returns the corners of the box.
+func (s *SpaceBox) Bounds() (low, high *munfall.WPos) {
+	return s.bounds(s.Offset())
+}
+
+func (s *SpaceBox) bounds(c *munfall.WPos) (low, high *munfall.WPos) {
+	return &munfall.WPos{X: c.X - s.Width/2, Y: c.Y - s.Height/2}, &munfall.WPos{X: c.X + s.Width/2, Y: c.Y + s.Height/2}
+}
+
+// OverlapsCircle returns if the box overlaps the given circle.
+func (s *SpaceBox) OverlapsCircle(center *munfall.WPos, radius float32) bool {
+	low, high := s.Bounds()
+	return circleOverlapsBox(center, radius, low, high)
+}
+
+// OverlapsBox returns if the box overlaps the given box.
+func (s *SpaceBox) OverlapsBox(low, high *munfall.WPos) bool {
+	l, h := s.Bounds()
+	return l.X < high.X && h.X > low.X && l.Y < high.Y && h.Y > low.Y
+}
+
+// Intersects returns if the box moved by offset intersects the other space,
+// spaces that aren't shaped are treated as a point.
+func (s *SpaceBox) Intersects(other munfall.Space, offset *munfall.WPos) bool {
+	center := s.Offset()
+	if offset != nil {
+		center = center.Add(offset)
+	}
+
+	low, high := s.bounds(center)
+	if shaped, ok := other.(ShapedSpace); ok {
+		return shaped.OverlapsBox(low, high)
+	}
+
+	p := other.Offset()
+	return p.X >= low.X && p.X < high.X && p.Y >= low.Y && p.Y < high.Y
+}
+
+func circleOverlapsBox(center *munfall.WPos, radius float32, low, high *munfall.WPos) bool {
+	closest := center.Clamp(low, high)
+	dx, dy := center.X-closest.X, center.Y-closest.Y
+	return dx*dx+dy*dy < radius*radius
 }
 
 // OccupySpace defines a trait that occupies space and should collide with
@@ -51,3 +197,51 @@ type OccupySpace interface {
 	Space() []munfall.Space
 	OutOfBounds(*munfall.WPos) bool
 }
+
+// Mobile defines a trait that moves its actor over the world map, the movement
+// class selects the terrain costs that apply to the actor.
+type Mobile interface {
+	munfall.Trait
+	MovementClass() string
+}
+
+// RevealsShroud defines a trait that lets a player see the cells within the
+// radius around its actor on maps with a fog of war.
+type RevealsShroud interface {
+	munfall.Trait
+	RevealRadius() float32
+	Player() int
+}
+
+// MovementClass returns the movement class of the actor's first Mobile trait,
+// an empty string if it has none or isn't part of a world. World returns a
+// typed nil for actors without one, so the value behind it is checked.
+func MovementClass(a munfall.Actor) string {
+	if munfall.IsNil(a) || munfall.IsNil(a.World()) {
+		return ""
+	}
+
+	for _, trait := range a.World().GetTraitsImplementing(a, (*Mobile)(nil)) {
+		return trait.(Mobile).MovementClass()
+	}
+
+	return ""
+}
+
+// MovementSpeed returns the speed of the actor on the terrain at its position,
+// 0 if it can't move over the terrain.
+func MovementSpeed(a munfall.Actor, speed float32) float32 {
+	wm := a.World().WorldMap()
+	pos := a.Pos()
+	if !wm.InsideMapWPos(pos) {
+		return speed
+	}
+
+	terrain := wm.CellAt(wm.ConvertToMPos(pos)).Terrain()
+	class := MovementClass(a)
+	if !terrain.CanPass(class) {
+		return 0
+	}
+
+	return speed / terrain.MovementCost(class)
+}